@@ -0,0 +1,851 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harshakumara/book-api/api/handlers"
+	"github.com/harshakumara/book-api/config"
+	"github.com/harshakumara/book-api/models"
+	"github.com/harshakumara/book-api/storage"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// bookStores lists every BookStore implementation the conformance suite
+// runs against, each with the RevisionStore of the same backend. Stores that
+// do not record their own revisions come wrapped in an AuditedBookStore.
+// MongoDB is included when MONGO_TEST_URI points at a server, as it does in
+// CI; each run uses a fresh database that is dropped afterwards.
+var bookStores = map[string]func(t *testing.T) (storage.BookStore, storage.RevisionStore){
+	"file": func(t *testing.T) (storage.BookStore, storage.RevisionStore) {
+		fs := config.NewFileStorage(filepath.Join(t.TempDir(), "books.json"))
+		t.Cleanup(func() { fs.Close() })
+		return storage.NewFileBookStore(fs), storage.NewFileRevisionStore(fs)
+	},
+	"memory": func(t *testing.T) (storage.BookStore, storage.RevisionStore) {
+		revisions := storage.NewMemoryRevisionStore()
+		return storage.NewAuditedBookStore(storage.NewMemoryBookStore(), revisions), revisions
+	},
+	"mongodb": func(t *testing.T) (storage.BookStore, storage.RevisionStore) {
+		uri := os.Getenv("MONGO_TEST_URI")
+		if uri == "" {
+			// CI starts a mongod, so a missing URI there is a broken job
+			if os.Getenv("CI") != "" {
+				t.Fatal("MONGO_TEST_URI must be set in CI")
+			}
+			t.Skip("MONGO_TEST_URI is not set")
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+		if err != nil {
+			t.Fatalf("connecting to MongoDB: %v", err)
+		}
+		db := client.Database("book_api_conformance_" + primitive.NewObjectID().Hex())
+		t.Cleanup(func() {
+			db.Drop(context.Background())
+			client.Disconnect(context.Background())
+		})
+
+		store := storage.NewMongoBookStore(db, storage.MongoOptions{})
+		if err := store.EnsureIndexes(ctx); err != nil {
+			t.Fatalf("creating indexes: %v", err)
+		}
+		revisions := storage.NewMongoRevisionStore(db, storage.MongoOptions{})
+		if err := revisions.EnsureIndexes(ctx); err != nil {
+			t.Fatalf("creating revision indexes: %v", err)
+		}
+		return storage.NewAuditedBookStore(store, revisions), revisions
+	},
+}
+
+// TestBookStoreConformance runs the same HTTP scenarios against every
+// BookStore so the backends cannot drift apart
+func TestBookStoreConformance(t *testing.T) {
+	for name, newStore := range bookStores {
+		t.Run(name, func(t *testing.T) {
+			books, revisions := newStore(t)
+			store, err := storage.NewIndexedBookStore(context.Background(), books, nil)
+			if err != nil {
+				t.Fatalf("Indexing failed: %v", err)
+			}
+			c := &conformanceClient{store: store, router: NewRouter(Handlers{
+				Books:      handlers.NewBookHandler(store, false),
+				Authors:    &handlers.AuthorHandler{},
+				Publishers: &handlers.PublisherHandler{},
+				Inventory:  &handlers.InventoryHandler{},
+				Orders:     &handlers.OrderHandler{},
+				History:    handlers.NewHistoryHandler(revisions, store, false),
+			})}
+
+			t.Run("missing books are 404", c.missingBooks)
+			t.Run("create, update, patch and delete", c.lifecycle)
+			t.Run("conditional writes", c.conditionalWrites)
+			t.Run("list, filter and paginate", c.listing)
+			t.Run("search", c.search)
+			t.Run("regex search", c.regexSearch)
+			t.Run("fuzzy search", c.fuzzySearch)
+			t.Run("autocomplete", c.autocomplete)
+			t.Run("bulk upsert and delete", c.bulk)
+			t.Run("bulk versions", c.bulkVersions)
+			t.Run("csv export and import", c.csv)
+			t.Run("unique isbn", c.uniqueISBN)
+			t.Run("facets", c.facets)
+			t.Run("structured query", c.structuredQuery)
+			t.Run("history and restore", c.history)
+		})
+	}
+}
+
+// conformanceClient sends requests to a router backed by the store under test
+type conformanceClient struct {
+	store  storage.BookStore
+	router http.Handler
+}
+
+func (c *conformanceClient) do(t *testing.T, method, path, body string, headers ...string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	rr := httptest.NewRecorder()
+	c.router.ServeHTTP(rr, req)
+	return rr
+}
+
+// expect checks the status code and, for errors, the error code of a response
+func expect(t *testing.T, rr *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+
+	if rr.Code != status {
+		t.Fatalf("Expected status %d, got %d: %s", status, rr.Code, rr.Body.String())
+	}
+	if code == "" {
+		return
+	}
+
+	var envelope struct {
+		Error struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &envelope)
+	if envelope.Error.Code != code {
+		t.Errorf("Expected error code %s, got %s", code, rr.Body.String())
+	}
+}
+
+func (c *conformanceClient) create(t *testing.T, book string) models.Book {
+	t.Helper()
+
+	rr := c.do(t, "POST", "/books", book)
+	expect(t, rr, http.StatusCreated, "")
+
+	var created models.Book
+	json.Unmarshal(rr.Body.Bytes(), &created)
+	return created
+}
+
+func decodeBook(t *testing.T, rr *httptest.ResponseRecorder) models.Book {
+	t.Helper()
+
+	var book models.Book
+	if err := json.Unmarshal(rr.Body.Bytes(), &book); err != nil {
+		t.Fatalf("Expected a book, got %s", rr.Body.String())
+	}
+	return book
+}
+
+func titles(t *testing.T, rr *httptest.ResponseRecorder) []string {
+	t.Helper()
+
+	var books []models.Book
+	if err := json.Unmarshal(rr.Body.Bytes(), &books); err != nil || books == nil {
+		t.Fatalf("Expected a JSON array of books, got %s", rr.Body.String())
+	}
+
+	out := make([]string, len(books))
+	for i, b := range books {
+		out[i] = b.Title
+	}
+	return out
+}
+
+func (c *conformanceClient) missingBooks(t *testing.T) {
+	missing := "/books/" + primitive.NewObjectID().Hex()
+
+	expect(t, c.do(t, "GET", missing, ""), http.StatusNotFound, "book_not_found")
+	expect(t, c.do(t, "PUT", missing, `{"title": "Ghost"}`), http.StatusNotFound, "book_not_found")
+	expect(t, c.do(t, "PATCH", missing, `{"price": 1}`), http.StatusNotFound, "book_not_found")
+	expect(t, c.do(t, "DELETE", missing, ""), http.StatusNotFound, "book_not_found")
+	expect(t, c.do(t, "DELETE", missing, "", "If-Match", `"1"`), http.StatusNotFound, "book_not_found")
+}
+
+func (c *conformanceClient) lifecycle(t *testing.T) {
+	book := c.create(t, `{"title": "Middlemarch", "genre": "Classic", "isbn": "0306406152", "price": 12, "quantity": 3}`)
+	if book.ID.IsZero() || book.Version != 1 {
+		t.Fatalf("Expected an ID and version 1, got %+v", book)
+	}
+	path := "/books/" + book.ID.Hex()
+
+	rr := c.do(t, "GET", path, "")
+	expect(t, rr, http.StatusOK, "")
+	if got := decodeBook(t, rr); got.Title != "Middlemarch" || rr.Header().Get("ETag") != `"1"` {
+		t.Errorf("Expected the created book with ETag \"1\", got %+v %q", got, rr.Header().Get("ETag"))
+	}
+
+	// PUT replaces the whole book
+	rr = c.do(t, "PUT", path, `{"title": "Middlemarch", "price": 14}`)
+	expect(t, rr, http.StatusOK, "")
+	if got := decodeBook(t, rr); got.Version != 2 || got.Genre != "" || got.Price != 14 || got.ID != book.ID {
+		t.Errorf("Expected a full replacement at version 2, got %+v", got)
+	}
+
+	// PATCH changes only the given fields
+	rr = c.do(t, "PATCH", path, `{"genre": "Classic"}`)
+	expect(t, rr, http.StatusOK, "")
+	if got := decodeBook(t, rr); got.Version != 3 || got.Genre != "Classic" || got.Price != 14 {
+		t.Errorf("Expected a partial update at version 3, got %+v", got)
+	}
+
+	expect(t, c.do(t, "PUT", path, `{"title": ""}`), http.StatusUnprocessableEntity, "validation_failed")
+	if got := decodeBook(t, c.do(t, "GET", path, "")); got.Title != "Middlemarch" || got.Version != 3 {
+		t.Errorf("Expected a rejected update to change nothing, got %+v", got)
+	}
+
+	expect(t, c.do(t, "DELETE", path, ""), http.StatusNoContent, "")
+	expect(t, c.do(t, "GET", path, ""), http.StatusNotFound, "book_not_found")
+	expect(t, c.do(t, "DELETE", path, ""), http.StatusNotFound, "book_not_found")
+}
+
+func (c *conformanceClient) conditionalWrites(t *testing.T) {
+	book := c.create(t, `{"title": "Persuasion"}`)
+	path := "/books/" + book.ID.Hex()
+
+	expect(t, c.do(t, "GET", path, "", "If-None-Match", `"1"`), http.StatusNotModified, "")
+	expect(t, c.do(t, "PUT", path, `{"title": "Persuasion"}`, "If-Match", `"1"`), http.StatusOK, "")
+	expect(t, c.do(t, "PUT", path, `{"title": "Lost"}`, "If-Match", `"1"`), http.StatusPreconditionFailed, "precondition_failed")
+	expect(t, c.do(t, "PATCH", path, `{"title": "Lost"}`, "If-Match", `"1"`), http.StatusPreconditionFailed, "precondition_failed")
+	expect(t, c.do(t, "DELETE", path, "", "If-Match", `"1"`), http.StatusPreconditionFailed, "precondition_failed")
+	expect(t, c.do(t, "DELETE", path, "", "If-Match", `"2"`), http.StatusNoContent, "")
+}
+
+func (c *conformanceClient) listing(t *testing.T) {
+	for _, b := range []string{
+		`{"title": "Listing A", "genre": "Listing", "price": 30}`,
+		`{"title": "Listing B", "genre": "Listing", "price": 10}`,
+		`{"title": "Listing C", "genre": "Listing", "price": 20}`,
+		`{"title": "Listing D", "genre": "Other", "price": 5}`,
+	} {
+		c.create(t, b)
+	}
+
+	rr := c.do(t, "GET", "/books?genre=Listing&sort=price&limit=2", "")
+	expect(t, rr, http.StatusOK, "")
+	if got := titles(t, rr); len(got) != 2 || got[0] != "Listing B" || got[1] != "Listing C" {
+		t.Errorf("Expected the two cheapest Listing books, got %v", got)
+	}
+	if rr.Header().Get("X-Total-Count") != "3" {
+		t.Errorf("Expected X-Total-Count 3, got %q", rr.Header().Get("X-Total-Count"))
+	}
+
+	cursor := rr.Header().Get("X-Next-Cursor")
+	if cursor == "" {
+		t.Fatal("Expected a next cursor")
+	}
+	rr = c.do(t, "GET", "/books?genre=Listing&sort=price&limit=2&cursor="+cursor, "")
+	expect(t, rr, http.StatusOK, "")
+	if got := titles(t, rr); len(got) != 1 || got[0] != "Listing A" {
+		t.Errorf("Expected the last Listing book on page 2, got %v", got)
+	}
+	if rr.Header().Get("X-Next-Cursor") != "" {
+		t.Error("Expected no cursor after the last page")
+	}
+
+	expect(t, c.do(t, "GET", "/books?sort=color", ""), http.StatusBadRequest, "invalid_query")
+}
+
+func (c *conformanceClient) search(t *testing.T) {
+	c.create(t, `{"title": "The Hobbit", "description": "A hobbit goes on an adventure"}`)
+	c.create(t, `{"title": "Dracula", "description": "A vampire novel"}`)
+
+	rr := c.do(t, "GET", "/books/search?q=HOBBIT", "")
+	expect(t, rr, http.StatusOK, "")
+	if got := titles(t, rr); len(got) != 1 || got[0] != "The Hobbit" {
+		t.Errorf("Expected a case-insensitive title match, got %v", got)
+	}
+
+	rr = c.do(t, "GET", "/books/search?q=novel", "")
+	if got := titles(t, rr); len(got) != 1 || got[0] != "Dracula" {
+		t.Errorf("Expected a description match, got %v", got)
+	}
+
+	if got := titles(t, c.do(t, "GET", "/books/search?q=nothing-matches", "")); len(got) != 0 {
+		t.Errorf("Expected an empty array, got %v", got)
+	}
+
+	// Stemmed words match, title matches rank first and genres are searched
+	c.create(t, `{"title": "Recipes", "genre": "Cooking", "description": "Dishes for a wizard on the go"}`)
+	wizards := c.create(t, `{"title": "Wizards of the Coast", "genre": "Fantasy", "description": "Wizarding schools"}`)
+
+	rr = c.do(t, "GET", "/books/search?q=wizard", "")
+	expect(t, rr, http.StatusOK, "")
+	var hits []storage.SearchHit
+	json.Unmarshal(rr.Body.Bytes(), &hits)
+	if len(hits) != 2 || hits[0].Title != "Wizards of the Coast" || hits[0].Score <= hits[1].Score || hits[1].Score <= 0 {
+		t.Fatalf("Expected the title match ranked first with scores, got %s", rr.Body.String())
+	}
+	if got := titles(t, c.do(t, "GET", "/books/search?q=fantasy&limit=1", "")); len(got) != 1 || got[0] != "Wizards of the Coast" {
+		t.Errorf("Expected a genre match, got %v", got)
+	}
+	expect(t, c.do(t, "GET", "/books/search?q=wizard&limit=-1", ""), http.StatusBadRequest, "invalid_query")
+
+	// Writes update the index
+	expect(t, c.do(t, "PATCH", "/books/"+wizards.ID.Hex(), `{"title": "Sorcerers of the Coast"}`), http.StatusOK, "")
+	if got := titles(t, c.do(t, "GET", "/books/search?q=sorcerer", "")); len(got) != 1 {
+		t.Errorf("Expected the new title to be searchable, got %v", got)
+	}
+	expect(t, c.do(t, "DELETE", "/books/"+wizards.ID.Hex(), ""), http.StatusNoContent, "")
+	if got := titles(t, c.do(t, "GET", "/books/search?q=coast", "")); len(got) != 0 {
+		t.Errorf("Expected a deleted book to leave the index, got %v", got)
+	}
+}
+
+func (c *conformanceClient) regexSearch(t *testing.T) {
+	c.create(t, `{"title": "C++ Primer", "description": "Templates (and more) explained"}`)
+	c.create(t, `{"title": "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa!", "description": "A worst case for (a+)+$"}`)
+
+	// Plain searches match the keyword literally
+	for keyword, want := range map[string]int{"c++": 1, "(and more)": 1, "(a+)+$": 1, ".*": 0, "[": 0, "^c": 0} {
+		books, err := c.store.Search(context.Background(), keyword)
+		if err != nil || len(books) != want {
+			t.Errorf("Expected %d literal matches for %q, got %d, %v", want, keyword, len(books), err)
+		}
+	}
+
+	regex := func(pattern string) *httptest.ResponseRecorder {
+		return c.do(t, "GET", "/books/search?"+url.Values{"mode": {"regex"}, "q": {pattern}}.Encode(), "")
+	}
+
+	rr := regex(`^c\+\+ primer$`)
+	expect(t, rr, http.StatusOK, "")
+	if got := titles(t, rr); len(got) != 1 || got[0] != "C++ Primer" {
+		t.Errorf("Expected a case-insensitive regex match, got %v", got)
+	}
+	if got := titles(t, regex("explained|worst")); len(got) != 2 {
+		t.Errorf("Expected a regex match on descriptions, got %v", got)
+	}
+	if got := titles(t, regex(".*")); len(got) < 2 {
+		t.Errorf("Expected .* to match every book, got %v", got)
+	}
+
+	// Patterns that backtrack badly are rejected before reaching the store
+	for _, pattern := range []string{"(a+)+$", "(a|aa)*!", "(.*a){20}", `(\w+)\1`, "(?=a)", "a{1000}", strings.Repeat("a", 101)} {
+		expect(t, regex(pattern), http.StatusBadRequest, "invalid_query")
+	}
+	expect(t, c.do(t, "GET", "/books/search?q=a&mode=glob", ""), http.StatusBadRequest, "invalid_query")
+}
+
+func (c *conformanceClient) fuzzySearch(t *testing.T) {
+	c.create(t, `{"title": "Harry Potter and the Philosopher's Stone", "description": "A boy learns he is a wizard"}`)
+	c.create(t, `{"title": "The Great Gatsby", "description": "Wealth and love in the 1920s"}`)
+
+	search := func(query string) handlers.SearchResponse {
+		t.Helper()
+		rr := c.do(t, "GET", "/books/search?"+query, "")
+		expect(t, rr, http.StatusOK, "")
+		var resp handlers.SearchResponse
+		json.Unmarshal(rr.Body.Bytes(), &resp)
+		return resp
+	}
+
+	resp := search("q=harry+poter&fuzziness=auto")
+	if len(resp.Books) == 0 || resp.Books[0].Title != "Harry Potter and the Philosopher's Stone" || !reflect.DeepEqual(resp.Suggestions, []string{"harry potter"}) {
+		t.Errorf("Expected the misspelled title first with a suggestion, got %+v", resp)
+	}
+	resp = search("q=gatsbee&fuzziness=2")
+	if len(resp.Books) != 1 || resp.Books[0].Title != "The Great Gatsby" || !reflect.DeepEqual(resp.Suggestions, []string{"gatsby"}) {
+		t.Errorf("Expected a match two edits away, got %+v", resp)
+	}
+	if resp = search("q=gatsbee&fuzziness=0"); len(resp.Books) != 0 || len(resp.Suggestions) != 0 {
+		t.Errorf("Expected no typo tolerance at fuzziness 0, got %+v", resp)
+	}
+	if resp = search("q=gatsby&fuzziness=auto"); len(resp.Books) != 1 || resp.Suggestions != nil {
+		t.Errorf("Expected exact hits without suggestions, got %+v", resp)
+	}
+
+	expect(t, c.do(t, "GET", "/books/search?q=gatsbee&fuzziness=3", ""), http.StatusBadRequest, "invalid_query")
+	expect(t, c.do(t, "GET", "/books/search?q=gatsbee&fuzziness=1&mode=regex", ""), http.StatusBadRequest, "invalid_query")
+}
+
+func (c *conformanceClient) autocomplete(t *testing.T) {
+	quixote := c.create(t, `{"title": "Don Quixote", "isbn": "978-1-4028-9462-6"}`)
+	c.create(t, `{"title": "Quiet Flows the Don"}`)
+
+	complete := func(query string) []storage.Completion {
+		t.Helper()
+		rr := c.do(t, "GET", "/books/autocomplete?"+query, "")
+		expect(t, rr, http.StatusOK, "")
+		var completions []storage.Completion
+		if err := json.Unmarshal(rr.Body.Bytes(), &completions); err != nil || completions == nil {
+			t.Fatalf("Expected a JSON array of completions, got %s", rr.Body.String())
+		}
+		return completions
+	}
+
+	got := complete("prefix=qui")
+	if len(got) != 2 || got[0].Text != "Quiet Flows the Don" || got[1].Text != "Don Quixote" || got[1].ID != quixote.ID.Hex() {
+		t.Errorf("Expected the title starting with the prefix first, got %+v", got)
+	}
+	if got := complete("prefix=978-1-40"); len(got) != 1 || got[0].Kind != "isbn" || got[0].Text != "9781402894626" {
+		t.Errorf("Expected a hyphenated ISBN prefix to complete, got %+v", got)
+	}
+	if got := complete("prefix=qui&limit=1"); len(got) != 1 {
+		t.Errorf("Expected the limit to apply, got %+v", got)
+	}
+
+	// Writes keep completions current
+	expect(t, c.do(t, "PATCH", "/books/"+quixote.ID.Hex(), `{"title": "Quixotic Tales"}`), http.StatusOK, "")
+	if got := complete("prefix=don+q"); len(got) != 0 {
+		t.Errorf("Expected the old title to be gone, got %+v", got)
+	}
+	expect(t, c.do(t, "DELETE", "/books/"+quixote.ID.Hex(), ""), http.StatusNoContent, "")
+	if got := complete("prefix=quixo"); len(got) != 0 {
+		t.Errorf("Expected a deleted book to leave no completions, got %+v", got)
+	}
+
+	expect(t, c.do(t, "GET", "/books/autocomplete?prefix=+", ""), http.StatusBadRequest, "invalid_query")
+	expect(t, c.do(t, "GET", "/books/autocomplete?prefix=a&limit=51", ""), http.StatusBadRequest, "invalid_query")
+}
+
+// bulkResult decodes the body of a bulk request
+func bulkResult(t *testing.T, rr *httptest.ResponseRecorder) handlers.BulkUpsertResponse {
+	t.Helper()
+
+	var resp handlers.BulkUpsertResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || len(resp.Results) == 0 {
+		t.Fatalf("Expected a bulk response, got %s", rr.Body.String())
+	}
+	return resp
+}
+
+func (c *conformanceClient) bulk(t *testing.T) {
+	batch := `[{"title": "Bulk A", "genre": "Bulk"}, {"title": ""}, {"title": "Bulk B", "genre": "Bulk"}]`
+
+	// One invalid book rejects the whole atomic batch
+	rr := c.do(t, "POST", "/books/bulk", batch)
+	expect(t, rr, http.StatusUnprocessableEntity, "")
+	resp := bulkResult(t, rr)
+	if resp.Failed != 3 || resp.Results[0].Error.Code != "not_applied" || resp.Results[1].Error.Code != "validation_failed" {
+		t.Errorf("Expected every item to fail, got %s", rr.Body.String())
+	}
+	if got := titles(t, c.do(t, "GET", "/books?genre=Bulk", "")); len(got) != 0 {
+		t.Fatalf("Expected nothing written, got %v", got)
+	}
+
+	// Best effort writes the valid books
+	rr = c.do(t, "POST", "/books/bulk?mode=best-effort", batch)
+	expect(t, rr, http.StatusMultiStatus, "")
+	resp = bulkResult(t, rr)
+	if resp.Created != 2 || resp.Failed != 1 || resp.Results[2].Status != http.StatusCreated || resp.Results[2].Version != 1 {
+		t.Errorf("Expected two books created, got %s", rr.Body.String())
+	}
+	first := resp.Results[0].BookID
+
+	// NDJSON lines replace by ID, create without one, and fail alone when malformed
+	ndjson := strings.Join([]string{
+		`{"bookId": "` + first + `", "title": "Bulk A2", "genre": "Bulk"}`,
+		`{"title": "Bulk C", "genre": "Bulk"}`,
+		`{"title": `,
+	}, "\n")
+	rr = c.do(t, "POST", "/books/bulk?mode=best-effort", ndjson, "Content-Type", "application/x-ndjson")
+	expect(t, rr, http.StatusMultiStatus, "")
+	resp = bulkResult(t, rr)
+	if resp.Updated != 1 || resp.Created != 1 || resp.Results[0].Version != 2 || resp.Results[2].Error.Code != "invalid_body" {
+		t.Errorf("Expected one update, one create and one bad line, got %s", rr.Body.String())
+	}
+	if got := decodeBook(t, c.do(t, "GET", "/books/"+first, "")); got.Title != "Bulk A2" || got.Version != 2 {
+		t.Errorf("Expected the book replaced at version 2, got %+v", got)
+	}
+
+	expect(t, c.do(t, "POST", "/books/bulk?mode=sometimes", batch), http.StatusBadRequest, "invalid_query")
+	expect(t, c.do(t, "POST", "/books/bulk", `[]`), http.StatusBadRequest, "invalid_body")
+
+	// Deleting by ID is all-or-nothing unless asked otherwise
+	missing := primitive.NewObjectID().Hex()
+	ids := `{"ids": ["` + first + `", "` + missing + `"]}`
+	expect(t, c.do(t, "POST", "/books/bulk/delete", ids), http.StatusUnprocessableEntity, "")
+	expect(t, c.do(t, "GET", "/books/"+first, ""), http.StatusOK, "")
+
+	rr = c.do(t, "POST", "/books/bulk/delete?mode=best-effort", ids)
+	expect(t, rr, http.StatusMultiStatus, "")
+	if !strings.Contains(rr.Body.String(), `"deleted":1`) || !strings.Contains(rr.Body.String(), `"book_not_found"`) {
+		t.Errorf("Expected one deletion and one miss, got %s", rr.Body.String())
+	}
+	expect(t, c.do(t, "GET", "/books/"+first, ""), http.StatusNotFound, "book_not_found")
+
+	// Deleting by filter removes every match
+	expect(t, c.do(t, "POST", "/books/bulk/delete", `{"filter": {}}`), http.StatusBadRequest, "invalid_body")
+	rr = c.do(t, "POST", "/books/bulk/delete", `{"filter": {"genre": "Bulk"}}`)
+	expect(t, rr, http.StatusOK, "")
+	if strings.TrimSpace(rr.Body.String()) != `{"deleted":2}` {
+		t.Errorf("Expected two books deleted by filter, got %s", rr.Body.String())
+	}
+	if got := titles(t, c.do(t, "GET", "/books?genre=Bulk", "")); len(got) != 0 {
+		t.Errorf("Expected no Bulk books left, got %v", got)
+	}
+}
+
+func (c *conformanceClient) bulkVersions(t *testing.T) {
+	book := c.create(t, `{"title": "Versioned", "genre": "BulkVersions"}`)
+	id := book.ID.Hex()
+	replace := func(version int) string {
+		return fmt.Sprintf(`[{"bookId": %q, "version": %d, "title": "Versioned", "genre": "BulkVersions"}]`, id, version)
+	}
+
+	// A version makes the replacement conditional, like If-Match
+	rr := c.do(t, "POST", "/books/bulk", replace(1))
+	expect(t, rr, http.StatusOK, "")
+	if resp := bulkResult(t, rr); resp.Updated != 1 || resp.Results[0].Version != 2 {
+		t.Fatalf("Expected a replacement at version 2, got %s", rr.Body.String())
+	}
+	rr = c.do(t, "POST", "/books/bulk", replace(1))
+	expect(t, rr, http.StatusUnprocessableEntity, "")
+	if resp := bulkResult(t, rr); resp.Results[0].Error.Code != "precondition_failed" {
+		t.Errorf("Expected a stale version to fail, got %s", rr.Body.String())
+	}
+	missing := primitive.NewObjectID().Hex()
+	rr = c.do(t, "POST", "/books/bulk", fmt.Sprintf(`[{"bookId": %q, "version": 1, "title": "Gone"}]`, missing))
+	if resp := bulkResult(t, rr); resp.Results[0].Error.Code != "book_not_found" {
+		t.Errorf("Expected a versioned replacement of a missing book to fail, got %s", rr.Body.String())
+	}
+
+	// A book sent twice in one batch is written once and the repeat fails
+	twice := fmt.Sprintf(`[{"bookId": %q, "title": "Once", "genre": "BulkVersions"}, {"bookId": %q, "title": "Twice", "genre": "BulkVersions"}]`, id, id)
+	rr = c.do(t, "POST", "/books/bulk", twice)
+	expect(t, rr, http.StatusUnprocessableEntity, "")
+	if resp := bulkResult(t, rr); resp.Results[0].Error.Code != "not_applied" || resp.Results[1].Error.Code != "validation_failed" {
+		t.Errorf("Expected the repeated book to fail the batch, got %s", rr.Body.String())
+	}
+	rr = c.do(t, "POST", "/books/bulk?mode=best-effort", twice)
+	expect(t, rr, http.StatusMultiStatus, "")
+	if resp := bulkResult(t, rr); resp.Updated != 1 || resp.Results[1].Status != http.StatusUnprocessableEntity {
+		t.Errorf("Expected only the first copy written, got %s", rr.Body.String())
+	}
+	if got := decodeBook(t, c.do(t, "GET", "/books/"+id, "")); got.Title != "Once" || got.Version != 3 {
+		t.Errorf("Expected the first copy at version 3, got %+v", got)
+	}
+
+	// IDs may be sent with the version they must be at
+	rr = c.do(t, "POST", "/books/bulk/delete", fmt.Sprintf(`{"ids": [{"bookId": %q, "version": 1}]}`, id))
+	expect(t, rr, http.StatusUnprocessableEntity, "")
+	if !strings.Contains(rr.Body.String(), `"precondition_failed"`) {
+		t.Errorf("Expected a stale version to fail the delete, got %s", rr.Body.String())
+	}
+	expect(t, c.do(t, "GET", "/books/"+id, ""), http.StatusOK, "")
+
+	rr = c.do(t, "POST", "/books/bulk/delete", fmt.Sprintf(`{"ids": [{"bookId": %q, "version": 3}]}`, id))
+	expect(t, rr, http.StatusOK, "")
+	expect(t, c.do(t, "GET", "/books/"+id, ""), http.StatusNotFound, "book_not_found")
+}
+
+func (c *conformanceClient) csv(t *testing.T) {
+	c.create(t, `{"title": "Emma", "genre": "CSV", "isbn": "9780141439587", "price": 7.5, "quantity": 4}`)
+	c.create(t, `{"title": "Ulysses, Annotated", "genre": "CSV", "isbn": "9780199535675", "price": 11}`)
+	c.create(t, `{"title": "Elsewhere", "genre": "Other"}`)
+
+	rr := c.do(t, "GET", "/books/export?format=csv&genre=CSV&sort=title", "")
+	expect(t, rr, http.StatusOK, "")
+	if !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/csv") {
+		t.Errorf("Expected a CSV content type, got %q", rr.Header().Get("Content-Type"))
+	}
+	rows := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	if len(rows) != 3 || rows[0] != "bookId,authorId,publisherId,title,publicationDate,isbn,pages,genre,description,price,quantity,version" {
+		t.Fatalf("Expected a header and two rows, got %q", rr.Body.String())
+	}
+	if !strings.Contains(rows[1], ",Emma,,9780141439587,0,CSV,,7.5,4,1") || !strings.Contains(rows[2], `"Ulysses, Annotated"`) {
+		t.Errorf("Expected the filtered books in title order, got %q", rr.Body.String())
+	}
+	expect(t, c.do(t, "GET", "/books/export?format=xml", ""), http.StatusBadRequest, "invalid_query")
+
+	// Headers are matched loosely or mapped; missing columns keep their values
+	file := "ISBN,Book Title,Cost,Shelf\n" +
+		"9780141439587,Emma,8.25,A1\n" +
+		"9780140449136,Crime and Punishment,12,B2\n" +
+		"9780140449136,Crime and Punishment,12,B3\n" +
+		",No ISBN,1,C1\n"
+	csvHeaders := []string{"Content-Type", "text/csv"}
+
+	rr = c.do(t, "POST", "/books/import?dryRun=true&map=Book+Title:title&map=Cost:price", file, csvHeaders...)
+	expect(t, rr, http.StatusUnprocessableEntity, "")
+	var resp handlers.ImportResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if !resp.DryRun || resp.Updated != 1 || resp.Created != 1 || resp.Failed != 2 || len(resp.IgnoredColumns) != 1 {
+		t.Fatalf("Expected one update, one create and two bad rows, got %s", rr.Body.String())
+	}
+	if resp.Results[2].Line != 4 || resp.Results[2].Error.Code != "duplicate_isbn" || resp.Results[3].Error.Code != "validation_failed" {
+		t.Errorf("Expected row-level errors with line numbers, got %s", rr.Body.String())
+	}
+	if got := titles(t, c.do(t, "GET", "/books?genre=CSV&sort=title", "")); len(got) != 2 {
+		t.Fatalf("Expected a dry run to write nothing, got %v", got)
+	}
+
+	rr = c.do(t, "POST", "/books/import?mode=best-effort&map=Book+Title:title&map=Cost:price", file, csvHeaders...)
+	expect(t, rr, http.StatusMultiStatus, "")
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp.DryRun || resp.Updated != 1 || resp.Created != 1 {
+		t.Fatalf("Expected one update and one create, got %s", rr.Body.String())
+	}
+
+	rr = c.do(t, "GET", "/books/"+resp.Results[0].BookID, "")
+	if got := decodeBook(t, rr); got.Title != "Emma" || got.Price != 8.25 || got.Quantity != 4 || got.Genre != "CSV" || got.Version != 2 {
+		t.Errorf("Expected only the imported columns to change, got %+v", got)
+	}
+
+	expect(t, c.do(t, "POST", "/books/import", file), http.StatusUnsupportedMediaType, "unsupported_media_type")
+	expect(t, c.do(t, "POST", "/books/import", "title\nEmma\n", csvHeaders...), http.StatusBadRequest, "invalid_body")
+}
+
+func (c *conformanceClient) uniqueISBN(t *testing.T) {
+	// ISBNs are stored as bare ISBN-13s
+	book := c.create(t, `{"title": "SICP", "isbn": "0-262-03384-4"}`)
+	if book.ISBN != "9780262033848" {
+		t.Errorf("Expected the ISBN-10 converted to ISBN-13, got %q", book.ISBN)
+	}
+
+	for _, isbn := range []string{"9780262033848", "978-0-262-03384-8", "0262033844"} {
+		rr := c.do(t, "GET", "/books/isbn/"+isbn, "")
+		expect(t, rr, http.StatusOK, "")
+		if got := decodeBook(t, rr); got.ID != book.ID || rr.Header().Get("ETag") != `"1"` {
+			t.Errorf("Expected %s to find the book, got %+v", isbn, got)
+		}
+	}
+	expect(t, c.do(t, "GET", "/books/isbn/9780131103627", ""), http.StatusNotFound, "book_not_found")
+	expect(t, c.do(t, "GET", "/books/isbn/12345", ""), http.StatusBadRequest, "invalid_id")
+
+	// Another book cannot take the ISBN in any form
+	expect(t, c.do(t, "POST", "/books", `{"title": "Copy", "isbn": "978-0262033848"}`), http.StatusConflict, "duplicate_isbn")
+	other := c.create(t, `{"title": "K&R", "isbn": "0131103628"}`)
+	path := "/books/" + other.ID.Hex()
+	expect(t, c.do(t, "PUT", path, `{"title": "K&R", "isbn": "0262033844"}`), http.StatusConflict, "duplicate_isbn")
+	expect(t, c.do(t, "PATCH", path, `{"isbn": "9780262033848"}`), http.StatusConflict, "duplicate_isbn")
+	if got := decodeBook(t, c.do(t, "GET", path, "")); got.ISBN != "9780131103627" || got.Version != 1 {
+		t.Errorf("Expected a rejected write to change nothing, got %+v", got)
+	}
+
+	// A book keeps its own ISBN across updates
+	expect(t, c.do(t, "PUT", "/books/"+book.ID.Hex(), `{"title": "SICP, 2nd ed.", "isbn": "9780262033848"}`), http.StatusOK, "")
+
+	rr := c.do(t, "POST", "/books/bulk?mode=best-effort", `[{"title": "Dup", "isbn": "0262033844"}, {"title": "New", "isbn": "9780596517748"}, {"title": "New again", "isbn": "9780596517748"}]`)
+	expect(t, rr, http.StatusMultiStatus, "")
+	resp := bulkResult(t, rr)
+	if resp.Created != 1 || resp.Results[0].Error.Code != "duplicate_isbn" || resp.Results[2].Error.Code != "duplicate_isbn" {
+		t.Errorf("Expected duplicates against stored books and within the batch to fail, got %s", rr.Body.String())
+	}
+}
+
+func (c *conformanceClient) facets(t *testing.T) {
+	c.create(t, `{"title": "Facet One", "genre": "Poetry", "price": 5, "quantity": 1, "publicationDate": "1995-01-01"}`)
+	c.create(t, `{"title": "Facet Two", "genre": "Poetry", "price": 15, "quantity": 0, "publicationDate": "1999-06-01"}`)
+	c.create(t, `{"title": "Facet Three", "genre": "Poetry", "price": 60, "quantity": 2, "publicationDate": "2003-02-01"}`)
+
+	rr := c.do(t, "GET", "/books?genre=Poetry&limit=1&facets", "")
+	expect(t, rr, http.StatusOK, "")
+	var list handlers.BookListResponse
+	json.Unmarshal(rr.Body.Bytes(), &list)
+	want := storage.Facets{
+		"genre":     {{Value: "Poetry", Count: 3}},
+		"price":     {{Value: "0-10", Count: 1}, {Value: "10-20", Count: 1}, {Value: "50+", Count: 1}},
+		"decade":    {{Value: "1990s", Count: 2}, {Value: "2000s", Count: 1}},
+		"stock":     {{Value: "inStock", Count: 2}, {Value: "outOfStock", Count: 1}},
+		"publisher": {},
+	}
+	if len(list.Books) != 1 || !reflect.DeepEqual(list.Facets, want) {
+		t.Errorf("Expected one book and facets over every match, got %s", rr.Body.String())
+	}
+
+	// Facet values are accepted as filters
+	if got := titles(t, c.do(t, "GET", "/books?genre=Poetry&decade=1990s&priceBand=10-20", "")); len(got) != 1 || got[0] != "Facet Two" {
+		t.Errorf("Expected the decade and price band to filter, got %v", got)
+	}
+	expect(t, c.do(t, "GET", "/books?decade=199", ""), http.StatusBadRequest, "invalid_query")
+	expect(t, c.do(t, "GET", "/books?priceBand=5-6", ""), http.StatusBadRequest, "invalid_query")
+	expect(t, c.do(t, "GET", "/books?facets=genre,color", ""), http.StatusBadRequest, "invalid_query")
+
+	rr = c.do(t, "GET", "/books/search?q=facet&inStock=true&facets=stock,decade&limit=1", "")
+	expect(t, rr, http.StatusOK, "")
+	var search handlers.SearchResponse
+	json.Unmarshal(rr.Body.Bytes(), &search)
+	want = storage.Facets{
+		"stock":  {{Value: "inStock", Count: 2}},
+		"decade": {{Value: "1990s", Count: 1}, {Value: "2000s", Count: 1}},
+	}
+	if len(search.Books) != 1 || !reflect.DeepEqual(search.Facets, want) {
+		t.Errorf("Expected filtered search results with facets, got %s", rr.Body.String())
+	}
+}
+
+func (c *conformanceClient) structuredQuery(t *testing.T) {
+	c.create(t, `{"title": "Spellbound", "genre": "Fantasy", "price": 12.5, "pages": 420, "quantity": 3, "publicationDate": "1997-06-26", "description": "The tale of a young wizard"}`)
+	c.create(t, `{"title": "Spellbound Deluxe", "genre": "Fantasy", "price": 45, "pages": 420, "quantity": 0, "publicationDate": "2001-11-16", "description": "The tale of a young wizard, illustrated"}`)
+	c.create(t, `{"title": "Pocket Spells", "genre": "fantasy", "price": 8, "pages": 120, "quantity": 1, "publicationDate": "1997-01-10", "description": "A young wizard's handbook"}`)
+
+	query := func(q string) *httptest.ResponseRecorder {
+		return c.do(t, "GET", "/books/search?"+url.Values{"query": {q}}.Encode(), "")
+	}
+
+	for q, want := range map[string][]string{
+		`genre:Fantasy price:<20 pages:>300 "young wizard"`: {"Spellbound"},
+		`genre:fantasy "young wizard"`:                      {"Pocket Spells", "Spellbound", "Spellbound Deluxe"},
+		`"young wizard" (price:>=40 OR pages:<=120)`:        {"Pocket Spells", "Spellbound Deluxe"},
+		`"young wizard" -title:deluxe published:1997`:       {"Pocket Spells", "Spellbound"},
+		`"young wizard" published:>1997`:                    {"Spellbound Deluxe"},
+		`"young wizard" published:<=1997-01`:                {"Pocket Spells"},
+		`"young wizard" NOT inStock:true`:                   {"Spellbound Deluxe"},
+	} {
+		rr := query(q)
+		expect(t, rr, http.StatusOK, "")
+		if got := titles(t, rr); !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %v for %s, got %v", want, q, got)
+		}
+	}
+
+	// Filters, facets and limit apply to structured queries too
+	rr := c.do(t, "GET", "/books/search?"+url.Values{"query": {`"young wizard"`}, "inStock": {"true"}, "facets": {"price"}, "limit": {"1"}}.Encode(), "")
+	expect(t, rr, http.StatusOK, "")
+	var resp handlers.SearchResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	want := storage.Facets{"price": {{Value: "0-10", Count: 1}, {Value: "10-20", Count: 1}}}
+	if len(resp.Books) != 1 || resp.Books[0].Title != "Pocket Spells" || !reflect.DeepEqual(resp.Facets, want) {
+		t.Errorf("Expected filtered results with facets, got %s", rr.Body.String())
+	}
+
+	// Syntax errors point at the offending character
+	for q, position := range map[string]int{
+		`genre:Fantasy price:<`:  22,
+		`(price:<20 OR pages:>1`: 1,
+		`"young wizard`:          1,
+		`colour:red`:             1,
+		`genre:>Fantasy`:         7,
+		`pages:>many`:            8,
+	} {
+		rr := query(q)
+		expect(t, rr, http.StatusBadRequest, "query_syntax")
+		var envelope struct {
+			Error handlers.APIError `json:"error"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &envelope)
+		if envelope.Error.Position != position {
+			t.Errorf("Expected %s to fail at position %d, got %s", q, position, rr.Body.String())
+		}
+	}
+	expect(t, c.do(t, "GET", "/books/search?q=a&query=b", ""), http.StatusBadRequest, "invalid_query")
+	expect(t, c.do(t, "GET", "/books/search?query=a&mode=regex", ""), http.StatusBadRequest, "invalid_query")
+}
+
+// revisions decodes a list of revisions and returns their actions
+func revisions(t *testing.T, rr *httptest.ResponseRecorder) ([]models.Revision, []string) {
+	t.Helper()
+
+	var revs []models.Revision
+	if err := json.Unmarshal(rr.Body.Bytes(), &revs); err != nil || revs == nil {
+		t.Fatalf("Expected a JSON array of revisions, got %s", rr.Body.String())
+	}
+
+	actions := make([]string, len(revs))
+	for i, r := range revs {
+		actions[i] = r.Action
+	}
+	return revs, actions
+}
+
+func (c *conformanceClient) history(t *testing.T) {
+	since := time.Now().UTC().Add(-time.Second).Format(time.RFC3339)
+	book := c.create(t, `{"title": "Revisited", "price": 10, "pages": 100, "quantity": 5}`)
+	path := "/books/" + book.ID.Hex()
+
+	expect(t, c.do(t, "PUT", path, `{"title": "Revisited", "price": 12, "pages": 100, "quantity": 5}`, "X-Actor", "alice", "X-Request-ID", "req-history"), http.StatusOK, "")
+	expect(t, c.do(t, "PATCH", path, `{"pages": 120, "quantity": 7}`, "X-Actor", "bob"), http.StatusOK, "")
+
+	rr := c.do(t, "GET", path+"/history", "")
+	expect(t, rr, http.StatusOK, "")
+	history, actions := revisions(t, rr)
+	if !reflect.DeepEqual(actions, []string{"create", "update", "update"}) {
+		t.Fatalf("Expected create and two updates, got %s", rr.Body.String())
+	}
+	update := history[1]
+	if update.Actor != "alice" || update.RequestID != "req-history" || update.Version != 2 ||
+		!reflect.DeepEqual(update.Changes, []models.FieldChange{{Field: "price", Before: 10.0, After: 12.0}}) {
+		t.Errorf("Expected the price change by alice, got %+v", update)
+	}
+	if history[0].Actor != "anonymous" || history[2].Actor != "bob" {
+		t.Errorf("Expected anonymous and bob, got %q and %q", history[0].Actor, history[2].Actor)
+	}
+
+	// Restoring the first revision brings back its fields as a new version,
+	// but not its stock
+	restore := path + "/history/" + history[0].ID.Hex() + "/restore"
+	expect(t, c.do(t, "POST", restore, "", "If-Match", `"1"`), http.StatusPreconditionFailed, "precondition_failed")
+	rr = c.do(t, "POST", restore, "", "X-Actor", "carol")
+	expect(t, rr, http.StatusOK, "")
+	if restored := decodeBook(t, rr); restored.Price != 10 || restored.Pages != 100 || restored.Quantity != 7 || restored.Version != 4 || rr.Header().Get("ETag") != `"4"` {
+		t.Errorf("Expected the first revision's fields with the current stock at version 4, got %s", rr.Body.String())
+	}
+
+	// A deleted book keeps its history and can be recreated
+	expect(t, c.do(t, "DELETE", path, ""), http.StatusNoContent, "")
+	history, actions = revisions(t, c.do(t, "GET", path+"/history", ""))
+	if !reflect.DeepEqual(actions, []string{"create", "update", "update", "restore", "delete"}) || history[3].RestoredFrom != history[0].ID.Hex() || history[3].Actor != "carol" {
+		t.Fatalf("Expected the restore and delete to be recorded, got %+v", history)
+	}
+	expect(t, c.do(t, "POST", path+"/history/"+history[4].ID.Hex()+"/restore", ""), http.StatusConflict, "not_restorable")
+	rr = c.do(t, "POST", path+"/history/"+history[2].ID.Hex()+"/restore", "")
+	expect(t, rr, http.StatusCreated, "")
+	if restored := decodeBook(t, rr); restored.ID != book.ID || restored.Price != 12 || restored.Pages != 120 || restored.Quantity != 0 || restored.Version != 5 {
+		t.Errorf("Expected the book recreated as of the third revision at version 5 with no stock, got %s", rr.Body.String())
+	}
+	expect(t, c.do(t, "GET", path, ""), http.StatusOK, "")
+
+	// The audit log covers every book, oldest first
+	other := c.create(t, `{"title": "Another Audited Book"}`)
+	rr = c.do(t, "GET", "/audit?since="+url.QueryEscape(since)+"&limit=1000", "")
+	expect(t, rr, http.StatusOK, "")
+	log, actions := revisions(t, rr)
+	n := len(log)
+	if n < 7 || log[n-1].BookID != other.ID || log[n-2].BookID != book.ID || actions[n-2] != "restore" {
+		t.Errorf("Expected the recreation then the new book last, got %v", actions)
+	}
+	for i := 1; i < n; i++ {
+		if log[i].CreatedAt.Before(log[i-1].CreatedAt) {
+			t.Errorf("Expected the audit log oldest first, got %v before %v", log[i-1].CreatedAt, log[i].CreatedAt)
+		}
+	}
+	if _, actions := revisions(t, c.do(t, "GET", "/audit?since="+url.QueryEscape(since)+"&limit=2", "")); len(actions) != 2 {
+		t.Errorf("Expected the limit to apply, got %v", actions)
+	}
+
+	expect(t, c.do(t, "GET", "/audit?since=yesterday", ""), http.StatusBadRequest, "invalid_query")
+	expect(t, c.do(t, "GET", "/audit?limit=5000", ""), http.StatusBadRequest, "invalid_query")
+	expect(t, c.do(t, "GET", "/books/"+primitive.NewObjectID().Hex()+"/history", ""), http.StatusNotFound, "book_not_found")
+	expect(t, c.do(t, "POST", path+"/history/"+primitive.NewObjectID().Hex()+"/restore", ""), http.StatusNotFound, "revision_not_found")
+	expect(t, c.do(t, "POST", "/books/"+other.ID.Hex()+"/history/"+history[0].ID.Hex()+"/restore", ""), http.StatusNotFound, "revision_not_found")
+	expect(t, c.do(t, "POST", path+"/history/not-an-id/restore", ""), http.StatusBadRequest, "invalid_id")
+}
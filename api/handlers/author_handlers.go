@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/harshakumara/book-api/models"
+	"github.com/harshakumara/book-api/storage"
+)
+
+// AuthorHandler serves the /authors endpoints
+type AuthorHandler struct {
+	authors      storage.AuthorStore
+	books        storage.BookStore
+	deletePolicy storage.DeletePolicy
+}
+
+// NewAuthorHandler creates an AuthorHandler.
+// deletePolicy decides what happens to an author's books when the author is deleted.
+func NewAuthorHandler(authors storage.AuthorStore, books storage.BookStore, deletePolicy storage.DeletePolicy) *AuthorHandler {
+	return &AuthorHandler{authors: authors, books: books, deletePolicy: deletePolicy}
+}
+
+// GetAuthors returns all authors
+func (h *AuthorHandler) GetAuthors(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	authors, err := h.authors.List(r.Context())
+	if err != nil {
+		writeStoreError(w, r, "Author", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(authors)
+}
+
+// GetAuthor returns a single author by ID
+func (h *AuthorHandler) GetAuthor(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id := mux.Vars(r)["id"]
+
+	author, err := h.authors.Get(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, r, "Author", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(author)
+}
+
+// CreateAuthor creates a new author
+func (h *AuthorHandler) CreateAuthor(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var author models.Author
+	if err := json.NewDecoder(r.Body).Decode(&author); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+
+	author, err := h.authors.Create(r.Context(), author)
+	if err != nil {
+		writeStoreError(w, r, "Author", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(author)
+}
+
+// UpdateAuthor updates an author by ID
+func (h *AuthorHandler) UpdateAuthor(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id := mux.Vars(r)["id"]
+
+	var author models.Author
+	if err := json.NewDecoder(r.Body).Decode(&author); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+
+	author, err := h.authors.Update(r.Context(), id, author)
+	if err != nil {
+		writeStoreError(w, r, "Author", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(author)
+}
+
+// DeleteAuthor deletes an author by ID using the configured delete policy
+func (h *AuthorHandler) DeleteAuthor(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id := mux.Vars(r)["id"]
+
+	if err := h.authors.Delete(r.Context(), id, h.deletePolicy); err != nil {
+		writeStoreError(w, r, "Author", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetAuthorBooks returns the author's books with the same paging, sorting and
+// filtering options as GET /books
+func (h *AuthorHandler) GetAuthorBooks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id := mux.Vars(r)["id"]
+
+	if _, err := h.authors.Get(r.Context(), id); err != nil {
+		writeStoreError(w, r, "Author", err)
+		return
+	}
+
+	query, err := parseBookQuery(r.URL.Query())
+	if err != nil {
+		writeStoreError(w, r, "Book", err)
+		return
+	}
+	query.Filter.AuthorID = id
+
+	page, err := h.books.List(r.Context(), query)
+	if err != nil {
+		writeStoreError(w, r, "Book", err)
+		return
+	}
+
+	writeBookPage(w, r, page, page.Books)
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/harshakumara/book-api/config"
+	"github.com/harshakumara/book-api/models"
+	"github.com/harshakumara/book-api/storage"
+)
+
+func TestAuthorBooksAndDeletePolicy(t *testing.T) {
+	fs := config.NewFileStorage(filepath.Join(t.TempDir(), "test_books.json"))
+	books := storage.NewFileBookStore(fs)
+	authors := storage.NewFileAuthorStore(fs)
+	h := NewAuthorHandler(authors, books, storage.DeleteReject)
+
+	ctx := context.Background()
+	author, _ := authors.Create(ctx, models.Author{Name: "Test Author"})
+	_, _ = books.Create(ctx, models.Book{Title: "By author", AuthorID: author.ID})
+	_, _ = books.Create(ctx, models.Book{Title: "Anonymous"})
+
+	r := mux.NewRouter()
+	r.HandleFunc("/authors/{id}", h.DeleteAuthor).Methods("DELETE")
+	r.HandleFunc("/authors/{id}/books", h.GetAuthorBooks).Methods("GET")
+
+	req, _ := http.NewRequest("GET", "/authors/"+author.ID+"/books", nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	var responseBooks []models.Book
+	json.Unmarshal(rr.Body.Bytes(), &responseBooks)
+	if rr.Code != http.StatusOK || len(responseBooks) != 1 || responseBooks[0].Title != "By author" {
+		t.Errorf("Expected only the author's book, got %v %s", rr.Code, rr.Body.String())
+	}
+
+	req, _ = http.NewRequest("GET", "/authors/missing/books", nil)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown author, got %v", rr.Code)
+	}
+
+	req, _ = http.NewRequest("DELETE", "/authors/"+author.ID, nil)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Expected 409 deleting an author with books, got %v", rr.Code)
+	}
+}
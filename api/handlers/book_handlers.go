@@ -1,368 +1,448 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"strconv"
 	"strings"
-	"sync"
-	"time"
 
 	"github.com/gorilla/mux"
-	"github.com/harshakumara/book-api/config"
 	"github.com/harshakumara/book-api/models"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
+	"github.com/harshakumara/book-api/patch"
+	"github.com/harshakumara/book-api/storage"
 )
 
-// GetBooks returns all books
-func GetBooks(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	// Check if using MongoDB or file storage
-	if config.MongoClient != nil {
-		// MongoDB implementation
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-
-		cursor, err := config.BookCollection.Find(ctx, bson.M{})
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		defer cursor.Close(ctx)
+// BookHandler serves the /books endpoints on top of a BookStore
+type BookHandler struct {
+	store          storage.BookStore
+	requireIfMatch bool
+}
 
-		var books []models.Book
-		if err = cursor.All(ctx, &books); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
+// NewBookHandler creates a BookHandler using the given store.
+// With requireIfMatch set, PUT, PATCH and DELETE are refused unless they
+// carry an If-Match header.
+func NewBookHandler(store storage.BookStore, requireIfMatch bool) *BookHandler {
+	return &BookHandler{store: store, requireIfMatch: requireIfMatch}
+}
 
-		json.NewEncoder(w).Encode(books)
-	} else {
-		// File storage implementation
-		fs := config.NewFileStorage("books.json")
-		books, err := fs.ReadBooks()
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
+// BookListResponse is the body of GET /books when facets are requested
+type BookListResponse struct {
+	Books  []models.Book  `json:"books"`
+	Facets storage.Facets `json:"facets"`
+}
 
-		json.NewEncoder(w).Encode(books)
-	}
+// SearchResponse is the body of a search when facets or fuzziness are
+// requested. Suggestions holds corrections of misspelled query words.
+type SearchResponse struct {
+	Books       []storage.SearchHit `json:"books"`
+	Facets      storage.Facets      `json:"facets,omitempty"`
+	Suggestions []string            `json:"suggestions,omitempty"`
 }
 
-// GetBook returns a single book by ID
-func GetBook(w http.ResponseWriter, r *http.Request) {
+// GetBooks returns a page of books.
+// The body stays a plain array; the total match count and the cursor for the
+// next page are returned in the X-Total-Count and X-Next-Cursor headers.
+// With ?facets the body becomes a BookListResponse whose facets count every
+// matching book, not just the page.
+func (h *BookHandler) GetBooks(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	params := mux.Vars(r)
-	id := params["id"]
 
-	if config.MongoClient != nil {
-		// MongoDB implementation
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
+	query, err := parseBookQuery(r.URL.Query())
+	if err != nil {
+		writeStoreError(w, r, "Book", err)
+		return
+	}
 
-		objID, err := primitive.ObjectIDFromHex(id)
-		if err != nil {
-			http.Error(w, "Invalid ID format", http.StatusBadRequest)
-			return
-		}
+	facetNames, err := parseFacets(r.URL.Query())
+	if err != nil {
+		writeStoreError(w, r, "Book", err)
+		return
+	}
 
-		var book models.Book
-		err = config.BookCollection.FindOne(ctx, bson.M{"_id": objID}).Decode(&book)
-		if err != nil {
-			http.Error(w, "Book not found", http.StatusNotFound)
-			return
-		}
+	page, err := h.store.List(r.Context(), query)
+	if err != nil {
+		writeStoreError(w, r, "Book", err)
+		return
+	}
+	if facetNames == nil {
+		writeBookPage(w, r, page, page.Books)
+		return
+	}
 
-		json.NewEncoder(w).Encode(book)
-	} else {
-		// File storage implementation
-		fs := config.NewFileStorage("books.json")
-		books, err := fs.ReadBooks()
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
+	facets, err := h.store.Facets(r.Context(), query.Filter, facetNames)
+	if err != nil {
+		writeStoreError(w, r, "Book", err)
+		return
+	}
+	writeBookPage(w, r, page, BookListResponse{Books: page.Books, Facets: facets})
+}
 
-		var book models.Book
-		found := false
-		for _, b := range books {
-			if b.ID.Hex() == id {
-				book = b
-				found = true
-				break
-			}
-		}
+// GetBook returns a single book by ID, with its version as the ETag
+func (h *BookHandler) GetBook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id := mux.Vars(r)["id"]
 
-		if !found {
-			http.Error(w, "Book not found", http.StatusNotFound)
-			return
-		}
+	book, err := h.store.Get(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, r, "Book", err)
+		return
+	}
 
-		json.NewEncoder(w).Encode(book)
+	etag := bookETag(book)
+	w.Header().Set("ETag", etag)
+	if notModified(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
 	}
+
+	json.NewEncoder(w).Encode(book)
 }
 
 // CreateBook creates a new book
-func CreateBook(w http.ResponseWriter, r *http.Request) {
+func (h *BookHandler) CreateBook(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	var book models.Book
 	if err := json.NewDecoder(r.Body).Decode(&book); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeBodyError(w, r, err)
 		return
 	}
 
-	// Generate new ID if not provided
-	if book.ID.IsZero() {
-		book.ID = primitive.NewObjectID()
+	book.Normalize()
+	if err := book.Validate(); err != nil {
+		writeStoreError(w, r, "Book", err)
+		return
 	}
 
-	if config.MongoClient != nil {
-		// MongoDB implementation
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-
-		_, err := config.BookCollection.InsertOne(ctx, book)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-	} else {
-		// File storage implementation
-		fs := config.NewFileStorage("books.json")
-		books, err := fs.ReadBooks()
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		books = append(books, book)
-		if err := fs.WriteBooks(books); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
+	// A new book always starts at version 1
+	book.Version = 0
+	book, err := h.store.Create(r.Context(), book)
+	if err != nil {
+		writeStoreError(w, r, "Book", err)
+		return
 	}
 
+	w.Header().Set("ETag", bookETag(book))
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(book)
 }
 
-// UpdateBook updates a book by ID
-func UpdateBook(w http.ResponseWriter, r *http.Request) {
+// UpdateBook updates a book by ID, if it still matches If-Match
+func (h *BookHandler) UpdateBook(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	params := mux.Vars(r)
-	id := params["id"]
+	id := mux.Vars(r)["id"]
+
+	version, apiErr := ifMatchVersion(r, h.requireIfMatch)
+	if apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
 
 	var updatedBook models.Book
 	if err := json.NewDecoder(r.Body).Decode(&updatedBook); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeBodyError(w, r, err)
 		return
 	}
 
-	if config.MongoClient != nil {
-		// MongoDB implementation
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
+	updatedBook.Normalize()
+	if err := updatedBook.Validate(); err != nil {
+		writeStoreError(w, r, "Book", err)
+		return
+	}
 
-		objID, err := primitive.ObjectIDFromHex(id)
-		if err != nil {
-			http.Error(w, "Invalid ID format", http.StatusBadRequest)
-			return
-		}
+	updatedBook, err := h.store.Update(r.Context(), id, updatedBook, version)
+	if err != nil {
+		writeStoreError(w, r, "Book", err)
+		return
+	}
+
+	w.Header().Set("ETag", bookETag(updatedBook))
+	json.NewEncoder(w).Encode(updatedBook)
+}
+
+// PatchBook partially updates a book by ID.
+// The body is an RFC 7396 merge patch (application/merge-patch+json, or
+// application/json) or an RFC 6902 JSON Patch (application/json-patch+json).
+// The patched book is validated like a PUT before it is stored.
+func (h *BookHandler) PatchBook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id := mux.Vars(r)["id"]
+
+	version, apiErr := ifMatchVersion(r, h.requireIfMatch)
+	if apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
 
-		// Preserve the original ID
-		updatedBook.ID = objID
+	var applyPatch func(doc, patch []byte) ([]byte, error)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case patch.MergePatchType, "application/json":
+		applyPatch = patch.MergePatch
+	case patch.JSONPatchType:
+		applyPatch = patch.JSONPatch
+	default:
+		w.Header().Set("Accept-Patch", patch.MergePatchType+", "+patch.JSONPatchType)
+		writeError(w, r, &APIError{
+			Status:  http.StatusUnsupportedMediaType,
+			Code:    CodeUnsupportedMediaType,
+			Message: "Content-Type must be " + patch.MergePatchType + " or " + patch.JSONPatchType,
+		})
+		return
+	}
 
-		_, err = config.BookCollection.ReplaceOne(ctx, bson.M{"_id": objID}, updatedBook)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+
+	book, err := h.store.Patch(r.Context(), id, version, func(current models.Book) (models.Book, error) {
+		doc, err := json.Marshal(current)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+			return models.Book{}, err
 		}
-	} else {
-		// File storage implementation
-		fs := config.NewFileStorage("books.json")
-		books, err := fs.ReadBooks()
+		patched, err := applyPatch(doc, body)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+			return models.Book{}, err
 		}
 
-		found := false
-		for i, b := range books {
-			if b.ID.Hex() == id {
-				// Preserve the original ID
-				updatedBook.ID = b.ID
-				books[i] = updatedBook
-				found = true
-				break
+		var book models.Book
+		if err := json.Unmarshal(patched, &book); err != nil {
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) {
+				return models.Book{}, &models.ValidationError{Errors: []models.FieldError{{Field: typeErr.Field, Message: "must be a " + typeErr.Type.String()}}}
 			}
+			return models.Book{}, fmt.Errorf("%w: %v", patch.ErrFailed, err)
 		}
 
-		if !found {
-			http.Error(w, "Book not found", http.StatusNotFound)
-			return
-		}
-
-		if err := fs.WriteBooks(books); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
+		book.Normalize()
+		return book, book.Validate()
+	})
+	if err != nil {
+		writeStoreError(w, r, "Book", err)
+		return
 	}
 
-	json.NewEncoder(w).Encode(updatedBook)
+	w.Header().Set("ETag", bookETag(book))
+	json.NewEncoder(w).Encode(book)
 }
 
-// DeleteBook deletes a book by ID
-func DeleteBook(w http.ResponseWriter, r *http.Request) {
+// DeleteBook deletes a book by ID, if it still matches If-Match
+func (h *BookHandler) DeleteBook(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	params := mux.Vars(r)
-	id := params["id"]
+	id := mux.Vars(r)["id"]
 
-	if config.MongoClient != nil {
-		// MongoDB implementation
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
+	version, apiErr := ifMatchVersion(r, h.requireIfMatch)
+	if apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
 
-		objID, err := primitive.ObjectIDFromHex(id)
-		if err != nil {
-			http.Error(w, "Invalid ID format", http.StatusBadRequest)
-			return
-		}
+	if err := h.store.Delete(r.Context(), id, version); err != nil {
+		writeStoreError(w, r, "Book", err)
+		return
+	}
 
-		_, err = config.BookCollection.DeleteOne(ctx, bson.M{"_id": objID})
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-	} else {
-		// File storage implementation
-		fs := config.NewFileStorage("books.json")
-		books, err := fs.ReadBooks()
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
+	w.WriteHeader(http.StatusNoContent)
+}
 
-		found := false
-		filteredBooks := []models.Book{}
-		for _, b := range books {
-			if b.ID.Hex() != id {
-				filteredBooks = append(filteredBooks, b)
-			} else {
-				found = true
-			}
-		}
+// GetBookByISBN returns the book with the given ISBN-10 or ISBN-13, with its
+// version as the ETag
+func (h *BookHandler) GetBookByISBN(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
 
-		if !found {
-			http.Error(w, "Book not found", http.StatusNotFound)
-			return
-		}
+	isbn := models.NormalizeISBN(mux.Vars(r)["isbn"])
+	if !models.ValidISBN(isbn) {
+		writeError(w, r, &APIError{Status: http.StatusBadRequest, Code: CodeInvalidID, Message: "Invalid ISBN"})
+		return
+	}
 
-		if err := fs.WriteBooks(filteredBooks); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
+	books, err := h.store.FindByISBN(r.Context(), []string{isbn})
+	if err != nil {
+		writeStoreError(w, r, "Book", err)
+		return
+	}
+	if len(books) == 0 {
+		writeStoreError(w, r, "Book", storage.ErrNotFound)
+		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	etag := bookETag(books[0])
+	w.Header().Set("ETag", etag)
+	if notModified(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	json.NewEncoder(w).Encode(books[0])
 }
 
-// SearchBooks searches for books based on title and description
-func SearchBooks(w http.ResponseWriter, r *http.Request) {
+// SearchBooks searches titles, descriptions, genres and author names.
+// Stores that rank results return them best first with a relevance score;
+// others return substring matches of the title or description with a
+// score of 0. ?fuzziness tolerates typos in ranked searches. With
+// ?mode=regex, q is a regular expression matched against titles and
+// descriptions instead. ?query takes a structured query such as
+// genre:Fantasy price:<20 "young wizard" in place of q; its results are
+// ordered by title. The GET /books filters narrow the results, ?facets
+// adds facet counts and ?limit caps the number of results.
+func (h *BookHandler) SearchBooks(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	// Get the search keyword from query parameters
-	keyword := r.URL.Query().Get("q")
-	if keyword == "" {
-		http.Error(w, "Search keyword is required", http.StatusBadRequest)
+	params, err := parseSearchParams(r.URL.Query())
+	if err != nil {
+		writeStoreError(w, r, "Book", err)
 		return
 	}
 
-	keyword = strings.ToLower(keyword)
-
-	var allBooks []models.Book
-	var err error
+	hits, suggestions, err := h.search(r, params)
+	if err != nil {
+		writeStoreError(w, r, "Book", err)
+		return
+	}
 
-	if config.MongoClient != nil {
-		// MongoDB implementation
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-
-		// Using $or to search in both title and description with case-insensitive search
-		filter := bson.M{
-			"$or": []bson.M{
-				{"title": bson.M{"$regex": keyword, "$options": "i"}},
-				{"description": bson.M{"$regex": keyword, "$options": "i"}},
-			},
+	narrowed := hits[:0]
+	for _, hit := range hits {
+		if params.filter.Matches(hit.Book) {
+			narrowed = append(narrowed, hit)
 		}
+	}
+	hits = narrowed
 
-		cursor, err := config.BookCollection.Find(ctx, filter)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+	var facets storage.Facets
+	if params.facetNames != nil {
+		books := make([]models.Book, len(hits))
+		for i, hit := range hits {
+			books[i] = hit.Book
 		}
-		defer cursor.Close(ctx)
+		facets = storage.CountFacets(books, params.facetNames)
+	}
+	if params.limit > 0 && len(hits) > params.limit {
+		hits = hits[:params.limit]
+	}
 
-		if err = cursor.All(ctx, &allBooks); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+	if params.facetNames != nil || params.fuzziness != nil {
+		json.NewEncoder(w).Encode(SearchResponse{Books: hits, Facets: facets, Suggestions: suggestions})
+		return
+	}
+	json.NewEncoder(w).Encode(hits)
+}
+
+// search runs a search in the mode the request asks for. Ranked searches
+// are capped at the limit here unless the results still need filtering or
+// facet counts.
+func (h *BookHandler) search(r *http.Request, params searchParams) ([]storage.SearchHit, []string, error) {
+	var books []models.Book
+	var err error
+
+	switch {
+	case params.query != nil:
+		books, err = h.store.SearchStructured(r.Context(), params.query)
+	case params.mode == "":
+		if searcher, ok := h.store.(storage.RankedSearcher); ok {
+			limit := params.limit
+			if params.filter != (storage.BookFilter{}) || params.facetNames != nil {
+				limit = 0
+			}
+			if params.fuzziness != nil && *params.fuzziness != 0 {
+				return searcher.SearchFuzzy(r.Context(), params.keyword, *params.fuzziness, limit)
+			}
+			hits, err := searcher.SearchRanked(r.Context(), params.keyword, limit)
+			return hits, nil, err
 		}
-	} else {
-		// File storage implementation
-		fs := config.NewFileStorage("books.json")
-		allBooks, err = fs.ReadBooks()
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+		books, err = h.store.Search(r.Context(), params.keyword)
+	case params.mode == "regex":
+		var pattern *storage.Pattern
+		if pattern, err = storage.CompilePattern(params.keyword); err == nil {
+			books, err = h.store.SearchPattern(r.Context(), pattern)
 		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
 
-		// Use goroutines and channels for concurrent search
-		results := make(chan models.Book)
-		var wg sync.WaitGroup
+	hits := make([]storage.SearchHit, len(books))
+	for i, b := range books {
+		hits[i] = storage.SearchHit{Book: b}
+	}
+	return hits, nil, nil
+}
 
-		// Divide the books into chunks for parallel processing
-		chunkSize := 10 // Adjust based on expected dataset size
-		if len(allBooks) < chunkSize {
-			chunkSize = 1
-		}
+// Bounds of ?limit on autocomplete
+const (
+	defaultCompletions = 10
+	maxCompletions     = 50
+)
+
+// AutocompleteBooks returns the titles, author names and ISBNs that
+// complete ?prefix, for search-as-you-type. ?limit caps the number of
+// completions (default 10, at most 50).
+func (h *BookHandler) AutocompleteBooks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
 
-		chunks := (len(allBooks) + chunkSize - 1) / chunkSize
+	prefix := strings.TrimSpace(r.URL.Query().Get("prefix"))
+	if prefix == "" {
+		writeError(w, r, &APIError{Status: http.StatusBadRequest, Code: CodeInvalidQuery, Message: "Prefix is required"})
+		return
+	}
 
-		// Launch goroutines to search each chunk
-		for i := 0; i < chunks; i++ {
-			wg.Add(1)
-			go func(start, end int) {
-				defer wg.Done()
+	limit, err := intParam(r.URL.Query(), "limit")
+	if err == nil && (limit < 0 || limit > maxCompletions) {
+		err = fmt.Errorf("%w: limit must be between 0 and %d", storage.ErrInvalidQuery, maxCompletions)
+	}
+	if err != nil {
+		writeStoreError(w, r, "Book", err)
+		return
+	}
+	if limit == 0 {
+		limit = defaultCompletions
+	}
 
-				for j := start; j < end && j < len(allBooks); j++ {
-					book := allBooks[j]
-					title := strings.ToLower(book.Title)
-					description := strings.ToLower(book.Description)
+	completer, ok := h.store.(storage.Autocompleter)
+	if !ok {
+		writeError(w, r, &APIError{Status: http.StatusNotImplemented, Code: CodeNotImplemented, Message: "Autocomplete needs the search index"})
+		return
+	}
 
-					if strings.Contains(title, keyword) || strings.Contains(description, keyword) {
-						results <- book
-					}
-				}
-			}(i*chunkSize, (i+1)*chunkSize)
-		}
+	completions, err := completer.Autocomplete(r.Context(), prefix, limit)
+	if err != nil {
+		writeStoreError(w, r, "Book", err)
+		return
+	}
+	json.NewEncoder(w).Encode(completions)
+}
 
-		// Close the channel once all goroutines are done
-		go func() {
-			wg.Wait()
-			close(results)
-		}()
+// writeBookPage writes the body for a page of books with the page's
+// pagination headers and an ETag of the body, answering 304 if it matches
+// If-None-Match
+func writeBookPage(w http.ResponseWriter, r *http.Request, page storage.BookPage, v interface{}) {
+	w.Header().Set("X-Total-Count", strconv.FormatInt(page.Total, 10))
+	if page.NextCursor != "" {
+		w.Header().Set("X-Next-Cursor", page.NextCursor)
+
+		next := r.URL.Query()
+		next.Del("offset")
+		next.Set("cursor", page.NextCursor)
+		w.Header().Set("Link", fmt.Sprintf("<%s?%s>; rel=\"next\"", r.URL.Path, next.Encode()))
+	}
 
-		// Collect results
-		var searchResults []models.Book
-		for book := range results {
-			searchResults = append(searchResults, book)
-		}
+	body, err := json.Marshal(v)
+	if err != nil {
+		writeStoreError(w, r, "Book", err)
+		return
+	}
 
-		allBooks = searchResults
+	etag := bodyETag(body)
+	w.Header().Set("ETag", etag)
+	if notModified(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
 	}
 
-	json.NewEncoder(w).Encode(allBooks)
+	w.Write(append(body, '\n'))
 }
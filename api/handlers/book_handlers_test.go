@@ -2,20 +2,25 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/gorilla/mux"
 	"github.com/harshakumara/book-api/config"
 	"github.com/harshakumara/book-api/models"
+	"github.com/harshakumara/book-api/storage"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 func TestGetBooks(t *testing.T) {
 	// Initialize file storage with test data
-	fs := config.NewFileStorage("../../test_books.json")
+	fs := config.NewFileStorage(filepath.Join(t.TempDir(), "test_books.json"))
+	h := NewBookHandler(storage.NewFileBookStore(fs), false)
 
 	// Create sample books
 	book1 := models.Book{
@@ -60,7 +65,7 @@ func TestGetBooks(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	// Call the handler
-	handler := http.HandlerFunc(GetBooks)
+	handler := http.HandlerFunc(h.GetBooks)
 	handler.ServeHTTP(rr, req)
 
 	// Check status code
@@ -82,18 +87,23 @@ func TestGetBooks(t *testing.T) {
 
 func TestCreateBook(t *testing.T) {
 	// Initialize file storage
-	fs := config.NewFileStorage("../../test_books.json")
+	fs := config.NewFileStorage(filepath.Join(t.TempDir(), "test_books.json"))
+	h := NewBookHandler(storage.NewFileBookStore(fs), false)
 
 	// Empty books array
 	_ = fs.WriteBooks([]models.Book{})
 
+	// The book must reference an existing author and publisher
+	_, _ = storage.NewFileAuthorStore(fs).Create(context.Background(), models.Author{ID: "author1", Name: "Test Author"})
+	_, _ = storage.NewFilePublisherStore(fs).Create(context.Background(), models.Publisher{ID: "publisher1", Name: "Test Publisher"})
+
 	// Create a test book
 	newBook := models.Book{
 		Title:           "New Test Book",
 		AuthorID:        "author1",
 		PublisherID:     "publisher1",
 		PublicationDate: "2023-03-01",
-		ISBN:            "1122334455",
+		ISBN:            "0306406152",
 		Pages:           150,
 		Genre:           "Test",
 		Description:     "New test description",
@@ -115,7 +125,7 @@ func TestCreateBook(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	// Call the handler
-	handler := http.HandlerFunc(CreateBook)
+	handler := http.HandlerFunc(h.CreateBook)
 	handler.ServeHTTP(rr, req)
 
 	// Check status code
@@ -133,13 +143,275 @@ func TestCreateBook(t *testing.T) {
 	_ = fs.WriteBooks([]models.Book{})
 }
 
-func setupRouter() *mux.Router {
+func TestGetBooksPagination(t *testing.T) {
+	fs := config.NewFileStorage(filepath.Join(t.TempDir(), "test_books.json"))
+	h := NewBookHandler(storage.NewFileBookStore(fs), false)
+
+	var testBooks []models.Book
+	for _, price := range []float64{30, 10, 20} {
+		testBooks = append(testBooks, models.Book{ID: primitive.NewObjectID(), Title: "Book", Genre: "Test", Price: price, Quantity: 1})
+	}
+	_ = fs.WriteBooks(testBooks)
+
+	req, _ := http.NewRequest("GET", "/books?sort=price&limit=2&genre=Test", nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(h.GetBooks).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if total := rr.Header().Get("X-Total-Count"); total != "3" {
+		t.Errorf("Expected X-Total-Count 3, got %q", total)
+	}
+	if rr.Header().Get("X-Next-Cursor") == "" {
+		t.Error("Expected X-Next-Cursor to be set")
+	}
+
+	var responseBooks []models.Book
+	json.Unmarshal(rr.Body.Bytes(), &responseBooks)
+	if len(responseBooks) != 2 || responseBooks[0].Price != 10 || responseBooks[1].Price != 20 {
+		t.Errorf("Expected the two cheapest books in order, got %+v", responseBooks)
+	}
+
+	req, _ = http.NewRequest("GET", "/books?sort=colour", nil)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(h.GetBooks).ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for unknown sort field, got %v", rr.Code)
+	}
+}
+
+func TestCreateBookValidation(t *testing.T) {
+	fs := config.NewFileStorage(filepath.Join(t.TempDir(), "test_books.json"))
+	h := NewBookHandler(storage.NewFileBookStore(fs), false)
+
+	body := []byte(`{"title": "", "isbn": "9780743273566", "pages": -1, "price": -2, "quantity": -3, "publicationDate": "yesterday"}`)
+	req, _ := http.NewRequest("POST", "/books", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(h.CreateBook).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusUnprocessableEntity)
+	}
+
+	var response errorEnvelope
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil || response.Error == nil {
+		t.Fatalf("Expected a JSON error body, got %q", rr.Body.String())
+	}
+	if response.Error.Code != CodeValidationFailed {
+		t.Errorf("Expected code %s, got %s", CodeValidationFailed, response.Error.Code)
+	}
+	if len(response.Error.Details) != 6 {
+		t.Errorf("Expected 6 field errors, got %+v", response.Error.Details)
+	}
+
+	books, _ := fs.ReadBooks()
+	if len(books) != 0 {
+		t.Errorf("Expected no book to be stored, got %d", len(books))
+	}
+}
+
+func TestPatchBook(t *testing.T) {
+	fs := config.NewFileStorage(filepath.Join(t.TempDir(), "test_books.json"))
+	store := storage.NewFileBookStore(fs)
+	h := NewBookHandler(store, false)
+
+	book, _ := store.Create(context.Background(), models.Book{Title: "Dune", ISBN: "9780306406157", Price: 20, Quantity: 4})
+
+	r := mux.NewRouter()
+	r.HandleFunc("/books/{id}", h.PatchBook).Methods("PATCH")
+
+	patch := func(contentType, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("PATCH", "/books/"+book.ID.Hex(), bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", contentType)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := patch("application/merge-patch+json", `{"price": 9.99}`)
+	var got models.Book
+	json.Unmarshal(rr.Body.Bytes(), &got)
+	if rr.Code != http.StatusOK || got.Price != 9.99 || got.Title != "Dune" || got.ISBN != "9780306406157" {
+		t.Errorf("Expected merge patch to change only the price, got %v %s", rr.Code, rr.Body.String())
+	}
+
+	rr = patch("application/json-patch+json", `[{"op":"test","path":"/price","value":9.99},{"op":"replace","path":"/quantity","value":7}]`)
+	json.Unmarshal(rr.Body.Bytes(), &got)
+	if rr.Code != http.StatusOK || got.Quantity != 7 || got.Price != 9.99 {
+		t.Errorf("Expected JSON patch to change the quantity, got %v %s", rr.Code, rr.Body.String())
+	}
+
+	tests := []struct {
+		contentType string
+		body        string
+		status      int
+		code        string
+	}{
+		{"application/merge-patch+json", `{"title": null}`, http.StatusUnprocessableEntity, CodeValidationFailed},
+		{"application/merge-patch+json", `{"pages": "many"}`, http.StatusUnprocessableEntity, CodeValidationFailed},
+		{"application/json-patch+json", `[{"op":"test","path":"/price","value":1}]`, http.StatusConflict, CodePatchConflict},
+		{"application/json-patch+json", `{"op":"replace"}`, http.StatusBadRequest, CodeInvalidPatch},
+		{"text/plain", `price=1`, http.StatusUnsupportedMediaType, CodeUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		rr := patch(tt.contentType, tt.body)
+		if e := decodeError(t, rr); rr.Code != tt.status || e.Code != tt.code {
+			t.Errorf("%s %s: expected %d %s, got %d %s", tt.contentType, tt.body, tt.status, tt.code, rr.Code, rr.Body.String())
+		}
+	}
+
+	stored, _ := store.Get(context.Background(), book.ID.Hex())
+	if stored.Title != "Dune" || stored.Quantity != 7 {
+		t.Errorf("Expected rejected patches to leave the book unchanged, got %+v", stored)
+	}
+}
+
+func TestBookETags(t *testing.T) {
+	fs := config.NewFileStorage(filepath.Join(t.TempDir(), "test_books.json"))
+	store := storage.NewFileBookStore(fs)
+	h := NewBookHandler(store, true)
+
+	book, _ := store.Create(context.Background(), models.Book{Title: "Emma", Quantity: 1})
+
+	r := mux.NewRouter()
+	r.HandleFunc("/books", h.GetBooks).Methods("GET")
+	r.HandleFunc("/books/{id}", h.GetBook).Methods("GET")
+	r.HandleFunc("/books/{id}", h.UpdateBook).Methods("PUT")
+	r.HandleFunc("/books/{id}", h.DeleteBook).Methods("DELETE")
+
+	do := func(method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+	path := "/books/" + book.ID.Hex()
+
+	rr := do("GET", path, "", nil)
+	etag := rr.Header().Get("ETag")
+	if etag != `"1"` {
+		t.Fatalf("Expected ETag \"1\", got %q", etag)
+	}
+	if rr := do("GET", path, "", map[string]string{"If-None-Match": etag}); rr.Code != http.StatusNotModified || rr.Body.Len() != 0 {
+		t.Errorf("Expected 304 with no body, got %v %q", rr.Code, rr.Body.String())
+	}
+
+	list := do("GET", "/books", "", nil)
+	if rr := do("GET", "/books", "", map[string]string{"If-None-Match": list.Header().Get("ETag")}); rr.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for an unchanged list, got %v", rr.Code)
+	}
+
+	if rr := do("PUT", path, `{"title": "Emma"}`, nil); rr.Code != http.StatusPreconditionRequired {
+		t.Errorf("Expected 428 without If-Match, got %v", rr.Code)
+	}
+
+	rr = do("PUT", path, `{"title": "Emma (2nd ed.)"}`, map[string]string{"If-Match": etag})
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") != `"2"` {
+		t.Fatalf("Expected update with ETag \"2\", got %v %q", rr.Code, rr.Header().Get("ETag"))
+	}
+
+	// The first editor's ETag is now stale
+	rr = do("PUT", path, `{"title": "Emma (lost update)"}`, map[string]string{"If-Match": etag})
+	if e := decodeError(t, rr); rr.Code != http.StatusPreconditionFailed || e.Code != CodePreconditionFailed {
+		t.Errorf("Expected 412 precondition_failed, got %v %s", rr.Code, rr.Body.String())
+	}
+	if rr := do("DELETE", path, "", map[string]string{"If-Match": etag}); rr.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected 412 deleting with a stale ETag, got %v", rr.Code)
+	}
+	if rr := do("GET", "/books", "", map[string]string{"If-None-Match": list.Header().Get("ETag")}); rr.Code != http.StatusOK {
+		t.Errorf("Expected the list ETag to change after an update, got %v", rr.Code)
+	}
+
+	if rr := do("DELETE", path, "", map[string]string{"If-Match": `"2"`}); rr.Code != http.StatusNoContent {
+		t.Errorf("Expected delete with the current ETag to succeed, got %v", rr.Code)
+	}
+}
+
+func TestBulkRequiresVersions(t *testing.T) {
+	fs := config.NewFileStorage(filepath.Join(t.TempDir(), "test_books.json"))
+	store := storage.NewFileBookStore(fs)
+	h := NewBookHandler(store, true)
+
+	book, _ := store.Create(context.Background(), models.Book{Title: "Emma", Genre: "Novel"})
+	id := book.ID.Hex()
+
 	r := mux.NewRouter()
-	r.HandleFunc("/books", GetBooks).Methods("GET")
-	r.HandleFunc("/books", CreateBook).Methods("POST")
-	r.HandleFunc("/books/{id}", GetBook).Methods("GET")
-	r.HandleFunc("/books/{id}", UpdateBook).Methods("PUT")
-	r.HandleFunc("/books/{id}", DeleteBook).Methods("DELETE")
-	r.HandleFunc("/books/search", SearchBooks).Methods("GET")
-	return r
+	r.HandleFunc("/books/bulk", h.BulkUpsertBooks).Methods("POST")
+	r.HandleFunc("/books/bulk/delete", h.BulkDeleteBooks).Methods("POST")
+
+	do := func(path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", path, bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Without a version a book with an ID is only created, so it cannot
+	// replace one and bypass If-Match
+	fresh := primitive.NewObjectID().Hex()
+	rr := do("/books/bulk?mode=best-effort", `[{"bookId": "`+id+`", "title": "Emma (lost update)"}, {"title": "New"}, {"bookId": "`+fresh+`", "title": "Chosen ID"}]`)
+	var resp BulkUpsertResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if rr.Code != http.StatusMultiStatus || resp.Results[0].Error == nil || resp.Results[0].Error.Code != "book_exists" || resp.Created != 2 {
+		t.Errorf("Expected the unversioned replacement to be refused and the creates to succeed, got %v %s", rr.Code, rr.Body.String())
+	}
+	if resp.Results[2].BookID != fresh || resp.Results[2].Version != 1 {
+		t.Errorf("Expected the book created with its ID at version 1, got %+v", resp.Results[2])
+	}
+	if got, _ := store.Get(context.Background(), id); got.Title != "Emma" {
+		t.Errorf("Expected the existing book untouched, got %q", got.Title)
+	}
+	if rr := do("/books/bulk", `[{"bookId": "`+id+`", "version": 1, "title": "Emma (2nd ed.)"}]`); rr.Code != http.StatusOK {
+		t.Errorf("Expected a versioned replacement to succeed, got %v %s", rr.Code, rr.Body.String())
+	}
+
+	if rr := do("/books/bulk/delete", `{"filter": {"genre": "Novel"}}`); rr.Code != http.StatusPreconditionRequired {
+		t.Errorf("Expected deleting by filter to be refused, got %v", rr.Code)
+	}
+	if rr := do("/books/bulk/delete", `{"ids": ["`+id+`"]}`); rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), CodePreconditionRequired) {
+		t.Errorf("Expected an ID without a version to be refused, got %v %s", rr.Code, rr.Body.String())
+	}
+	if rr := do("/books/bulk/delete", `{"ids": [{"bookId": "`+id+`", "version": 2}]}`); rr.Code != http.StatusOK {
+		t.Errorf("Expected a versioned delete to succeed, got %v %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestBulkLimits(t *testing.T) {
+	fs := config.NewFileStorage(filepath.Join(t.TempDir(), "test_books.json"))
+	h := NewBookHandler(storage.NewFileBookStore(fs), false)
+
+	r := mux.NewRouter()
+	r.HandleFunc("/books/bulk", h.BulkUpsertBooks).Methods("POST")
+	r.HandleFunc("/books/bulk/delete", h.BulkDeleteBooks).Methods("POST")
+
+	do := func(path, contentType, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	books := make([]string, maxBulkItems+1)
+	for i := range books {
+		books[i] = `{"title": "Book"}`
+	}
+	if rr := do("/books/bulk", "application/json", "["+strings.Join(books, ",")+"]"); rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for too many books, got %v %s", rr.Code, rr.Body.String())
+	}
+	if rr := do("/books/bulk", "application/x-ndjson", strings.Join(books, "\n")); rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for too many NDJSON lines, got %v %s", rr.Code, rr.Body.String())
+	}
+	if rr := do("/books/bulk", "application/json", `{"title": "Not an array"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a body that is not an array, got %v", rr.Code)
+	}
+
+	huge := `{"ids": ["` + strings.Repeat("a", maxBulkDeleteBytes) + `"]}`
+	if rr := do("/books/bulk/delete", "application/json", huge); rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for an oversized body, got %v %s", rr.Code, rr.Body.String())
+	}
 }
@@ -0,0 +1,457 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/harshakumara/book-api/models"
+	"github.com/harshakumara/book-api/storage"
+)
+
+// Limits of one bulk request. Bodies are cut off at the byte limits and
+// items are counted as they are decoded, so an oversized request fails
+// before it is held in memory.
+const (
+	maxBulkItems = 10000
+	// maxBulkBytes caps bulk upsert and import bodies, about 3KB a book
+	maxBulkBytes = 32 << 20
+	// maxBulkDeleteBytes caps bulk delete bodies, which hold only IDs
+	maxBulkDeleteBytes = 1 << 20
+)
+
+// Bulk request modes
+const (
+	bulkAtomic     = "atomic"
+	bulkBestEffort = "best-effort"
+)
+
+// BulkItemResult reports the outcome of one item in a bulk request
+type BulkItemResult struct {
+	Index int `json:"index"`
+	// Line is the item's line in an imported CSV file
+	Line    int       `json:"line,omitempty"`
+	Status  int       `json:"status"`
+	BookID  string    `json:"bookId,omitempty"`
+	Version int64     `json:"version,omitempty"`
+	Error   *APIError `json:"error,omitempty"`
+}
+
+// BulkUpsertResponse is the body of a bulk upsert
+type BulkUpsertResponse struct {
+	Created int              `json:"created"`
+	Updated int              `json:"updated"`
+	Failed  int              `json:"failed"`
+	Results []BulkItemResult `json:"results"`
+}
+
+// BulkDeleteResponse is the body of a bulk delete by IDs
+type BulkDeleteResponse struct {
+	Deleted int              `json:"deleted"`
+	Failed  int              `json:"failed"`
+	Results []BulkItemResult `json:"results"`
+}
+
+// BulkDeleteRequest selects the books removed by a bulk delete, either by ID
+// or by filter
+type BulkDeleteRequest struct {
+	IDs    []BulkDeleteItem  `json:"ids"`
+	Filter *BulkDeleteFilter `json:"filter"`
+}
+
+// BulkDeleteItem is one book of a bulk delete by IDs. It is sent as the ID
+// alone, or as {"bookId": ..., "version": ...} to delete the book only if
+// it is still at that version.
+type BulkDeleteItem struct {
+	ID      string `json:"bookId"`
+	Version int64  `json:"version"`
+}
+
+// UnmarshalJSON accepts a bare ID or an object
+func (item *BulkDeleteItem) UnmarshalJSON(data []byte) error {
+	var id string
+	if err := json.Unmarshal(data, &id); err == nil {
+		*item = BulkDeleteItem{ID: id}
+		return nil
+	}
+
+	type plain BulkDeleteItem
+	return json.Unmarshal(data, (*plain)(item))
+}
+
+// BulkDeleteFilter matches books like the GET /books filter parameters
+type BulkDeleteFilter struct {
+	Genre       string   `json:"genre"`
+	AuthorID    string   `json:"authorId"`
+	PublisherID string   `json:"publisherId"`
+	MinPrice    *float64 `json:"minPrice"`
+	MaxPrice    *float64 `json:"maxPrice"`
+	InStock     *bool    `json:"inStock"`
+	PriceBand   string   `json:"priceBand"`
+	Decade      string   `json:"decade"`
+}
+
+// BulkUpsertBooks creates or replaces many books in one request.
+// The body is a JSON array of books, or one book per line when sent as
+// application/x-ndjson. Books with a bookId replace that book; the rest are
+// created. A book's version, like If-Match, makes its replacement
+// conditional; with If-Match required a book with a bookId but no version
+// is only created, and fails if that book exists.
+// In atomic mode (the default) nothing is written if any book fails;
+// with ?mode=best-effort every valid book is written.
+func (h *BookHandler) BulkUpsertBooks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	atomic, apiErr := bulkMode(r)
+	if apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBulkBytes)
+	books, itemErrs, apiErr := decodeBulkBooks(r)
+	if apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+	if len(books) == 0 {
+		writeError(w, r, &APIError{Status: http.StatusBadRequest, Code: CodeInvalidBody, Message: "At least one book is required"})
+		return
+	}
+
+	for i := range books {
+		if itemErrs[i] == nil {
+			books[i].Normalize()
+			if err := books[i].Validate(); err != nil {
+				itemErrs[i] = storeAPIError(r, "Book", err)
+			} else if h.requireIfMatch && !books[i].ID.IsZero() && books[i].Version == 0 {
+				// Without a version the book may only be created, so it
+				// cannot overwrite a change it has not seen
+				books[i].Version = storage.CreateOnly
+			}
+		}
+	}
+
+	resp, err := h.upsertBatch(r, books, itemErrs, atomic)
+	if err != nil {
+		writeStoreError(w, r, "Book", err)
+		return
+	}
+
+	var status int
+	resp.Failed, status = bulkStatus(resp.Results, atomic)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// BulkDeleteBooks deletes many books in one request, either by a list of IDs
+// with per-ID results, or every book matching a filter. Each ID may carry
+// the version the book must be at; with If-Match required every ID must,
+// and deleting by filter is refused.
+func (h *BookHandler) BulkDeleteBooks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	atomic, apiErr := bulkMode(r)
+	if apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+
+	var req BulkDeleteRequest
+	r.Body = http.MaxBytesReader(w, r.Body, maxBulkDeleteBytes)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+
+	switch {
+	case req.Filter != nil && req.IDs != nil:
+		writeError(w, r, &APIError{Status: http.StatusBadRequest, Code: CodeInvalidBody, Message: "Send either ids or filter, not both"})
+	case req.Filter != nil && h.requireIfMatch:
+		writeError(w, r, &APIError{Status: http.StatusPreconditionRequired, Code: CodePreconditionRequired, Message: "Deleting by filter is disabled while If-Match is required; delete by ids with versions"})
+	case req.Filter != nil:
+		filter := storage.BookFilter(*req.Filter)
+		if filter == (storage.BookFilter{}) {
+			writeError(w, r, &APIError{Status: http.StatusBadRequest, Code: CodeInvalidBody, Message: "Filter must have at least one field"})
+			return
+		}
+		if err := filter.Validate(); err != nil {
+			writeStoreError(w, r, "Book", err)
+			return
+		}
+
+		deleted, err := h.store.DeleteWhere(r.Context(), filter)
+		if err != nil {
+			writeStoreError(w, r, "Book", err)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]int64{"deleted": deleted})
+	case len(req.IDs) == 0:
+		writeError(w, r, &APIError{Status: http.StatusBadRequest, Code: CodeInvalidBody, Message: "Either ids or filter is required"})
+	case len(req.IDs) > maxBulkItems:
+		writeError(w, r, &APIError{
+			Status:  http.StatusRequestEntityTooLarge,
+			Code:    CodeInvalidBody,
+			Message: fmt.Sprintf("At most %d ids can be sent in one request", maxBulkItems),
+		})
+	default:
+		resp, err := h.deleteBatch(r, req.IDs, atomic)
+		if err != nil {
+			writeStoreError(w, r, "Book", err)
+			return
+		}
+
+		var status int
+		resp.Failed, status = bulkStatus(resp.Results, atomic)
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// deleteBatch deletes the books of a bulk delete by IDs and reports every
+// item's outcome. Items without a version fail up front if one is required;
+// in atomic mode nothing is then deleted.
+func (h *BookHandler) deleteBatch(r *http.Request, items []BulkDeleteItem, atomic bool) (BulkDeleteResponse, error) {
+	resp := BulkDeleteResponse{Results: make([]BulkItemResult, len(items))}
+
+	var ids []string
+	var versions []int64
+	var positions []int
+	for i, item := range items {
+		if h.requireIfMatch && item.Version == 0 {
+			apiErr := versionRequired()
+			resp.Results[i] = BulkItemResult{Index: i, Status: apiErr.Status, BookID: item.ID, Error: apiErr}
+			continue
+		}
+
+		version := item.Version
+		if version == 0 {
+			version = storage.AnyVersion
+		}
+		ids = append(ids, item.ID)
+		versions = append(versions, version)
+		positions = append(positions, i)
+	}
+
+	if atomic && len(ids) < len(items) {
+		for _, i := range positions {
+			apiErr := storeAPIError(r, "Book", storage.ErrNotApplied)
+			resp.Results[i] = BulkItemResult{Index: i, Status: apiErr.Status, BookID: items[i].ID, Error: apiErr}
+		}
+		return resp, nil
+	}
+	if len(ids) == 0 {
+		return resp, nil
+	}
+
+	errs, err := h.store.DeleteMany(r.Context(), ids, versions, atomic)
+	if err != nil {
+		return resp, err
+	}
+
+	for j, err := range errs {
+		i := positions[j]
+		if err != nil {
+			apiErr := storeAPIError(r, "Book", err)
+			resp.Results[i] = BulkItemResult{Index: i, Status: apiErr.Status, BookID: ids[j], Error: apiErr}
+			continue
+		}
+		resp.Deleted++
+		resp.Results[i] = BulkItemResult{Index: i, Status: http.StatusNoContent, BookID: ids[j]}
+	}
+
+	return resp, nil
+}
+
+// upsertBatch writes the books that have no item error and reports every
+// book's outcome. In atomic mode nothing is written if any item failed.
+func (h *BookHandler) upsertBatch(r *http.Request, books []models.Book, itemErrs []*APIError, atomic bool) (BulkUpsertResponse, error) {
+	var valid []models.Book
+	var positions []int
+	for i, book := range books {
+		if itemErrs[i] == nil {
+			valid = append(valid, book)
+			positions = append(positions, i)
+		}
+	}
+
+	resp := BulkUpsertResponse{Results: make([]BulkItemResult, len(books))}
+	for i, apiErr := range itemErrs {
+		if apiErr != nil {
+			resp.Results[i] = BulkItemResult{Index: i, Status: apiErr.Status, BookID: bulkBookID(books[i]), Error: apiErr}
+		}
+	}
+
+	if atomic && len(valid) < len(books) {
+		for _, i := range positions {
+			resp.Results[i] = notAppliedResult(r, i, books[i])
+		}
+		return resp, nil
+	}
+	if len(valid) == 0 {
+		return resp, nil
+	}
+
+	results, err := h.store.BulkUpsert(r.Context(), valid, atomic)
+	if err != nil {
+		return resp, err
+	}
+
+	for j, res := range results {
+		i := positions[j]
+		switch {
+		case res.Err != nil:
+			apiErr := storeAPIError(r, "Book", res.Err)
+			// Report the ID as sent; a new book's generated ID was never stored
+			resp.Results[i] = BulkItemResult{Index: i, Status: apiErr.Status, BookID: bulkBookID(valid[j]), Error: apiErr}
+		case res.Created:
+			resp.Created++
+			resp.Results[i] = BulkItemResult{Index: i, Status: http.StatusCreated, BookID: res.Book.ID.Hex(), Version: res.Book.Version}
+		default:
+			resp.Updated++
+			resp.Results[i] = BulkItemResult{Index: i, Status: http.StatusOK, BookID: res.Book.ID.Hex(), Version: res.Book.Version}
+		}
+	}
+
+	return resp, nil
+}
+
+// bulkMode reads ?mode=, reporting whether the request is all-or-nothing
+func bulkMode(r *http.Request) (bool, *APIError) {
+	switch mode := r.URL.Query().Get("mode"); mode {
+	case "", bulkAtomic:
+		return true, nil
+	case bulkBestEffort:
+		return false, nil
+	default:
+		return false, &APIError{
+			Status:  http.StatusBadRequest,
+			Code:    CodeInvalidQuery,
+			Message: "mode must be " + bulkAtomic + " or " + bulkBestEffort,
+		}
+	}
+}
+
+// decodeBulkBooks reads the books of a bulk request one at a time, failing
+// as soon as there are more than maxBulkItems. For NDJSON a line that does
+// not decode fails only that item; its error is returned at its index.
+func decodeBulkBooks(r *http.Request) ([]models.Book, []*APIError, *APIError) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/x-ndjson" && mediaType != "application/ndjson" {
+		books, err := decodeBookArray(json.NewDecoder(r.Body))
+		if err != nil {
+			return nil, nil, err
+		}
+		return books, make([]*APIError, len(books)), nil
+	}
+
+	var books []models.Book
+	var errs []*APIError
+	reader := bufio.NewReader(r.Body)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, nil, bodyError(err)
+		}
+
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			if len(books) == maxBulkItems {
+				return nil, nil, tooManyBooks()
+			}
+
+			var book models.Book
+			var apiErr *APIError
+			if err := json.Unmarshal(line, &book); err != nil {
+				apiErr = &APIError{Status: http.StatusBadRequest, Code: CodeInvalidBody, Message: "Line is not valid JSON: " + err.Error()}
+			}
+			books = append(books, book)
+			errs = append(errs, apiErr)
+		}
+
+		if err == io.EOF {
+			return books, errs, nil
+		}
+	}
+}
+
+// decodeBookArray decodes a JSON array of books element by element
+func decodeBookArray(dec *json.Decoder) ([]models.Book, *APIError) {
+	if tok, err := dec.Token(); err != nil {
+		return nil, bodyError(err)
+	} else if tok != json.Delim('[') {
+		return nil, bodyError(errors.New("expected an array of books"))
+	}
+
+	books := []models.Book{}
+	for dec.More() {
+		if len(books) == maxBulkItems {
+			return nil, tooManyBooks()
+		}
+
+		var book models.Book
+		if err := dec.Decode(&book); err != nil {
+			return nil, bodyError(err)
+		}
+		books = append(books, book)
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return nil, bodyError(err)
+	}
+	return books, nil
+}
+
+// tooManyBooks is the error for a bulk request with too many books
+func tooManyBooks() *APIError {
+	return &APIError{
+		Status:  http.StatusRequestEntityTooLarge,
+		Code:    CodeInvalidBody,
+		Message: fmt.Sprintf("At most %d books can be sent in one request", maxBulkItems),
+	}
+}
+
+// bulkStatus counts the failed items of a bulk request and picks its status:
+// 200 when every item succeeded, 422 when an atomic batch was rejected, and
+// 207 for a partly applied batch
+func bulkStatus(results []BulkItemResult, atomic bool) (int, int) {
+	failed := 0
+	for _, res := range results {
+		if res.Error != nil {
+			failed++
+		}
+	}
+
+	switch {
+	case failed == 0:
+		return 0, http.StatusOK
+	case atomic:
+		return failed, http.StatusUnprocessableEntity
+	default:
+		return failed, http.StatusMultiStatus
+	}
+}
+
+// versionRequired is the error for a bulk item without the version that
+// If-Match would carry when If-Match is required
+func versionRequired() *APIError {
+	return &APIError{Status: http.StatusPreconditionRequired, Code: CodePreconditionRequired, Message: "version is required for existing books while If-Match is required"}
+}
+
+// notAppliedResult reports an item skipped because another item failed
+func notAppliedResult(r *http.Request, i int, book models.Book) BulkItemResult {
+	apiErr := storeAPIError(r, "Book", storage.ErrNotApplied)
+	return BulkItemResult{Index: i, Status: apiErr.Status, BookID: bulkBookID(book), Error: apiErr}
+}
+
+// bulkBookID returns the ID of a book in a result, if it has one
+func bulkBookID(book models.Book) string {
+	if book.ID.IsZero() {
+		return ""
+	}
+	return book.ID.Hex()
+}
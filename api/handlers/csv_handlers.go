@@ -0,0 +1,376 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/harshakumara/book-api/models"
+)
+
+// exportPageSize is how many books an export reads from the store at a time
+const exportPageSize = 500
+
+// CSVType is the media type of book exports and imports
+const CSVType = "text/csv"
+
+// columnsByHeader maps each normalized book column name to the column
+var columnsByHeader = func() map[string]string {
+	columns := map[string]string{}
+	for _, column := range models.BookColumns {
+		columns[normalizeHeader(column)] = column
+	}
+	return columns
+}()
+
+// ImportResponse is the body of a CSV import. Results are indexed by data
+// row, starting at 0 for the row after the header.
+type ImportResponse struct {
+	DryRun         bool     `json:"dryRun"`
+	IgnoredColumns []string `json:"ignoredColumns,omitempty"`
+	BulkUpsertResponse
+}
+
+// ExportBooks streams every book matching the GET /books filters as CSV,
+// with one column per models.BookColumns entry. Sorting is honoured;
+// pagination parameters are ignored.
+func (h *BookHandler) ExportBooks(w http.ResponseWriter, r *http.Request) {
+	if format := r.URL.Query().Get("format"); format != "csv" {
+		writeError(w, r, &APIError{Status: http.StatusBadRequest, Code: CodeInvalidQuery, Message: "format must be csv"})
+		return
+	}
+
+	query, err := parseBookQuery(r.URL.Query())
+	if err != nil {
+		writeStoreError(w, r, "Book", err)
+		return
+	}
+	query.Limit, query.Offset, query.Cursor = exportPageSize, 0, ""
+
+	// Read the first page before writing anything so a failure still gets
+	// an error response
+	page, err := h.store.List(r.Context(), query)
+	if err != nil {
+		writeStoreError(w, r, "Book", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", CSVType+"; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="books.csv"`)
+	w.Header().Set("X-Total-Count", strconv.FormatInt(page.Total, 10))
+
+	out := csv.NewWriter(w)
+	out.Write(models.BookColumns)
+	for {
+		for _, book := range page.Books {
+			out.Write(book.CSVRecord())
+		}
+		out.Flush()
+		if out.Error() != nil || page.NextCursor == "" {
+			return
+		}
+
+		query.Cursor = page.NextCursor
+		if page, err = h.store.List(r.Context(), query); err != nil {
+			// The status is already sent; the client sees a truncated file
+			log.Printf("[%s] %s %s: export stopped: %v", RequestIDFromContext(r.Context()), r.Method, r.URL.Path, err)
+			return
+		}
+	}
+}
+
+// ImportBooks creates or updates books from a CSV file, matching existing
+// books by ISBN. The header row names each column; headers match
+// models.BookColumns ignoring case, spaces, hyphens and underscores, and
+// ?map=Header:column maps any other header. bookId and version are ignored.
+// With ?dryRun=true the rows are checked and the outcome reported without
+// writing anything. ?mode works as for POST /books/bulk.
+func (h *BookHandler) ImportBooks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != CSVType && mediaType != "application/csv" {
+		writeError(w, r, &APIError{Status: http.StatusUnsupportedMediaType, Code: CodeUnsupportedMediaType, Message: "Content-Type must be " + CSVType})
+		return
+	}
+
+	atomic, apiErr := bulkMode(r)
+	if apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+
+	dryRun := false
+	if raw := r.URL.Query().Get("dryRun"); raw != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(raw); err != nil {
+			writeError(w, r, &APIError{Status: http.StatusBadRequest, Code: CodeInvalidQuery, Message: "dryRun must be true or false"})
+			return
+		}
+	}
+
+	mapping, apiErr := parseColumnMap(r.URL.Query()["map"])
+	if apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+
+	reader := csv.NewReader(http.MaxBytesReader(w, r.Body, maxBulkBytes))
+	header, err := reader.Read()
+	if err == io.EOF {
+		writeError(w, r, &APIError{Status: http.StatusBadRequest, Code: CodeInvalidBody, Message: "CSV must start with a header row"})
+		return
+	}
+	if err != nil {
+		writeError(w, r, &APIError{Status: http.StatusBadRequest, Code: CodeInvalidBody, Message: "Request body is not valid CSV: " + err.Error()})
+		return
+	}
+
+	resp := ImportResponse{DryRun: dryRun}
+	var columns []string
+	columns, resp.IgnoredColumns = mapColumns(header, mapping)
+	if indexOf(columns, "isbn") < 0 {
+		writeError(w, r, &APIError{Status: http.StatusBadRequest, Code: CodeInvalidBody, Message: "CSV must have an isbn column to match books by"})
+		return
+	}
+
+	records, lines, apiErr := readImportRows(reader)
+	if apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+	if len(records) == 0 {
+		writeError(w, r, &APIError{Status: http.StatusBadRequest, Code: CodeInvalidBody, Message: "At least one row is required"})
+		return
+	}
+
+	books, itemErrs, err := h.importBooks(r, records, lines, columns)
+	if err != nil {
+		writeStoreError(w, r, "Book", err)
+		return
+	}
+
+	if dryRun {
+		resp.Results = make([]BulkItemResult, len(books))
+		for i, book := range books {
+			switch {
+			case itemErrs[i] != nil:
+				resp.Results[i] = BulkItemResult{Index: i, Status: itemErrs[i].Status, Error: itemErrs[i]}
+			case book.ID.IsZero():
+				resp.Created++
+				resp.Results[i] = BulkItemResult{Index: i, Status: http.StatusCreated}
+			default:
+				resp.Updated++
+				resp.Results[i] = BulkItemResult{Index: i, Status: http.StatusOK, BookID: book.ID.Hex()}
+			}
+		}
+	} else if resp.BulkUpsertResponse, err = h.upsertBatch(r, books, itemErrs, atomic); err != nil {
+		writeStoreError(w, r, "Book", err)
+		return
+	}
+
+	for i := range resp.Results {
+		resp.Results[i].Line = lines[i]
+	}
+
+	var status int
+	resp.Failed, status = bulkStatus(resp.Results, atomic)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// readImportRows reads every data row and its line number. Rows with the
+// wrong number of cells are returned as they are and reported by importRow.
+func readImportRows(reader *csv.Reader) ([][]string, []int, *APIError) {
+	reader.FieldsPerRecord = -1
+
+	var records [][]string
+	var lines []int
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, nil, bodyError(err)
+		}
+		if err != nil {
+			return nil, nil, &APIError{Status: http.StatusBadRequest, Code: CodeInvalidBody, Message: "Request body is not valid CSV: " + err.Error()}
+		}
+		if len(records) == maxBulkItems {
+			return nil, nil, &APIError{
+				Status:  http.StatusRequestEntityTooLarge,
+				Code:    CodeInvalidBody,
+				Message: fmt.Sprintf("At most %d rows can be imported in one request", maxBulkItems),
+			}
+		}
+
+		line, _ := reader.FieldPos(0)
+		records = append(records, record)
+		lines = append(lines, line)
+	}
+
+	return records, lines, nil
+}
+
+// importBooks turns rows into books, returning one error (or nil) per row.
+// A row whose ISBN belongs to a stored book updates that book's imported
+// columns and keeps the rest; other rows create books. A repeated ISBN
+// fails the later rows.
+func (h *BookHandler) importBooks(r *http.Request, records [][]string, lines []int, columns []string) ([]models.Book, []*APIError, error) {
+	isbnColumn := indexOf(columns, "isbn")
+	var isbns []string
+	for _, record := range records {
+		if isbnColumn < len(record) {
+			if isbn := models.NormalizeISBN(strings.TrimSpace(record[isbnColumn])); isbn != "" {
+				isbns = append(isbns, isbn)
+			}
+		}
+	}
+
+	stored := map[string]models.Book{}
+	if len(isbns) > 0 {
+		existing, err := h.store.FindByISBN(r.Context(), isbns)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, book := range existing {
+			stored[book.ISBN] = book
+		}
+	}
+
+	books := make([]models.Book, len(records))
+	errs := make([]*APIError, len(records))
+	firstRow := map[string]int{}
+	for i, record := range records {
+		var base models.Book
+		if isbnColumn < len(record) {
+			base = stored[models.NormalizeISBN(strings.TrimSpace(record[isbnColumn]))]
+		}
+
+		books[i], errs[i] = importRow(base, record, columns)
+		if errs[i] != nil {
+			continue
+		}
+
+		isbn := books[i].ISBN
+		if first, ok := firstRow[isbn]; ok {
+			errs[i] = &APIError{
+				Status:  http.StatusConflict,
+				Code:    CodeDuplicateISBN,
+				Message: fmt.Sprintf("ISBN %s is already used on line %d", isbn, lines[first]),
+				Details: []models.FieldError{{Field: "isbn", Message: "is already used by another row"}},
+			}
+			continue
+		}
+		firstRow[isbn] = i
+	}
+
+	return books, errs, nil
+}
+
+// importRow applies one CSV row to book, reporting unparsable cells
+// together with the result's validation errors
+func importRow(book models.Book, record, columns []string) (models.Book, *APIError) {
+	if len(record) != len(columns) {
+		return book, &APIError{
+			Status:  http.StatusBadRequest,
+			Code:    CodeInvalidBody,
+			Message: fmt.Sprintf("Row has %d cells but the header has %d", len(record), len(columns)),
+		}
+	}
+
+	errs := &models.ValidationError{}
+	for i, column := range columns {
+		if column == "" {
+			continue
+		}
+		if fe := book.SetCSVField(column, strings.TrimSpace(record[i])); fe != nil {
+			errs.Errors = append(errs.Errors, *fe)
+		}
+	}
+
+	book.Normalize()
+	var validationErr *models.ValidationError
+	if err := book.Validate(); errors.As(err, &validationErr) {
+		errs.Errors = append(errs.Errors, validationErr.Errors...)
+	}
+	if book.ISBN == "" {
+		errs.Errors = append(errs.Errors, models.FieldError{Field: "isbn", Message: "is required to import"})
+	}
+
+	if len(errs.Errors) > 0 {
+		return book, &APIError{Status: http.StatusUnprocessableEntity, Code: CodeValidationFailed, Message: "Validation failed", Details: errs.Errors}
+	}
+	return book, nil
+}
+
+// parseColumnMap parses ?map=Header:column parameters into a map from
+// normalized header to book column
+func parseColumnMap(params []string) (map[string]string, *APIError) {
+	mapping := map[string]string{}
+	for _, param := range params {
+		i := strings.LastIndex(param, ":")
+		if i < 0 {
+			return nil, &APIError{Status: http.StatusBadRequest, Code: CodeInvalidQuery, Message: "map must be Header:column, got " + strconv.Quote(param)}
+		}
+
+		column, ok := columnsByHeader[normalizeHeader(param[i+1:])]
+		if !ok {
+			return nil, &APIError{Status: http.StatusBadRequest, Code: CodeInvalidQuery, Message: "map names unknown column " + strconv.Quote(param[i+1:])}
+		}
+		mapping[normalizeHeader(param[:i])] = column
+	}
+
+	return mapping, nil
+}
+
+// mapColumns returns the book column of each header cell, or "" for headers
+// that are not imported, along with the names of those headers
+func mapColumns(header []string, mapping map[string]string) ([]string, []string) {
+	columns := make([]string, len(header))
+	var ignored []string
+	for i, name := range header {
+		key := normalizeHeader(name)
+		column, ok := mapping[key]
+		if !ok {
+			column = columnsByHeader[key]
+		}
+
+		// Rows are matched by ISBN, so IDs and versions are never imported
+		if column == "" || column == "bookId" || column == "version" || indexOf(columns[:i], column) >= 0 {
+			ignored = append(ignored, name)
+			continue
+		}
+		columns[i] = column
+	}
+
+	return columns, ignored
+}
+
+// normalizeHeader folds a header for matching, so "Publication Date",
+// "publication_date" and "publicationDate" are the same column
+func normalizeHeader(name string) string {
+	// Spreadsheets often start the file with a byte order mark
+	name = strings.TrimPrefix(name, "\ufeff")
+	return strings.ToLower(strings.NewReplacer(" ", "", "_", "", "-", "").Replace(strings.TrimSpace(name)))
+}
+
+// indexOf returns the index of s in list, or -1
+func indexOf(list []string, s string) int {
+	for i, item := range list {
+		if item == s {
+			return i
+		}
+	}
+
+	return -1
+}
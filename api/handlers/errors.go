@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/harshakumara/book-api/models"
+	"github.com/harshakumara/book-api/patch"
+	"github.com/harshakumara/book-api/search"
+	"github.com/harshakumara/book-api/storage"
+)
+
+// Machine-readable error codes shared by every endpoint.
+// Resource-specific codes such as book_not_found are built from the resource name.
+const (
+	CodeInvalidID            = "invalid_id"
+	CodeInvalidBody          = "invalid_body"
+	CodeInvalidQuery         = "invalid_query"
+	CodeValidationFailed     = "validation_failed"
+	CodeReferenceNotFound    = "reference_not_found"
+	CodeStorageUnavailable   = "storage_unavailable"
+	CodeInsufficientStock    = "insufficient_stock"
+	CodeReservationClosed    = "reservation_not_active"
+	CodeReservationExpired   = "reservation_expired"
+	CodeInvalidTransition    = "invalid_transition"
+	CodeInvalidPatch         = "invalid_patch"
+	CodePatchConflict        = "patch_conflict"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodePreconditionFailed   = "precondition_failed"
+	CodePreconditionRequired = "precondition_required"
+	CodeNotApplied           = "not_applied"
+	CodeDuplicateISBN        = "duplicate_isbn"
+	CodeSearchTimeout        = "search_timeout"
+	CodeNotImplemented       = "not_implemented"
+	CodeQuerySyntax          = "query_syntax"
+	CodeNotRestorable        = "not_restorable"
+)
+
+// APIError is the body of every error response, wrapped as {"error": {...}}
+type APIError struct {
+	Status  int                 `json:"-"`
+	Code    string              `json:"code"`
+	Message string              `json:"message"`
+	Details []models.FieldError `json:"details,omitempty"`
+	// Position is the 1-based character of a query an error points at
+	Position  int    `json:"position,omitempty"`
+	RequestID string `json:"requestId,omitempty"`
+}
+
+func (e *APIError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// errorEnvelope wraps an APIError in the response body
+type errorEnvelope struct {
+	Error *APIError `json:"error"`
+}
+
+// writeError sends an APIError tagged with the request's ID
+func writeError(w http.ResponseWriter, r *http.Request, apiErr *APIError) {
+	apiErr.RequestID = RequestIDFromContext(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(apiErr.Status)
+	json.NewEncoder(w).Encode(errorEnvelope{Error: apiErr})
+}
+
+// writeBodyError reports a request body that could not be decoded
+func writeBodyError(w http.ResponseWriter, r *http.Request, err error) {
+	writeError(w, r, bodyError(err))
+}
+
+// bodyError describes a request body that could not be decoded. A body cut
+// off by http.MaxBytesReader is reported as too large.
+func bodyError(err error) *APIError {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return &APIError{
+			Status:  http.StatusRequestEntityTooLarge,
+			Code:    CodeInvalidBody,
+			Message: fmt.Sprintf("Request body must be at most %d bytes", tooLarge.Limit),
+		}
+	}
+
+	return &APIError{
+		Status:  http.StatusBadRequest,
+		Code:    CodeInvalidBody,
+		Message: "Request body is not valid JSON: " + err.Error(),
+	}
+}
+
+// writeStoreError maps a store error to an error response.
+// resource names the record type, e.g. "Book", in codes and messages.
+func writeStoreError(w http.ResponseWriter, r *http.Request, resource string, err error) {
+	writeError(w, r, storeAPIError(r, resource, err))
+}
+
+// storeAPIError maps a store error to an APIError.
+// Unexpected errors are logged with the request ID and masked from the client.
+func storeAPIError(r *http.Request, resource string, err error) *APIError {
+	var refErr *storage.ReferenceError
+	var validationErr *models.ValidationError
+	var syntaxErr *search.SyntaxError
+	code := strings.ToLower(resource)
+
+	switch {
+	case errors.As(err, &validationErr):
+		return &APIError{
+			Status:  http.StatusUnprocessableEntity,
+			Code:    CodeValidationFailed,
+			Message: "Validation failed",
+			Details: validationErr.Errors,
+		}
+	case errors.Is(err, storage.ErrNotFound):
+		return &APIError{Status: http.StatusNotFound, Code: code + "_not_found", Message: resource + " not found"}
+	case errors.Is(err, storage.ErrInvalidID):
+		return &APIError{Status: http.StatusBadRequest, Code: CodeInvalidID, Message: "Invalid ID format"}
+	case errors.As(err, &syntaxErr):
+		return &APIError{Status: http.StatusBadRequest, Code: CodeQuerySyntax, Message: syntaxErr.Error(), Position: syntaxErr.Position}
+	case errors.Is(err, storage.ErrInvalidQuery):
+		return &APIError{Status: http.StatusBadRequest, Code: CodeInvalidQuery, Message: err.Error()}
+	case errors.As(err, &refErr):
+		return &APIError{
+			Status:  http.StatusUnprocessableEntity,
+			Code:    CodeReferenceNotFound,
+			Message: refErr.Error(),
+			Details: []models.FieldError{{Field: refErr.Field, Message: "does not refer to an existing " + refErr.Resource}},
+		}
+	case errors.Is(err, storage.ErrDuplicateISBN):
+		return &APIError{
+			Status:  http.StatusConflict,
+			Code:    CodeDuplicateISBN,
+			Message: "Another book already has this ISBN",
+			Details: []models.FieldError{{Field: "isbn", Message: "is already used by another book"}},
+		}
+	case errors.Is(err, storage.ErrConflict):
+		return &APIError{Status: http.StatusConflict, Code: code + "_exists", Message: resource + " already exists"}
+	case errors.Is(err, storage.ErrHasBooks):
+		return &APIError{Status: http.StatusConflict, Code: code + "_has_books", Message: resource + " still has books"}
+	case errors.Is(err, storage.ErrInsufficientStock):
+		return &APIError{Status: http.StatusConflict, Code: CodeInsufficientStock, Message: "Not enough stock: " + err.Error()}
+	case errors.Is(err, storage.ErrReservationClosed):
+		return &APIError{Status: http.StatusConflict, Code: CodeReservationClosed, Message: "Reservation is no longer active"}
+	case errors.Is(err, storage.ErrReservationExpired):
+		return &APIError{Status: http.StatusConflict, Code: CodeReservationExpired, Message: "Reservation has expired"}
+	case errors.Is(err, patch.ErrMalformed):
+		return &APIError{Status: http.StatusBadRequest, Code: CodeInvalidPatch, Message: err.Error()}
+	case errors.Is(err, patch.ErrFailed):
+		return &APIError{Status: http.StatusConflict, Code: CodePatchConflict, Message: err.Error()}
+	case errors.Is(err, storage.ErrVersionMismatch):
+		return preconditionFailed()
+	case errors.Is(err, storage.ErrAtomicUnsupported):
+		return &APIError{Status: http.StatusNotImplemented, Code: CodeNotImplemented, Message: "Atomic bulk writes need a MongoDB replica set; use mode=best-effort"}
+	case errors.Is(err, storage.ErrNotApplied):
+		return &APIError{Status: http.StatusFailedDependency, Code: CodeNotApplied, Message: "Not applied because another item in the batch failed"}
+	case errors.Is(err, storage.ErrSearchTimeout):
+		return &APIError{Status: http.StatusUnprocessableEntity, Code: CodeSearchTimeout, Message: "The search took too long, try a simpler pattern"}
+	case errors.Is(err, storage.ErrInvalidTransition):
+		return &APIError{Status: http.StatusConflict, Code: CodeInvalidTransition, Message: resource + " cannot move to the requested status"}
+	default:
+		log.Printf("[%s] %s %s: storage error: %v", RequestIDFromContext(r.Context()), r.Method, r.URL.Path, err)
+		return &APIError{
+			Status:  http.StatusServiceUnavailable,
+			Code:    CodeStorageUnavailable,
+			Message: "The storage backend is unavailable, please retry later",
+		}
+	}
+}
+
+// NotFound responds to requests that match no route
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, &APIError{Status: http.StatusNotFound, Code: "route_not_found", Message: "No route for " + r.URL.Path})
+}
+
+// MethodNotAllowed responds to requests whose path matches a route but not its method
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, &APIError{Status: http.StatusMethodNotAllowed, Code: "method_not_allowed", Message: r.Method + " is not allowed on " + r.URL.Path})
+}
+
+// InvalidID responds to book paths whose ID is not a valid ObjectID
+func InvalidID(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, &APIError{Status: http.StatusBadRequest, Code: CodeInvalidID, Message: "Invalid ID format"})
+}
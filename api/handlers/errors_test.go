@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/harshakumara/book-api/config"
+	"github.com/harshakumara/book-api/storage"
+)
+
+func decodeError(t *testing.T, rr *httptest.ResponseRecorder) *APIError {
+	t.Helper()
+
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected JSON error, got Content-Type %q", ct)
+	}
+
+	var envelope errorEnvelope
+	if err := json.Unmarshal(rr.Body.Bytes(), &envelope); err != nil || envelope.Error == nil {
+		t.Fatalf("Expected an error envelope, got %q", rr.Body.String())
+	}
+
+	return envelope.Error
+}
+
+func TestErrorEnvelopeCarriesCodeAndRequestID(t *testing.T) {
+	fs := config.NewFileStorage(filepath.Join(t.TempDir(), "test_books.json"))
+	h := NewBookHandler(storage.NewFileBookStore(fs), false)
+
+	r := mux.NewRouter()
+	r.Use(RequestID)
+	r.HandleFunc("/books/{id}", h.GetBook).Methods("GET")
+
+	req, _ := http.NewRequest("GET", "/books/000000000000000000000000", nil)
+	req.Header.Set(RequestIDHeader, "test-request")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("Expected 404, got %v", rr.Code)
+	}
+	apiErr := decodeError(t, rr)
+	if apiErr.Code != "book_not_found" {
+		t.Errorf("Expected code book_not_found, got %s", apiErr.Code)
+	}
+	if apiErr.RequestID != "test-request" || rr.Header().Get(RequestIDHeader) != "test-request" {
+		t.Errorf("Expected request ID to be echoed, got %q / %q", apiErr.RequestID, rr.Header().Get(RequestIDHeader))
+	}
+}
+
+func TestStoreErrorsAreMasked(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/books", nil)
+	rr := httptest.NewRecorder()
+	writeStoreError(rr, req, "Book", errors.New("connection refused: mongodb://user:secret@db"))
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503, got %v", rr.Code)
+	}
+	apiErr := decodeError(t, rr)
+	if apiErr.Code != CodeStorageUnavailable {
+		t.Errorf("Expected code %s, got %s", CodeStorageUnavailable, apiErr.Code)
+	}
+	if strings.Contains(rr.Body.String(), "secret") {
+		t.Errorf("Expected internal error to be masked, got %q", rr.Body.String())
+	}
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/harshakumara/book-api/models"
+	"github.com/harshakumara/book-api/storage"
+)
+
+// bookETag is the strong entity tag of a book version
+func bookETag(book models.Book) string {
+	return `"` + strconv.FormatInt(book.Version, 10) + `"`
+}
+
+// bodyETag is a weak entity tag derived from a response body
+func bodyETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// ifMatchVersion returns the book version a write is conditional on.
+// Without an If-Match header the write is unconditional unless required is
+// set. "*" matches any version; otherwise the header must be a single strong
+// ETag as returned by bookETag, and anything else can never match.
+func ifMatchVersion(r *http.Request, required bool) (int64, *APIError) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	switch {
+	case header == "" && required:
+		return 0, &APIError{Status: http.StatusPreconditionRequired, Code: CodePreconditionRequired, Message: "If-Match header with the book's ETag is required"}
+	case header == "" || header == "*":
+		return storage.AnyVersion, nil
+	}
+
+	if len(header) >= 2 && header[0] == '"' && header[len(header)-1] == '"' {
+		if v, err := strconv.ParseInt(header[1:len(header)-1], 10, 64); err == nil && v >= 0 {
+			return v, nil
+		}
+	}
+
+	return 0, preconditionFailed()
+}
+
+// notModified reports whether the request's If-None-Match header matches
+// etag, using the weak comparison of RFC 9110
+func notModified(r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// preconditionFailed is the error for an If-Match that does not match the book
+func preconditionFailed() *APIError {
+	return &APIError{Status: http.StatusPreconditionFailed, Code: CodePreconditionFailed, Message: "The book has been changed since it was read; fetch it again and retry"}
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/harshakumara/book-api/models"
+	"github.com/harshakumara/book-api/storage"
+)
+
+// Bounds of ?limit on the audit log
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = storage.MaxLimit
+)
+
+// HistoryHandler serves book revision histories, the audit log and restores
+type HistoryHandler struct {
+	revisions      storage.RevisionStore
+	books          storage.BookStore
+	requireIfMatch bool
+}
+
+// NewHistoryHandler creates a HistoryHandler reading revisions and
+// restoring books through books, which should record its writes in
+// revisions. requireIfMatch is as for NewBookHandler.
+func NewHistoryHandler(revisions storage.RevisionStore, books storage.BookStore, requireIfMatch bool) *HistoryHandler {
+	return &HistoryHandler{revisions: revisions, books: books, requireIfMatch: requireIfMatch}
+}
+
+// GetBookHistory returns every revision of a book, oldest first.
+// A deleted book keeps its history, so it can be restored.
+func (h *HistoryHandler) GetBookHistory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id := mux.Vars(r)["id"]
+
+	history, err := h.revisions.History(r.Context(), id)
+	if err == nil && len(history) == 0 {
+		// A book written before auditing began has no history yet
+		_, err = h.books.Get(r.Context(), id)
+	}
+	if err != nil {
+		writeStoreError(w, r, "Book", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(history)
+}
+
+// GetAudit returns the revisions of every book recorded at or after
+// ?since (RFC 3339, default the beginning), oldest first. ?limit caps the
+// number of revisions (default 100, at most 1000); to read further, pass
+// the createdAt of the last one as since and skip the revisions already seen.
+func (h *HistoryHandler) GetAudit(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var since time.Time
+	var err error
+	if raw := r.URL.Query().Get("since"); raw != "" {
+		if since, err = time.Parse(time.RFC3339, raw); err != nil {
+			err = fmt.Errorf("%w: since must be an RFC 3339 time such as 2024-01-02T15:04:05Z", storage.ErrInvalidQuery)
+		}
+	}
+
+	limit := 0
+	if err == nil {
+		limit, err = intParam(r.URL.Query(), "limit")
+	}
+	if err == nil && (limit < 0 || limit > maxAuditLimit) {
+		err = fmt.Errorf("%w: limit must be between 0 and %d", storage.ErrInvalidQuery, maxAuditLimit)
+	}
+	if err != nil {
+		writeStoreError(w, r, "Revision", err)
+		return
+	}
+	if limit == 0 {
+		limit = defaultAuditLimit
+	}
+
+	revisions, err := h.revisions.Since(r.Context(), since, limit)
+	if err != nil {
+		writeStoreError(w, r, "Revision", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(revisions)
+}
+
+// RestoreBook puts a book back the way one of its revisions left it. The
+// restore is itself recorded as a revision. Stock is not part of a
+// restore: the book keeps its current quantity, so units sold or received
+// since the revision are not undone. A deleted book is recreated with its
+// old ID, no stock and the version after the one it was deleted at, and
+// answered with 201 Created. If-Match applies as for PUT; "*" may be used
+// to recreate a deleted book when it is required.
+func (h *HistoryHandler) RestoreBook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	vars := mux.Vars(r)
+	id, revisionID := vars["id"], vars["revisionId"]
+
+	version, apiErr := ifMatchVersion(r, h.requireIfMatch)
+	if apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+
+	revision, err := h.revisions.Get(r.Context(), revisionID)
+	if err == nil && revision.BookID.Hex() != id {
+		err = storage.ErrNotFound
+	}
+	if err != nil {
+		writeStoreError(w, r, "Revision", err)
+		return
+	}
+	if revision.Book == nil {
+		writeError(w, r, &APIError{Status: http.StatusConflict, Code: CodeNotRestorable, Message: "The revision deleted the book; restore an earlier revision"})
+		return
+	}
+
+	book := *revision.Book
+	book.Normalize()
+	if err := book.Validate(); err != nil {
+		writeStoreError(w, r, "Book", err)
+		return
+	}
+
+	info := storage.ChangeInfoFromContext(r.Context())
+	info.RestoredFrom = revisionID
+	ctx := storage.WithChangeInfo(r.Context(), info)
+
+	status := http.StatusOK
+	restored, err := h.books.Patch(ctx, id, version, func(current models.Book) (models.Book, error) {
+		book.Quantity = current.Quantity
+		return book, nil
+	})
+	if errors.Is(err, storage.ErrNotFound) && version == storage.AnyVersion {
+		status = http.StatusCreated
+		restored, err = h.recreate(ctx, id, book)
+	}
+	if err != nil {
+		writeStoreError(w, r, "Book", err)
+		return
+	}
+
+	w.Header().Set("ETag", bookETag(restored))
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(restored)
+}
+
+// recreate stores a deleted book again with no stock, continuing its
+// version history so ETags it had before the delete are not reused
+func (h *HistoryHandler) recreate(ctx context.Context, id string, book models.Book) (models.Book, error) {
+	history, err := h.revisions.History(ctx, id)
+	if err != nil {
+		return models.Book{}, err
+	}
+
+	book.Quantity = 0
+	book.Version = 1
+	if n := len(history); n > 0 {
+		book.Version = history[n-1].Version + 1
+	}
+	return h.books.Create(ctx, book)
+}
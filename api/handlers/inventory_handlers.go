@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/harshakumara/book-api/models"
+	"github.com/harshakumara/book-api/storage"
+)
+
+// InventoryHandler serves the stock adjustment and reservation endpoints
+type InventoryHandler struct {
+	store      storage.InventoryStore
+	defaultTTL time.Duration
+	maxTTL     time.Duration
+}
+
+// NewInventoryHandler creates an InventoryHandler.
+// Reservations last defaultTTL unless the request asks for a shorter or
+// longer one, which is capped at maxTTL.
+func NewInventoryHandler(store storage.InventoryStore, defaultTTL, maxTTL time.Duration) *InventoryHandler {
+	return &InventoryHandler{store: store, defaultTTL: defaultTTL, maxTTL: maxTTL}
+}
+
+// stockAdjustRequest is the body of POST /books/{id}/stock/adjust
+type stockAdjustRequest struct {
+	Delta  int    `json:"delta"`
+	Reason string `json:"reason"`
+}
+
+// reservationRequest is the body of POST /books/{id}/reservations
+type reservationRequest struct {
+	Quantity   int `json:"quantity"`
+	TTLSeconds int `json:"ttlSeconds"`
+}
+
+// AdjustStock changes a book's quantity by a relative delta
+func (h *InventoryHandler) AdjustStock(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id := mux.Vars(r)["id"]
+
+	var req stockAdjustRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+
+	errs := &models.ValidationError{}
+	if req.Delta == 0 {
+		errs.Errors = append(errs.Errors, models.FieldError{Field: "delta", Message: "must not be zero"})
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		errs.Errors = append(errs.Errors, models.FieldError{Field: "reason", Message: "is required"})
+	}
+	if len(errs.Errors) > 0 {
+		writeStoreError(w, r, "Book", errs)
+		return
+	}
+
+	adjustment, err := h.store.AdjustStock(r.Context(), id, req.Delta, req.Reason)
+	if err != nil {
+		writeStoreError(w, r, "Book", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(adjustment)
+}
+
+// CreateReservation holds stock of a book until it is committed, released or expires
+func (h *InventoryHandler) CreateReservation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id := mux.Vars(r)["id"]
+
+	var req reservationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+
+	ttl := h.defaultTTL
+	if req.TTLSeconds != 0 {
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+	}
+
+	errs := &models.ValidationError{}
+	if req.Quantity <= 0 {
+		errs.Errors = append(errs.Errors, models.FieldError{Field: "quantity", Message: "must be positive"})
+	}
+	if ttl <= 0 || (h.maxTTL > 0 && ttl > h.maxTTL) {
+		errs.Errors = append(errs.Errors, models.FieldError{Field: "ttlSeconds", Message: "must be between 1 and " + h.maxTTL.String()})
+	}
+	if len(errs.Errors) > 0 {
+		writeStoreError(w, r, "Book", errs)
+		return
+	}
+
+	reservation, err := h.store.Reserve(r.Context(), id, req.Quantity, ttl)
+	if err != nil {
+		writeStoreError(w, r, "Book", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(reservation)
+}
+
+// GetReservation returns a reservation by ID
+func (h *InventoryHandler) GetReservation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id := mux.Vars(r)["id"]
+
+	reservation, err := h.store.GetReservation(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, r, "Reservation", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(reservation)
+}
+
+// ReleaseReservation gives a reservation's stock back to the book
+func (h *InventoryHandler) ReleaseReservation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id := mux.Vars(r)["id"]
+
+	reservation, err := h.store.ReleaseReservation(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, r, "Reservation", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(reservation)
+}
+
+// CommitReservation makes a reservation's stock decrement permanent
+func (h *InventoryHandler) CommitReservation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id := mux.Vars(r)["id"]
+
+	reservation, err := h.store.CommitReservation(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, r, "Reservation", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(reservation)
+}
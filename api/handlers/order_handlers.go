@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/harshakumara/book-api/models"
+	"github.com/harshakumara/book-api/storage"
+)
+
+// OrderHandler serves the /orders endpoints on top of an OrderStore
+type OrderHandler struct {
+	store storage.OrderStore
+}
+
+// NewOrderHandler creates an OrderHandler using the given store
+func NewOrderHandler(store storage.OrderStore) *OrderHandler {
+	return &OrderHandler{store: store}
+}
+
+// GetOrders returns all orders, newest first, optionally filtered by ?status=
+func (h *OrderHandler) GetOrders(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", models.OrderPending, models.OrderPaid, models.OrderShipped, models.OrderCancelled:
+	default:
+		writeError(w, r, &APIError{Status: http.StatusBadRequest, Code: CodeInvalidQuery, Message: "status must be pending, paid, shipped or cancelled"})
+		return
+	}
+
+	orders, err := h.store.List(r.Context(), status)
+	if err != nil {
+		writeStoreError(w, r, "Order", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(orders)
+}
+
+// GetOrder returns a single order by ID
+func (h *OrderHandler) GetOrder(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id := mux.Vars(r)["id"]
+
+	order, err := h.store.Get(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, r, "Order", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(order)
+}
+
+// CreateOrder places a pending order.
+// Only the customer and each item's bookId and quantity are taken from the
+// request; titles, prices and totals come from the catalogue.
+func (h *OrderHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var order models.Order
+	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+
+	if err := order.Validate(); err != nil {
+		writeStoreError(w, r, "Order", err)
+		return
+	}
+
+	order, err := h.store.Create(r.Context(), order)
+	if err != nil {
+		writeStoreError(w, r, "Order", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(order)
+}
+
+// PayOrder confirms payment of a pending order and takes its items off stock
+func (h *OrderHandler) PayOrder(w http.ResponseWriter, r *http.Request) {
+	h.transition(w, r, models.OrderPaid)
+}
+
+// ShipOrder marks a paid order as shipped
+func (h *OrderHandler) ShipOrder(w http.ResponseWriter, r *http.Request) {
+	h.transition(w, r, models.OrderShipped)
+}
+
+// CancelOrder cancels an order that has not shipped, restocking it if it was paid
+func (h *OrderHandler) CancelOrder(w http.ResponseWriter, r *http.Request) {
+	h.transition(w, r, models.OrderCancelled)
+}
+
+// transition moves the order in the path to status
+func (h *OrderHandler) transition(w http.ResponseWriter, r *http.Request, status string) {
+	w.Header().Set("Content-Type", "application/json")
+	id := mux.Vars(r)["id"]
+
+	order, err := h.store.Transition(r.Context(), id, status)
+	if err != nil {
+		writeStoreError(w, r, "Order", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(order)
+}
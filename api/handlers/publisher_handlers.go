@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/harshakumara/book-api/models"
+	"github.com/harshakumara/book-api/storage"
+)
+
+// PublisherHandler serves the /publishers endpoints
+type PublisherHandler struct {
+	publishers   storage.PublisherStore
+	books        storage.BookStore
+	deletePolicy storage.DeletePolicy
+}
+
+// NewPublisherHandler creates a PublisherHandler.
+// deletePolicy decides what happens to a publisher's books when the publisher is deleted.
+func NewPublisherHandler(publishers storage.PublisherStore, books storage.BookStore, deletePolicy storage.DeletePolicy) *PublisherHandler {
+	return &PublisherHandler{publishers: publishers, books: books, deletePolicy: deletePolicy}
+}
+
+// GetPublishers returns all publishers
+func (h *PublisherHandler) GetPublishers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	publishers, err := h.publishers.List(r.Context())
+	if err != nil {
+		writeStoreError(w, r, "Publisher", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(publishers)
+}
+
+// GetPublisher returns a single publisher by ID
+func (h *PublisherHandler) GetPublisher(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id := mux.Vars(r)["id"]
+
+	publisher, err := h.publishers.Get(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, r, "Publisher", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(publisher)
+}
+
+// CreatePublisher creates a new publisher
+func (h *PublisherHandler) CreatePublisher(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var publisher models.Publisher
+	if err := json.NewDecoder(r.Body).Decode(&publisher); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+
+	publisher, err := h.publishers.Create(r.Context(), publisher)
+	if err != nil {
+		writeStoreError(w, r, "Publisher", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(publisher)
+}
+
+// UpdatePublisher updates a publisher by ID
+func (h *PublisherHandler) UpdatePublisher(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id := mux.Vars(r)["id"]
+
+	var publisher models.Publisher
+	if err := json.NewDecoder(r.Body).Decode(&publisher); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+
+	publisher, err := h.publishers.Update(r.Context(), id, publisher)
+	if err != nil {
+		writeStoreError(w, r, "Publisher", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(publisher)
+}
+
+// DeletePublisher deletes a publisher by ID using the configured delete policy
+func (h *PublisherHandler) DeletePublisher(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id := mux.Vars(r)["id"]
+
+	if err := h.publishers.Delete(r.Context(), id, h.deletePolicy); err != nil {
+		writeStoreError(w, r, "Publisher", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetPublisherBooks returns the publisher's books with the same paging, sorting and
+// filtering options as GET /books
+func (h *PublisherHandler) GetPublisherBooks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id := mux.Vars(r)["id"]
+
+	if _, err := h.publishers.Get(r.Context(), id); err != nil {
+		writeStoreError(w, r, "Publisher", err)
+		return
+	}
+
+	query, err := parseBookQuery(r.URL.Query())
+	if err != nil {
+		writeStoreError(w, r, "Book", err)
+		return
+	}
+	query.Filter.PublisherID = id
+
+	page, err := h.books.List(r.Context(), query)
+	if err != nil {
+		writeStoreError(w, r, "Book", err)
+		return
+	}
+
+	writeBookPage(w, r, page, page.Books)
+}
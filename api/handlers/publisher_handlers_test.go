@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/harshakumara/book-api/config"
+	"github.com/harshakumara/book-api/models"
+	"github.com/harshakumara/book-api/storage"
+)
+
+func TestPublisherBooksAndDeletePolicy(t *testing.T) {
+	fs := config.NewFileStorage(filepath.Join(t.TempDir(), "test_books.json"))
+	books := storage.NewFileBookStore(fs)
+	publishers := storage.NewFilePublisherStore(fs)
+	h := NewPublisherHandler(publishers, books, storage.DeleteReject)
+
+	ctx := context.Background()
+	publisher, _ := publishers.Create(ctx, models.Publisher{Name: "Test Press"})
+	_, _ = books.Create(ctx, models.Book{Title: "From publisher", PublisherID: publisher.ID})
+	_, _ = books.Create(ctx, models.Book{Title: "Self-published"})
+
+	r := mux.NewRouter()
+	r.HandleFunc("/publishers/{id}", h.DeletePublisher).Methods("DELETE")
+	r.HandleFunc("/publishers/{id}/books", h.GetPublisherBooks).Methods("GET")
+
+	req, _ := http.NewRequest("GET", "/publishers/"+publisher.ID+"/books", nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	var responseBooks []models.Book
+	json.Unmarshal(rr.Body.Bytes(), &responseBooks)
+	if rr.Code != http.StatusOK || len(responseBooks) != 1 || responseBooks[0].Title != "From publisher" {
+		t.Errorf("Expected only the publisher's book, got %v %s", rr.Code, rr.Body.String())
+	}
+
+	req, _ = http.NewRequest("GET", "/publishers/missing/books", nil)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown publisher, got %v", rr.Code)
+	}
+
+	req, _ = http.NewRequest("DELETE", "/publishers/"+publisher.ID, nil)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Expected 409 deleting a publisher with books, got %v", rr.Code)
+	}
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/harshakumara/book-api/search"
+	"github.com/harshakumara/book-api/storage"
+)
+
+// parseBookQuery reads pagination, sorting and filter parameters for GET /books
+func parseBookQuery(values url.Values) (storage.BookQuery, error) {
+	var q storage.BookQuery
+	var err error
+
+	if q.Limit, err = intParam(values, "limit"); err != nil {
+		return q, err
+	}
+	if q.Offset, err = intParam(values, "offset"); err != nil {
+		return q, err
+	}
+	q.Cursor = values.Get("cursor")
+
+	if spec := values.Get("sort"); spec != "" {
+		if q.Sort, err = storage.ParseSort(spec); err != nil {
+			return q, err
+		}
+	}
+
+	if q.Filter, err = parseBookFilter(values); err != nil {
+		return q, err
+	}
+
+	return q, q.Validate()
+}
+
+// parseBookFilter reads the filter and facet selection parameters shared by
+// GET /books and the search endpoint
+func parseBookFilter(values url.Values) (storage.BookFilter, error) {
+	var f storage.BookFilter
+	var err error
+
+	f.Genre = values.Get("genre")
+	f.AuthorID = values.Get("authorId")
+	f.PublisherID = values.Get("publisherId")
+	f.PriceBand = values.Get("priceBand")
+	f.Decade = values.Get("decade")
+
+	if f.MinPrice, err = floatParam(values, "minPrice"); err != nil {
+		return f, err
+	}
+	if f.MaxPrice, err = floatParam(values, "maxPrice"); err != nil {
+		return f, err
+	}
+	if raw := values.Get("inStock"); raw != "" {
+		inStock, err := strconv.ParseBool(raw)
+		if err != nil {
+			return f, fmt.Errorf("%w: inStock must be true or false", storage.ErrInvalidQuery)
+		}
+		f.InStock = &inStock
+	}
+
+	return f, f.Validate()
+}
+
+// parseFacets reads the facets parameter. A bare ?facets asks for every
+// facet; nil means none were asked for.
+func parseFacets(values url.Values) ([]string, error) {
+	if !values.Has("facets") {
+		return nil, nil
+	}
+
+	names, err := storage.ParseFacets(values.Get("facets"))
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		names = storage.FacetNames
+	}
+
+	return names, nil
+}
+
+// searchParams are the parameters of a search request
+type searchParams struct {
+	keyword    string
+	query      *storage.StructuredQuery // nil unless the request used ?query
+	mode       string
+	fuzziness  *int // nil unless the request set it
+	limit      int
+	filter     storage.BookFilter
+	facetNames []string
+}
+
+// parseSearchParams reads the parameters of GET /books/search
+func parseSearchParams(values url.Values) (searchParams, error) {
+	p := searchParams{keyword: values.Get("q"), mode: values.Get("mode")}
+	var err error
+
+	if values.Has("query") {
+		if values.Has("q") {
+			return p, fmt.Errorf("%w: q and query cannot be combined", storage.ErrInvalidQuery)
+		}
+		if p.mode != "" || values.Has("fuzziness") {
+			return p, fmt.Errorf("%w: query cannot be combined with mode or fuzziness", storage.ErrInvalidQuery)
+		}
+		if p.query, err = storage.ParseStructuredQuery(values.Get("query")); err != nil {
+			return p, err
+		}
+	} else if p.keyword == "" {
+		return p, fmt.Errorf("%w: search keyword is required", storage.ErrInvalidQuery)
+	}
+	if p.mode != "" && p.mode != "regex" {
+		return p, fmt.Errorf("%w: unknown search mode %q (the only mode is regex)", storage.ErrInvalidQuery, p.mode)
+	}
+
+	if p.limit, err = intParam(values, "limit"); err != nil {
+		return p, err
+	}
+	if p.limit < 0 || p.limit > storage.MaxLimit {
+		return p, fmt.Errorf("%w: limit must be between 0 and %d", storage.ErrInvalidQuery, storage.MaxLimit)
+	}
+
+	switch raw := values.Get("fuzziness"); raw {
+	case "":
+	case "auto":
+		auto := search.AutoFuzziness
+		p.fuzziness = &auto
+	default:
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 || n > search.MaxFuzziness {
+			return p, fmt.Errorf("%w: fuzziness must be auto or between 0 and %d", storage.ErrInvalidQuery, search.MaxFuzziness)
+		}
+		p.fuzziness = &n
+	}
+	if p.fuzziness != nil && p.mode == "regex" {
+		return p, fmt.Errorf("%w: fuzziness cannot be combined with mode=regex", storage.ErrInvalidQuery)
+	}
+
+	if p.filter, err = parseBookFilter(values); err != nil {
+		return p, err
+	}
+	if p.facetNames, err = parseFacets(values); err != nil {
+		return p, err
+	}
+
+	return p, nil
+}
+
+// intParam parses an optional integer query parameter
+func intParam(values url.Values, name string) (int, error) {
+	raw := values.Get(name)
+	if raw == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s must be an integer", storage.ErrInvalidQuery, name)
+	}
+
+	return n, nil
+}
+
+// floatParam parses an optional numeric query parameter
+func floatParam(values url.Values, name string) (*float64, error) {
+	raw := values.Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+
+	f, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s must be a number", storage.ErrInvalidQuery, name)
+	}
+
+	return &f, nil
+}
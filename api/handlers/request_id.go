@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/harshakumara/book-api/models"
+	"github.com/harshakumara/book-api/storage"
+)
+
+// RequestIDHeader carries the request ID in requests and responses
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// RequestID is middleware that tags every request with an ID.
+// A client-supplied X-Request-ID is reused, otherwise a new one is generated.
+// The ID is echoed in the response header and included in error bodies.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" || len(id) > 128 {
+			id = models.NewUUID()
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// RequestIDFromContext returns the ID assigned by RequestID, or "" outside of it
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// ActorHeader names who is making a request, for the audit log. The API
+// has no authentication, so the name is taken on trust.
+const ActorHeader = "X-Actor"
+
+// AnonymousActor is recorded for writes without an X-Actor header
+const AnonymousActor = "anonymous"
+
+// Actor is middleware that attributes the writes a request makes to its
+// X-Actor header and request ID. It must run after RequestID.
+func Actor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor := r.Header.Get(ActorHeader)
+		if actor == "" || len(actor) > 128 {
+			actor = AnonymousActor
+		}
+
+		info := storage.ChangeInfo{Actor: actor, RequestID: RequestIDFromContext(r.Context())}
+		next.ServeHTTP(w, r.WithContext(storage.WithChangeInfo(r.Context(), info)))
+	})
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/harshakumara/book-api/api/handlers"
+)
+
+// ObjectIDPattern matches a MongoDB ObjectID in hex form, as used for book IDs
+const ObjectIDPattern = "[0-9a-fA-F]{24}"
+
+// Handlers groups the handlers served by the API
+type Handlers struct {
+	Books      *handlers.BookHandler
+	Authors    *handlers.AuthorHandler
+	Publishers *handlers.PublisherHandler
+	Inventory  *handlers.InventoryHandler
+	Orders     *handlers.OrderHandler
+	History    *handlers.HistoryHandler
+}
+
+// Route describes a single endpoint
+type Route struct {
+	Name    string
+	Method  string
+	Path    string
+	Handler http.HandlerFunc
+}
+
+// Routes returns the route table in registration order.
+// gorilla/mux picks the first matching route, so static paths such as
+// /books/search must come before parameterized ones, and the catch-all
+// invalid-ID routes come last.
+func Routes(h Handlers) []Route {
+	bookID := "/books/{id:" + ObjectIDPattern + "}"
+
+	return []Route{
+		{"GetBooks", "GET", "/books", h.Books.GetBooks},
+		{"CreateBook", "POST", "/books", h.Books.CreateBook},
+		{"SearchBooks", "GET", "/books/search", h.Books.SearchBooks},
+		{"AutocompleteBooks", "GET", "/books/autocomplete", h.Books.AutocompleteBooks},
+		{"BulkUpsertBooks", "POST", "/books/bulk", h.Books.BulkUpsertBooks},
+		{"BulkDeleteBooks", "POST", "/books/bulk/delete", h.Books.BulkDeleteBooks},
+		{"ExportBooks", "GET", "/books/export", h.Books.ExportBooks},
+		{"ImportBooks", "POST", "/books/import", h.Books.ImportBooks},
+		{"GetBookByISBN", "GET", "/books/isbn/{isbn}", h.Books.GetBookByISBN},
+		{"GetBook", "GET", bookID, h.Books.GetBook},
+		{"UpdateBook", "PUT", bookID, h.Books.UpdateBook},
+		{"PatchBook", "PATCH", bookID, h.Books.PatchBook},
+		{"DeleteBook", "DELETE", bookID, h.Books.DeleteBook},
+		{"AdjustStock", "POST", bookID + "/stock/adjust", h.Inventory.AdjustStock},
+		{"CreateReservation", "POST", bookID + "/reservations", h.Inventory.CreateReservation},
+		{"GetBookHistory", "GET", bookID + "/history", h.History.GetBookHistory},
+		{"RestoreBook", "POST", bookID + "/history/{revisionId}/restore", h.History.RestoreBook},
+
+		{"GetAudit", "GET", "/audit", h.History.GetAudit},
+
+		{"GetReservation", "GET", "/reservations/{id}", h.Inventory.GetReservation},
+		{"ReleaseReservation", "POST", "/reservations/{id}/release", h.Inventory.ReleaseReservation},
+		{"CommitReservation", "POST", "/reservations/{id}/commit", h.Inventory.CommitReservation},
+
+		{"GetOrders", "GET", "/orders", h.Orders.GetOrders},
+		{"CreateOrder", "POST", "/orders", h.Orders.CreateOrder},
+		{"GetOrder", "GET", "/orders/{id}", h.Orders.GetOrder},
+		{"PayOrder", "POST", "/orders/{id}/pay", h.Orders.PayOrder},
+		{"ShipOrder", "POST", "/orders/{id}/ship", h.Orders.ShipOrder},
+		{"CancelOrder", "POST", "/orders/{id}/cancel", h.Orders.CancelOrder},
+
+		{"GetAuthors", "GET", "/authors", h.Authors.GetAuthors},
+		{"CreateAuthor", "POST", "/authors", h.Authors.CreateAuthor},
+		{"GetAuthor", "GET", "/authors/{id}", h.Authors.GetAuthor},
+		{"UpdateAuthor", "PUT", "/authors/{id}", h.Authors.UpdateAuthor},
+		{"DeleteAuthor", "DELETE", "/authors/{id}", h.Authors.DeleteAuthor},
+		{"GetAuthorBooks", "GET", "/authors/{id}/books", h.Authors.GetAuthorBooks},
+
+		{"GetPublishers", "GET", "/publishers", h.Publishers.GetPublishers},
+		{"CreatePublisher", "POST", "/publishers", h.Publishers.CreatePublisher},
+		{"GetPublisher", "GET", "/publishers/{id}", h.Publishers.GetPublisher},
+		{"UpdatePublisher", "PUT", "/publishers/{id}", h.Publishers.UpdatePublisher},
+		{"DeletePublisher", "DELETE", "/publishers/{id}", h.Publishers.DeletePublisher},
+		{"GetPublisherBooks", "GET", "/publishers/{id}/books", h.Publishers.GetPublisherBooks},
+
+		// Any other path segment in a book's position is a malformed book ID
+		{"InvalidBookID", "GET", "/books/{id}", handlers.InvalidID},
+		{"InvalidBookID", "PUT", "/books/{id}", handlers.InvalidID},
+		{"InvalidBookID", "PATCH", "/books/{id}", handlers.InvalidID},
+		{"InvalidBookID", "DELETE", "/books/{id}", handlers.InvalidID},
+		{"InvalidBookID", "POST", "/books/{id}/stock/adjust", handlers.InvalidID},
+		{"InvalidBookID", "POST", "/books/{id}/reservations", handlers.InvalidID},
+		{"InvalidBookID", "GET", "/books/{id}/history", handlers.InvalidID},
+		{"InvalidBookID", "POST", "/books/{id}/history/{revisionId}/restore", handlers.InvalidID},
+	}
+}
+
+// NewRouter builds the HTTP router for the API
+func NewRouter(h Handlers) *mux.Router {
+	r := mux.NewRouter()
+	r.Use(handlers.RequestID, handlers.Actor)
+	r.NotFoundHandler = handlers.RequestID(http.HandlerFunc(handlers.NotFound))
+	r.MethodNotAllowedHandler = handlers.RequestID(http.HandlerFunc(handlers.MethodNotAllowed))
+
+	for _, route := range Routes(h) {
+		r.HandleFunc(route.Path, route.Handler).Methods(route.Method).Name(route.Name)
+	}
+
+	return r
+}
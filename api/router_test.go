@@ -0,0 +1,145 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/harshakumara/book-api/api/handlers"
+	"github.com/harshakumara/book-api/config"
+	"github.com/harshakumara/book-api/models"
+	"github.com/harshakumara/book-api/storage"
+)
+
+func newTestRouter(t *testing.T) (*mux.Router, *config.FileStorage) {
+	fs := config.NewFileStorage(filepath.Join(t.TempDir(), "books.json"))
+	t.Cleanup(func() { fs.Close() })
+
+	revisions := storage.NewFileRevisionStore(fs)
+	books := storage.NewFileBookStore(fs)
+	h := Handlers{
+		Books:      handlers.NewBookHandler(books, false),
+		Authors:    handlers.NewAuthorHandler(storage.NewFileAuthorStore(fs), books, storage.DeleteReject),
+		Publishers: handlers.NewPublisherHandler(storage.NewFilePublisherStore(fs), books, storage.DeleteReject),
+		Inventory:  handlers.NewInventoryHandler(storage.NewFileInventoryStore(fs), time.Minute, time.Hour),
+		Orders:     handlers.NewOrderHandler(storage.NewFileOrderStore(fs)),
+		History:    handlers.NewHistoryHandler(revisions, books, false),
+	}
+
+	return NewRouter(h), fs
+}
+
+// TestRouteTable asserts that every endpoint is reachable at its path and is
+// not shadowed by another route
+func TestRouteTable(t *testing.T) {
+	r, _ := newTestRouter(t)
+
+	const bookID = "67e631732fba00c93c33cd86"
+	const uuid = "e0d91f68-a183-477d-8aa4-1f44ccc78a70"
+
+	tests := []struct {
+		method string
+		path   string
+		route  string
+	}{
+		{"GET", "/books", "GetBooks"},
+		{"POST", "/books", "CreateBook"},
+		{"GET", "/books/search?q=gatsby", "SearchBooks"},
+		{"GET", "/books/autocomplete?prefix=gat", "AutocompleteBooks"},
+		{"POST", "/books/bulk", "BulkUpsertBooks"},
+		{"POST", "/books/bulk/delete", "BulkDeleteBooks"},
+		{"GET", "/books/export?format=csv", "ExportBooks"},
+		{"POST", "/books/import", "ImportBooks"},
+		{"GET", "/books/isbn/978-0-7432-7356-5", "GetBookByISBN"},
+		{"GET", "/books/" + bookID, "GetBook"},
+		{"PUT", "/books/" + bookID, "UpdateBook"},
+		{"PATCH", "/books/" + bookID, "PatchBook"},
+		{"DELETE", "/books/" + bookID, "DeleteBook"},
+		{"GET", "/books/not-an-id", "InvalidBookID"},
+		{"PUT", "/books/not-an-id", "InvalidBookID"},
+		{"PATCH", "/books/not-an-id", "InvalidBookID"},
+		{"DELETE", "/books/not-an-id", "InvalidBookID"},
+		{"POST", "/books/" + bookID + "/stock/adjust", "AdjustStock"},
+		{"POST", "/books/" + bookID + "/reservations", "CreateReservation"},
+		{"POST", "/books/not-an-id/reservations", "InvalidBookID"},
+		{"GET", "/books/" + bookID + "/history", "GetBookHistory"},
+		{"POST", "/books/" + bookID + "/history/" + bookID + "/restore", "RestoreBook"},
+		{"GET", "/books/not-an-id/history", "InvalidBookID"},
+		{"POST", "/books/not-an-id/history/" + bookID + "/restore", "InvalidBookID"},
+		{"GET", "/audit?since=2024-01-01T00:00:00Z", "GetAudit"},
+
+		{"GET", "/reservations/" + uuid, "GetReservation"},
+		{"POST", "/reservations/" + uuid + "/release", "ReleaseReservation"},
+		{"POST", "/reservations/" + uuid + "/commit", "CommitReservation"},
+
+		{"GET", "/orders", "GetOrders"},
+		{"POST", "/orders", "CreateOrder"},
+		{"GET", "/orders/" + uuid, "GetOrder"},
+		{"POST", "/orders/" + uuid + "/pay", "PayOrder"},
+		{"POST", "/orders/" + uuid + "/ship", "ShipOrder"},
+		{"POST", "/orders/" + uuid + "/cancel", "CancelOrder"},
+
+		{"GET", "/authors", "GetAuthors"},
+		{"POST", "/authors", "CreateAuthor"},
+		{"GET", "/authors/" + uuid, "GetAuthor"},
+		{"PUT", "/authors/" + uuid, "UpdateAuthor"},
+		{"DELETE", "/authors/" + uuid, "DeleteAuthor"},
+		{"GET", "/authors/" + uuid + "/books", "GetAuthorBooks"},
+
+		{"GET", "/publishers", "GetPublishers"},
+		{"POST", "/publishers", "CreatePublisher"},
+		{"GET", "/publishers/" + uuid, "GetPublisher"},
+		{"PUT", "/publishers/" + uuid, "UpdatePublisher"},
+		{"DELETE", "/publishers/" + uuid, "DeletePublisher"},
+		{"GET", "/publishers/" + uuid + "/books", "GetPublisherBooks"},
+	}
+
+	covered := map[string]bool{}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+
+		var match mux.RouteMatch
+		if !r.Match(req, &match) || match.Route == nil {
+			t.Errorf("%s %s: no route matched", tt.method, tt.path)
+			continue
+		}
+		if got := match.Route.GetName(); got != tt.route {
+			t.Errorf("%s %s: matched %s, want %s", tt.method, tt.path, got, tt.route)
+		}
+		covered[tt.route] = true
+	}
+
+	// Every registered route must appear in the table above
+	for _, route := range Routes(Handlers{Books: &handlers.BookHandler{}, Authors: &handlers.AuthorHandler{}, Publishers: &handlers.PublisherHandler{}, Inventory: &handlers.InventoryHandler{}, Orders: &handlers.OrderHandler{}, History: &handlers.HistoryHandler{}}) {
+		if !covered[route.Name] {
+			t.Errorf("route %s (%s %s) is not covered by the route table test", route.Name, route.Method, route.Path)
+		}
+	}
+}
+
+func TestSearchIsReachable(t *testing.T) {
+	r, fs := newTestRouter(t)
+	_, _ = storage.NewFileBookStore(fs).Create(context.Background(), models.Book{Title: "The Great Gatsby"})
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/books/search?q=gatsby", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected search to succeed, got %v: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestInvalidBookID(t *testing.T) {
+	r, _ := newTestRouter(t)
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/books/xyz", nil))
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a malformed book ID, got %v", rr.Code)
+	}
+}
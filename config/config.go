@@ -0,0 +1,397 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Storage backends
+const (
+	StorageFile  = "file"
+	StorageMongo = "mongodb"
+)
+
+// Config holds every runtime setting of the API.
+// Values are resolved with the precedence flags > environment > config file > defaults.
+type Config struct {
+	Port     string `yaml:"port"`
+	Storage  string `yaml:"storage"`
+	DataFile string `yaml:"dataFile"`
+	// CompactEvery is the number of journal entries between file snapshot rewrites
+	CompactEvery          int    `yaml:"compactEvery"`
+	AuthorDeletePolicy    string `yaml:"authorDeletePolicy"`
+	PublisherDeletePolicy string `yaml:"publisherDeletePolicy"`
+	// RequireIfMatch refuses book writes that do not carry an If-Match header
+	RequireIfMatch bool            `yaml:"requireIfMatch"`
+	Mongo          MongoConfig     `yaml:"mongo"`
+	Timeouts       TimeoutsConfig  `yaml:"timeouts"`
+	Inventory      InventoryConfig `yaml:"inventory"`
+
+	// Seed loads the sample data on startup; it is a flag/env option only
+	Seed bool `yaml:"-"`
+}
+
+// MongoConfig holds the MongoDB connection settings
+type MongoConfig struct {
+	URI                  string `yaml:"uri"`
+	Database             string `yaml:"database"`
+	BooksCollection      string `yaml:"booksCollection"`
+	AuthorsCollection    string `yaml:"authorsCollection"`
+	PublishersCollection string `yaml:"publishersCollection"`
+	// The inventory, order and audit collections
+	ReservationsCollection string `yaml:"reservationsCollection"`
+	AdjustmentsCollection  string `yaml:"adjustmentsCollection"`
+	OrdersCollection       string `yaml:"ordersCollection"`
+	RevisionsCollection    string `yaml:"revisionsCollection"`
+}
+
+// mongoCollection names a configurable collection setting; its flag is
+// -mongo-<name>-collection and its variable MONGO_<NAME>_COLLECTION
+type mongoCollection struct {
+	name string
+	dst  *string
+}
+
+// envName returns the environment variable setting the collection
+func (c mongoCollection) envName() string {
+	return "MONGO_" + strings.ToUpper(c.name) + "_COLLECTION"
+}
+
+// collections returns every configurable collection name
+func (m *MongoConfig) collections() []mongoCollection {
+	return []mongoCollection{
+		{"books", &m.BooksCollection},
+		{"authors", &m.AuthorsCollection},
+		{"publishers", &m.PublishersCollection},
+		{"reservations", &m.ReservationsCollection},
+		{"adjustments", &m.AdjustmentsCollection},
+		{"orders", &m.OrdersCollection},
+		{"revisions", &m.RevisionsCollection},
+	}
+}
+
+// InventoryConfig holds the stock reservation settings
+type InventoryConfig struct {
+	// ReservationTTL is how long a reservation holds stock unless the request says otherwise
+	ReservationTTL time.Duration `yaml:"reservationTTL"`
+	// MaxReservationTTL caps the TTL a request may ask for
+	MaxReservationTTL time.Duration `yaml:"maxReservationTTL"`
+	// SweepInterval is how often expired reservations are released
+	SweepInterval time.Duration `yaml:"sweepInterval"`
+}
+
+// TimeoutsConfig holds server and storage timeouts
+type TimeoutsConfig struct {
+	// Connect bounds the initial MongoDB connection and ping
+	Connect time.Duration `yaml:"connect"`
+	// Operation bounds every individual storage operation
+	Operation time.Duration `yaml:"operation"`
+	Read      time.Duration `yaml:"read"`
+	Write     time.Duration `yaml:"write"`
+	Idle      time.Duration `yaml:"idle"`
+}
+
+// Default returns the built-in configuration
+func Default() Config {
+	return Config{
+		Port:                  "5001",
+		Storage:               StorageFile,
+		DataFile:              "books.json",
+		CompactEvery:          DefaultCompactEvery,
+		AuthorDeletePolicy:    "reject",
+		PublisherDeletePolicy: "reject",
+		Mongo: MongoConfig{
+			URI:                  "mongodb://localhost:27017",
+			Database:             "bookstore",
+			BooksCollection:      "books",
+			AuthorsCollection:    "authors",
+			PublishersCollection: "publishers",
+			// Named as before they were configurable
+			ReservationsCollection: "reservations",
+			AdjustmentsCollection:  "stock_adjustments",
+			OrdersCollection:       "orders",
+			RevisionsCollection:    "book_revisions",
+		},
+		Timeouts: TimeoutsConfig{
+			Connect:   10 * time.Second,
+			Operation: 10 * time.Second,
+			Read:      15 * time.Second,
+			Write:     30 * time.Second,
+			Idle:      60 * time.Second,
+		},
+		Inventory: InventoryConfig{
+			ReservationTTL:    15 * time.Minute,
+			MaxReservationTTL: 24 * time.Hour,
+			SweepInterval:     time.Minute,
+		},
+	}
+}
+
+// Load resolves the configuration from command line args (without the
+// program name), the environment and an optional YAML file.
+// The file is given by -config or CONFIG_FILE; a missing file is an error
+// only when it was named explicitly.
+func Load(args []string, getenv func(string) string) (*Config, error) {
+	cfg := Default()
+
+	fset := flag.NewFlagSet("book-api", flag.ContinueOnError)
+	configFile := fset.String("config", "", "Path to a YAML config file (env CONFIG_FILE)")
+	useMongo := fset.Bool("mongodb", false, "Use MongoDB for storage instead of file (same as -storage=mongodb)")
+	port := fset.String("port", cfg.Port, "Port to run the server on (env PORT)")
+	storage := fset.String("storage", cfg.Storage, "Storage backend: file or mongodb (env STORAGE)")
+	dataFile := fset.String("data-file", cfg.DataFile, "Path of the books JSON file for file storage (env DATA_FILE)")
+	seed := fset.Bool("seed", false, "Seed the database with sample data (env SEED)")
+	authorPolicy := fset.String("author-delete-policy", cfg.AuthorDeletePolicy, "What to do with an author's books when the author is deleted: reject, cascade or orphan (env AUTHOR_DELETE_POLICY)")
+	publisherPolicy := fset.String("publisher-delete-policy", cfg.PublisherDeletePolicy, "What to do with a publisher's books when the publisher is deleted: reject, cascade or orphan (env PUBLISHER_DELETE_POLICY)")
+	requireIfMatch := fset.Bool("require-if-match", false, "Require an If-Match header on book PUT, PATCH and DELETE (env REQUIRE_IF_MATCH)")
+	mongoURI := fset.String("mongo-uri", "", "MongoDB connection string (env MONGO_URI)")
+	mongoDB := fset.String("mongo-db", cfg.Mongo.Database, "MongoDB database name (env MONGO_DB)")
+	opTimeout := fset.Duration("operation-timeout", cfg.Timeouts.Operation, "Timeout for each storage operation (env OPERATION_TIMEOUT)")
+	collections := map[string]*string{}
+	for _, c := range cfg.Mongo.collections() {
+		flagName := "mongo-" + c.name + "-collection"
+		collections[flagName] = fset.String(flagName, *c.dst, fmt.Sprintf("MongoDB %s collection (env %s)", c.name, c.envName()))
+	}
+	if err := fset.Parse(args); err != nil {
+		return nil, err
+	}
+
+	set := map[string]bool{}
+	fset.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	// Config file
+	path := getenv("CONFIG_FILE")
+	if set["config"] {
+		path = *configFile
+	}
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	// Environment
+	if err := cfg.loadEnv(getenv); err != nil {
+		return nil, err
+	}
+
+	// Flags
+	if set["port"] {
+		cfg.Port = *port
+	}
+	if set["storage"] {
+		cfg.Storage = *storage
+	}
+	if set["mongodb"] && *useMongo {
+		cfg.Storage = StorageMongo
+	}
+	if set["data-file"] {
+		cfg.DataFile = *dataFile
+	}
+	if set["seed"] {
+		cfg.Seed = *seed
+	}
+	if set["author-delete-policy"] {
+		cfg.AuthorDeletePolicy = *authorPolicy
+	}
+	if set["publisher-delete-policy"] {
+		cfg.PublisherDeletePolicy = *publisherPolicy
+	}
+	if set["require-if-match"] {
+		cfg.RequireIfMatch = *requireIfMatch
+	}
+	if set["mongo-uri"] {
+		cfg.Mongo.URI = *mongoURI
+	}
+	if set["mongo-db"] {
+		cfg.Mongo.Database = *mongoDB
+	}
+	if set["operation-timeout"] {
+		cfg.Timeouts.Operation = *opTimeout
+	}
+	for _, c := range cfg.Mongo.collections() {
+		if flagName := "mongo-" + c.name + "-collection"; set[flagName] {
+			*c.dst = *collections[flagName]
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+// loadFile overlays the settings present in a YAML file
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// loadEnv overlays the settings present in the environment
+func (c *Config) loadEnv(getenv func(string) string) error {
+	strs := map[string]*string{
+		"PORT":                    &c.Port,
+		"STORAGE":                 &c.Storage,
+		"DATA_FILE":               &c.DataFile,
+		"AUTHOR_DELETE_POLICY":    &c.AuthorDeletePolicy,
+		"PUBLISHER_DELETE_POLICY": &c.PublisherDeletePolicy,
+		"MONGO_URI":               &c.Mongo.URI,
+		"MONGO_DB":                &c.Mongo.Database,
+	}
+	for _, mc := range c.Mongo.collections() {
+		strs[mc.envName()] = mc.dst
+	}
+	for name, dst := range strs {
+		if v := getenv(name); v != "" {
+			*dst = v
+		}
+	}
+
+	durations := map[string]*time.Duration{
+		"CONNECT_TIMEOUT":            &c.Timeouts.Connect,
+		"OPERATION_TIMEOUT":          &c.Timeouts.Operation,
+		"READ_TIMEOUT":               &c.Timeouts.Read,
+		"WRITE_TIMEOUT":              &c.Timeouts.Write,
+		"IDLE_TIMEOUT":               &c.Timeouts.Idle,
+		"RESERVATION_TTL":            &c.Inventory.ReservationTTL,
+		"MAX_RESERVATION_TTL":        &c.Inventory.MaxReservationTTL,
+		"RESERVATION_SWEEP_INTERVAL": &c.Inventory.SweepInterval,
+	}
+	for name, dst := range durations {
+		if v := getenv(name); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			*dst = d
+		}
+	}
+
+	if v := getenv("COMPACT_EVERY"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("COMPACT_EVERY: %w", err)
+		}
+		c.CompactEvery = n
+	}
+
+	if v := getenv("REQUIRE_IF_MATCH"); v != "" {
+		require, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("REQUIRE_IF_MATCH: %w", err)
+		}
+		c.RequireIfMatch = require
+	}
+
+	if v := getenv("SEED"); v != "" {
+		seed, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("SEED: %w", err)
+		}
+		c.Seed = seed
+	}
+
+	return nil
+}
+
+// Validate checks that the configuration is usable, reporting every problem at once
+func (c *Config) Validate() error {
+	var problems []string
+
+	if n, err := strconv.Atoi(c.Port); err != nil || n < 1 || n > 65535 {
+		problems = append(problems, fmt.Sprintf("port %q must be a number between 1 and 65535", c.Port))
+	}
+
+	switch c.Storage {
+	case StorageFile:
+		if c.DataFile == "" {
+			problems = append(problems, "dataFile is required for file storage")
+		}
+		if c.CompactEvery < 1 {
+			problems = append(problems, "compactEvery must be at least 1")
+		}
+	case StorageMongo:
+		if c.Mongo.URI == "" {
+			problems = append(problems, "mongo.uri (MONGO_URI) is required for mongodb storage")
+		}
+		empty := c.Mongo.Database == ""
+		for _, mc := range c.Mongo.collections() {
+			empty = empty || *mc.dst == ""
+		}
+		if empty {
+			problems = append(problems, "mongo database and collection names must not be empty")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("storage %q must be %q or %q", c.Storage, StorageFile, StorageMongo))
+	}
+
+	policies := []struct {
+		name   string
+		policy string
+	}{
+		{"authorDeletePolicy", c.AuthorDeletePolicy},
+		{"publisherDeletePolicy", c.PublisherDeletePolicy},
+	}
+	for _, p := range policies {
+		switch p.policy {
+		case "reject", "cascade", "orphan":
+		default:
+			problems = append(problems, fmt.Sprintf("%s %q must be reject, cascade or orphan", p.name, p.policy))
+		}
+	}
+
+	timeouts := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"connect", c.Timeouts.Connect},
+		{"operation", c.Timeouts.Operation},
+		{"read", c.Timeouts.Read},
+		{"write", c.Timeouts.Write},
+		{"idle", c.Timeouts.Idle},
+	}
+	for _, t := range timeouts {
+		if t.d <= 0 {
+			problems = append(problems, fmt.Sprintf("timeouts.%s must be positive", t.name))
+		}
+	}
+
+	inventory := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"reservationTTL", c.Inventory.ReservationTTL},
+		{"maxReservationTTL", c.Inventory.MaxReservationTTL},
+		{"sweepInterval", c.Inventory.SweepInterval},
+	}
+	for _, t := range inventory {
+		if t.d <= 0 {
+			problems = append(problems, fmt.Sprintf("inventory.%s must be positive", t.name))
+		}
+	}
+	if c.Inventory.ReservationTTL > c.Inventory.MaxReservationTTL {
+		problems = append(problems, "inventory.reservationTTL must not exceed inventory.maxReservationTTL")
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func envFrom(vars map[string]string) func(string) string {
+	return func(name string) string { return vars[name] }
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := Load(nil, envFrom(nil))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if cfg.Port != "5001" || cfg.Storage != StorageFile || cfg.DataFile != "books.json" {
+		t.Errorf("Unexpected defaults: %+v", cfg)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := `
+port: "6000"
+dataFile: /var/lib/books/books.json
+mongo:
+  database: fromfile
+  booksCollection: catalogue
+timeouts:
+  operation: 3s
+`
+	if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	env := envFrom(map[string]string{
+		"CONFIG_FILE": path,
+		"PORT":        "7000",
+		"MONGO_DB":    "fromenv",
+	})
+
+	cfg, err := Load([]string{"-port", "8000"}, env)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if cfg.Port != "8000" {
+		t.Errorf("Expected flag to win for port, got %s", cfg.Port)
+	}
+	if cfg.Mongo.Database != "fromenv" {
+		t.Errorf("Expected env to win over file for database, got %s", cfg.Mongo.Database)
+	}
+	if cfg.Mongo.BooksCollection != "catalogue" || cfg.DataFile != "/var/lib/books/books.json" {
+		t.Errorf("Expected file values to be applied, got %+v", cfg)
+	}
+	if cfg.Mongo.AuthorsCollection != "authors" {
+		t.Errorf("Expected defaults for unset values, got %s", cfg.Mongo.AuthorsCollection)
+	}
+	if cfg.Timeouts.Operation != 3*time.Second {
+		t.Errorf("Expected operation timeout from file, got %v", cfg.Timeouts.Operation)
+	}
+}
+
+func TestLoadMongoFlagAndValidation(t *testing.T) {
+	cfg, err := Load([]string{"-mongodb"}, envFrom(map[string]string{"MONGO_URI": "mongodb://db:27017"}))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Storage != StorageMongo || cfg.Mongo.URI != "mongodb://db:27017" {
+		t.Errorf("Expected mongodb storage with env URI, got %+v", cfg)
+	}
+
+	_, err = Load([]string{"-port", "abc", "-storage", "redis"}, envFrom(map[string]string{"READ_TIMEOUT": "0s"}))
+	if err == nil {
+		t.Fatal("Expected invalid configuration to be rejected")
+	}
+	for _, want := range []string{"port", "storage", "timeouts.read"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to mention %s, got %v", want, err)
+		}
+	}
+
+	if _, err := Load([]string{"-config", "missing.yaml"}, envFrom(nil)); err == nil {
+		t.Error("Expected an explicitly named missing config file to be an error")
+	}
+}
+
+func TestLoadMongoCollections(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := `
+mongo:
+  ordersCollection: file_orders
+  revisionsCollection: file_revisions
+`
+	if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	env := envFrom(map[string]string{
+		"CONFIG_FILE":                   path,
+		"MONGO_RESERVATIONS_COLLECTION": "env_reservations",
+		"MONGO_REVISIONS_COLLECTION":    "env_revisions",
+	})
+
+	cfg, err := Load([]string{"-mongo-adjustments-collection", "flag_adjustments"}, env)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	m := cfg.Mongo
+	if m.OrdersCollection != "file_orders" || m.RevisionsCollection != "env_revisions" ||
+		m.ReservationsCollection != "env_reservations" || m.AdjustmentsCollection != "flag_adjustments" {
+		t.Errorf("Expected collections from file, env and flags, got %+v", m)
+	}
+
+	if _, err := Load([]string{"-mongodb", "-mongo-orders-collection", ""}, envFrom(nil)); err == nil || !strings.Contains(err.Error(), "collection") {
+		t.Errorf("Expected an empty collection name to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,230 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/harshakumara/book-api/models"
+)
+
+// Dataset holds every collection kept by FileStorage.
+// Each collection is persisted as its own JSON array next to the books file.
+type Dataset struct {
+	Books            []models.Book
+	Authors          []models.Author
+	Publishers       []models.Publisher
+	Reservations     []models.Reservation
+	StockAdjustments []models.StockAdjustment
+	Orders           []models.Order
+	Revisions        []models.Revision
+}
+
+// collections describes how each Dataset field is stored and journaled.
+// The books collection always uses the FileStorage path itself.
+var collections = []collection{
+	docCollection[models.Book]{
+		name:  "books",
+		id:    func(b models.Book) string { return b.ID.Hex() },
+		field: func(ds *Dataset) *[]models.Book { return &ds.Books },
+	},
+	docCollection[models.Author]{
+		name:  "authors",
+		id:    func(a models.Author) string { return a.ID },
+		field: func(ds *Dataset) *[]models.Author { return &ds.Authors },
+	},
+	docCollection[models.Publisher]{
+		name:  "publishers",
+		id:    func(p models.Publisher) string { return p.ID },
+		field: func(ds *Dataset) *[]models.Publisher { return &ds.Publishers },
+	},
+	docCollection[models.Reservation]{
+		name:  "reservations",
+		id:    func(r models.Reservation) string { return r.ID },
+		field: func(ds *Dataset) *[]models.Reservation { return &ds.Reservations },
+	},
+	docCollection[models.StockAdjustment]{
+		name:  "stock_adjustments",
+		id:    func(a models.StockAdjustment) string { return a.ID },
+		field: func(ds *Dataset) *[]models.StockAdjustment { return &ds.StockAdjustments },
+	},
+	docCollection[models.Order]{
+		name:  "orders",
+		id:    func(o models.Order) string { return o.ID },
+		field: func(ds *Dataset) *[]models.Order { return &ds.Orders },
+	},
+	docCollection[models.Revision]{
+		name:  "revisions",
+		id:    func(r models.Revision) string { return r.ID.Hex() },
+		field: func(ds *Dataset) *[]models.Revision { return &ds.Revisions },
+	},
+}
+
+// collection is the type-erased view of a docCollection
+type collection interface {
+	collectionName() string
+	clone(dst, src *Dataset)
+	load(ds *Dataset, data []byte) error
+	marshal(ds *Dataset) ([]byte, error)
+	diff(before, after *Dataset) ([]journalOp, error)
+	apply(ds *Dataset, op journalOp) error
+}
+
+// docCollection stores a slice of documents of type T keyed by id
+type docCollection[T any] struct {
+	name  string
+	id    func(T) string
+	field func(*Dataset) *[]T
+}
+
+func (c docCollection[T]) collectionName() string {
+	return c.name
+}
+
+// clone copies the collection's slice from src into dst
+func (c docCollection[T]) clone(dst, src *Dataset) {
+	*c.field(dst) = append([]T{}, *c.field(src)...)
+}
+
+// load parses a snapshot file into the dataset
+func (c docCollection[T]) load(ds *Dataset, data []byte) error {
+	var docs []T
+	if err := json.Unmarshal(data, &docs); err != nil {
+		return err
+	}
+	if docs == nil {
+		docs = []T{}
+	}
+
+	*c.field(ds) = docs
+	return nil
+}
+
+// marshal renders the collection as a snapshot file
+func (c docCollection[T]) marshal(ds *Dataset) ([]byte, error) {
+	docs := *c.field(ds)
+	if docs == nil {
+		docs = []T{}
+	}
+
+	return json.MarshalIndent(docs, "", "  ")
+}
+
+// diff returns the operations that turn the collection in before into after
+func (c docCollection[T]) diff(before, after *Dataset) ([]journalOp, error) {
+	prev := *c.field(before)
+	next := *c.field(after)
+
+	old := make(map[string]T, len(prev))
+	for _, doc := range prev {
+		old[c.id(doc)] = doc
+	}
+
+	var ops []journalOp
+	for _, doc := range next {
+		id := c.id(doc)
+		if o, ok := old[id]; !ok || !reflect.DeepEqual(o, doc) {
+			data, err := json.Marshal(doc)
+			if err != nil {
+				return nil, err
+			}
+			ops = append(ops, journalOp{Collection: c.name, Op: opPut, ID: id, Doc: data})
+		}
+		delete(old, id)
+	}
+
+	// Iterate prev rather than the map to keep the journal deterministic
+	for _, doc := range prev {
+		if id := c.id(doc); hasKey(old, id) {
+			ops = append(ops, journalOp{Collection: c.name, Op: opDelete, ID: id})
+		}
+	}
+
+	return ops, nil
+}
+
+// apply replays a single operation onto the dataset
+func (c docCollection[T]) apply(ds *Dataset, op journalOp) error {
+	docs := c.field(ds)
+
+	switch op.Op {
+	case opPut:
+		var doc T
+		if err := json.Unmarshal(op.Doc, &doc); err != nil {
+			return err
+		}
+		for i := range *docs {
+			if c.id((*docs)[i]) == op.ID {
+				(*docs)[i] = doc
+				return nil
+			}
+		}
+		*docs = append(*docs, doc)
+	case opDelete:
+		for i := range *docs {
+			if c.id((*docs)[i]) == op.ID {
+				*docs = append((*docs)[:i], (*docs)[i+1:]...)
+				return nil
+			}
+		}
+	default:
+		return fmt.Errorf("unknown journal operation %q", op.Op)
+	}
+
+	return nil
+}
+
+// clone returns a copy of the dataset whose slices can be modified freely
+func (ds *Dataset) clone() Dataset {
+	var out Dataset
+	for _, c := range collections {
+		c.clone(&out, ds)
+	}
+
+	return out
+}
+
+// diffDataset returns the operations that turn before into after
+func diffDataset(before, after *Dataset) ([]journalOp, error) {
+	var ops []journalOp
+	for _, c := range collections {
+		collOps, err := c.diff(before, after)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, collOps...)
+	}
+
+	return ops, nil
+}
+
+// applyOps replays operations onto the dataset
+func applyOps(ds *Dataset, ops []journalOp) error {
+	for _, op := range ops {
+		c := collectionByName(op.Collection)
+		if c == nil {
+			return fmt.Errorf("unknown collection %q in journal", op.Collection)
+		}
+		if err := c.apply(ds, op); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// collectionByName looks up a registered collection
+func collectionByName(name string) collection {
+	for _, c := range collections {
+		if c.collectionName() == name {
+			return c
+		}
+	}
+
+	return nil
+}
+
+func hasKey[T any](m map[string]T, key string) bool {
+	_, ok := m[key]
+	return ok
+}
@@ -2,40 +2,98 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/harshakumara/book-api/models"
 )
 
-// FileStorage represents a file-based data storage
+// DefaultCompactEvery is the number of journal entries after which the
+// snapshots are rewritten and the journal truncated
+const DefaultCompactEvery = 100
+
+// FileStorage represents a file-based data storage.
+// A single instance should be shared by everything that touches the files so
+// that its lock serializes writers.
+//
+// Books are stored in the given file and every other collection in a sibling
+// file (authors.json, ...). All collections are kept in memory. Every
+// transaction is appended to a shared write-ahead journal (<file>.journal)
+// and fsynced before it is acknowledged; the JSON snapshots are rewritten
+// atomically when the journal is compacted.
 type FileStorage struct {
-	filePath string
-	mutex    sync.RWMutex
+	filePath     string
+	journalPath  string
+	mutex        sync.RWMutex
+	data         Dataset
+	journal      *os.File
+	entries      int
+	compactEvery int
+	quarantined  []string
 }
 
 // NewFileStorage creates a new instance of FileStorage
 func NewFileStorage(filePath string) *FileStorage {
-	// Create file if it doesn't exist
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		file, err := os.Create(filePath)
-		if err != nil {
-			log.Fatalf("Failed to create file: %v", err)
-		}
-		defer file.Close()
+	fs, err := OpenFileStorage(filePath)
+	if err != nil {
+		log.Fatalf("Failed to open file storage: %v", err)
+	}
+
+	return fs
+}
+
+// OpenFileStorage loads the snapshots, replays the journal and compacts it.
+// A snapshot that cannot be parsed is moved aside rather than failing.
+func OpenFileStorage(filePath string) (*FileStorage, error) {
+	fs := &FileStorage{
+		filePath:     filePath,
+		journalPath:  filePath + ".journal",
+		compactEvery: DefaultCompactEvery,
+	}
 
-		// Initialize with empty array
-		if _, err := file.Write([]byte("[]")); err != nil {
-			log.Fatalf("Failed to initialize file: %v", err)
+	for _, c := range collections {
+		if err := fs.loadSnapshot(c); err != nil {
+			return nil, err
 		}
 	}
 
-	return &FileStorage{
-		filePath: filePath,
-		mutex:    sync.RWMutex{},
+	if err := fs.replayJournal(); err != nil {
+		return nil, err
+	}
+
+	journal, err := os.OpenFile(fs.journalPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
 	}
+	fs.journal = journal
+
+	if err := fs.compact(); err != nil {
+		journal.Close()
+		return nil, err
+	}
+
+	return fs, nil
+}
+
+// Quarantined returns the paths corrupt snapshots were moved to on open
+func (fs *FileStorage) Quarantined() []string {
+	return fs.quarantined
+}
+
+// SetCompactEvery changes how many journal entries trigger a compaction
+func (fs *FileStorage) SetCompactEvery(n int) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if n < 1 {
+		n = 1
+	}
+	fs.compactEvery = n
 }
 
 // ReadBooks reads all books from the file
@@ -43,28 +101,223 @@ func (fs *FileStorage) ReadBooks() ([]models.Book, error) {
 	fs.mutex.RLock()
 	defer fs.mutex.RUnlock()
 
-	data, err := ioutil.ReadFile(fs.filePath)
-	if err != nil {
-		return nil, err
-	}
+	return append([]models.Book{}, fs.data.Books...), nil
+}
 
-	var books []models.Book
-	if err := json.Unmarshal(data, &books); err != nil {
-		return nil, err
+// WriteBooks replaces every stored book
+func (fs *FileStorage) WriteBooks(books []models.Book) error {
+	return fs.Update(func([]models.Book) ([]models.Book, error) {
+		return append([]models.Book{}, books...), nil
+	})
+}
+
+// Update runs a read-modify-write transaction on the stored books.
+// The write lock is held for the whole call, so concurrent updates are
+// applied one after another. If fn returns an error nothing is written.
+func (fs *FileStorage) Update(fn func([]models.Book) ([]models.Book, error)) error {
+	return fs.Transact(func(ds *Dataset) error {
+		books, err := fn(ds.Books)
+		if err != nil {
+			return err
+		}
+
+		ds.Books = books
+		return nil
+	})
+}
+
+// View runs fn with read access to every collection.
+// fn must not modify the dataset or keep references to it after returning.
+func (fs *FileStorage) View(fn func(ds *Dataset) error) error {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	return fn(&fs.data)
+}
+
+// Transact runs a read-modify-write transaction across every collection.
+// fn receives a private copy of the dataset; its changes are journaled and
+// applied atomically if it returns nil, and discarded otherwise.
+func (fs *FileStorage) Transact(fn func(ds *Dataset) error) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	next := fs.data.clone()
+	if err := fn(&next); err != nil {
+		return err
 	}
 
-	return books, nil
+	return fs.commit(next)
 }
 
-// WriteBooks writes books to the file
-func (fs *FileStorage) WriteBooks(books []models.Book) error {
+// Close compacts the journal and releases the file handle
+func (fs *FileStorage) Close() error {
 	fs.mutex.Lock()
 	defer fs.mutex.Unlock()
 
-	data, err := json.MarshalIndent(books, "", "  ")
+	if fs.journal == nil {
+		return nil
+	}
+
+	err := fs.compact()
+	if cerr := fs.journal.Close(); err == nil {
+		err = cerr
+	}
+	fs.journal = nil
+
+	return err
+}
+
+// snapshotPath returns the file a collection is persisted in
+func (fs *FileStorage) snapshotPath(c collection) string {
+	if c.collectionName() == "books" {
+		return fs.filePath
+	}
+
+	return filepath.Join(filepath.Dir(fs.filePath), c.collectionName()+".json")
+}
+
+// commit journals the changes from the current state to next and applies
+// them; the caller must hold the write lock
+func (fs *FileStorage) commit(next Dataset) error {
+	if fs.journal == nil {
+		return fmt.Errorf("file storage %s is closed", fs.filePath)
+	}
+
+	ops, err := diffDataset(&fs.data, &next)
 	if err != nil {
 		return err
 	}
+	if len(ops) == 0 {
+		return nil
+	}
+
+	line, err := json.Marshal(journalEntry{Ops: ops})
+	if err != nil {
+		return err
+	}
+	if err := fs.appendJournal(append(line, '\n')); err != nil {
+		return err
+	}
+
+	fs.data = next
+	fs.entries++
+
+	if fs.entries >= fs.compactEvery {
+		// The entry is durable in the journal, so the write has committed;
+		// compaction is retried on the next commit
+		if err := fs.compact(); err != nil {
+			log.Printf("WARNING: compacting %s failed: %v", fs.journalPath, err)
+		}
+	}
+
+	return nil
+}
+
+// appendJournal appends a line to the journal and syncs it. If either fails
+// the journal is cut back to its previous size, so a partial line never has
+// later entries appended after it; if even that fails the journal is closed
+// and further writes are refused. The caller must hold the write lock.
+func (fs *FileStorage) appendJournal(line []byte) error {
+	info, err := fs.journal.Stat()
+	if err != nil {
+		return err
+	}
+
+	_, err = fs.journal.Write(line)
+	if err == nil {
+		err = fs.journal.Sync()
+	}
+	if err == nil {
+		return nil
+	}
+
+	if terr := fs.journal.Truncate(info.Size()); terr != nil {
+		fs.journal.Close()
+		fs.journal = nil
+		return fmt.Errorf("%w (and the journal could not be rolled back, so it was closed: %v)", err, terr)
+	}
+
+	return err
+}
+
+// compact writes the in-memory state as the new snapshots and truncates the
+// journal; the caller must hold the write lock
+func (fs *FileStorage) compact() error {
+	for _, c := range collections {
+		data, err := c.marshal(&fs.data)
+		if err != nil {
+			return err
+		}
+
+		if err := writeFileAtomic(fs.snapshotPath(c), data, 0644); err != nil {
+			return err
+		}
+	}
+
+	// Only drop the journal once every snapshot is durable
+	if err := fs.journal.Truncate(0); err != nil {
+		return err
+	}
+	fs.entries = 0
+
+	return fs.journal.Sync()
+}
+
+// loadSnapshot reads a collection's snapshot, quarantining it if it is corrupt
+func (fs *FileStorage) loadSnapshot(c collection) error {
+	path := fs.snapshotPath(c)
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c.load(&fs.data, []byte("[]"))
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := c.load(&fs.data, data); err != nil {
+		quarantine := fmt.Sprintf("%s.corrupt-%s", path, time.Now().UTC().Format("20060102T150405Z"))
+		if rerr := os.Rename(path, quarantine); rerr != nil {
+			return fmt.Errorf("snapshot %s is corrupt (%v) and could not be quarantined: %w", path, err, rerr)
+		}
+
+		log.Printf("WARNING: %s is corrupt (%v); moved to %s and starting from the journal only", path, err, quarantine)
+		fs.quarantined = append(fs.quarantined, quarantine)
+		return c.load(&fs.data, []byte("[]"))
+	}
+
+	return nil
+}
+
+// replayJournal applies every complete journal entry to the loaded snapshots
+func (fs *FileStorage) replayJournal() error {
+	file, err := os.Open(fs.journalPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	entries, torn, err := readJournal(file)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", fs.journalPath, err)
+	}
+
+	for _, entry := range entries {
+		if err := applyOps(&fs.data, entry.Ops); err != nil {
+			return fmt.Errorf("replaying %s: %w", fs.journalPath, err)
+		}
+	}
+
+	if len(entries) > 0 {
+		log.Printf("Replayed %d journal entries from %s", len(entries), fs.journalPath)
+	}
+	if torn {
+		log.Printf("WARNING: discarded an incomplete trailing entry in %s", fs.journalPath)
+	}
 
-	return ioutil.WriteFile(fs.filePath, data, 0644)
+	return nil
 }
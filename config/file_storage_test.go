@@ -0,0 +1,167 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/harshakumara/book-api/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestFileStorageReplaysJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "books.json")
+
+	fs, err := OpenFileStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+
+	book := models.Book{ID: primitive.NewObjectID(), Title: "Journaled"}
+	if err := fs.Update(func(books []models.Book) ([]models.Book, error) {
+		return append(books, book), nil
+	}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	// Simulate a crash: the snapshot was never rewritten, only the journal has the book
+	snapshot, _ := os.ReadFile(path)
+	if strings.Contains(string(snapshot), "Journaled") {
+		t.Fatal("Expected the snapshot to be untouched before compaction")
+	}
+
+	reopened, err := OpenFileStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen storage: %v", err)
+	}
+	defer reopened.Close()
+
+	books, _ := reopened.ReadBooks()
+	if len(books) != 1 || books[0].Title != "Journaled" {
+		t.Fatalf("Expected journaled book after replay, got %+v", books)
+	}
+
+	// Reopening compacts the journal into the snapshot
+	snapshot, _ = os.ReadFile(path)
+	if !strings.Contains(string(snapshot), "Journaled") {
+		t.Error("Expected the snapshot to contain the book after compaction")
+	}
+	if info, err := os.Stat(path + ".journal"); err != nil || info.Size() != 0 {
+		t.Errorf("Expected an empty journal after compaction, got %v (%v)", info, err)
+	}
+}
+
+func TestFileStorageIgnoresTornJournalEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "books.json")
+
+	fs, err := OpenFileStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	if err := fs.WriteBooks([]models.Book{{ID: primitive.NewObjectID(), Title: "Committed"}}); err != nil {
+		t.Fatalf("WriteBooks failed: %v", err)
+	}
+
+	// Append half of an entry, as if the process died mid-write
+	journal, _ := os.OpenFile(path+".journal", os.O_WRONLY|os.O_APPEND, 0644)
+	journal.WriteString(`{"ops":[{"op":"delete","id":`)
+	journal.Close()
+
+	reopened, err := OpenFileStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen storage: %v", err)
+	}
+	defer reopened.Close()
+
+	books, _ := reopened.ReadBooks()
+	if len(books) != 1 || books[0].Title != "Committed" {
+		t.Errorf("Expected only the committed book, got %+v", books)
+	}
+}
+
+func TestFileStorageQuarantinesCorruptSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "books.json")
+	if err := os.WriteFile(path, []byte(`[{"title": "trunc`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fs, err := OpenFileStorage(path)
+	if err != nil {
+		t.Fatalf("Expected corrupt snapshot to be recovered, got %v", err)
+	}
+	defer fs.Close()
+
+	if len(fs.Quarantined()) != 1 {
+		t.Fatalf("Expected the corrupt snapshot to be quarantined, got %v", fs.Quarantined())
+	}
+	if _, err := os.Stat(fs.Quarantined()[0]); err != nil {
+		t.Errorf("Expected quarantined file to exist: %v", err)
+	}
+
+	books, err := fs.ReadBooks()
+	if err != nil || len(books) != 0 {
+		t.Errorf("Expected an empty, readable store, got %v (%v)", books, err)
+	}
+}
+
+func TestFileStorageRejectsCorruptJournalEntryBeforeOthers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "books.json")
+
+	fs, err := OpenFileStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	if err := fs.WriteBooks([]models.Book{{ID: primitive.NewObjectID(), Title: "First"}}); err != nil {
+		t.Fatalf("WriteBooks failed: %v", err)
+	}
+
+	// A partial line followed by an acknowledged entry must not be read as a torn tail
+	journal, _ := os.OpenFile(path+".journal", os.O_WRONLY|os.O_APPEND, 0644)
+	journal.WriteString(`{"ops":[{"op":"delete","id":` + "\n")
+	journal.Close()
+	if err := fs.WriteBooks([]models.Book{{ID: primitive.NewObjectID(), Title: "Second"}}); err != nil {
+		t.Fatalf("WriteBooks failed: %v", err)
+	}
+
+	if _, err := OpenFileStorage(path); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("Expected the open to fail on journal line 2, got %v", err)
+	}
+
+	// Nothing was compacted away
+	data, _ := os.ReadFile(path + ".journal")
+	if !strings.Contains(string(data), "Second") {
+		t.Error("Expected the journal to keep the later entry")
+	}
+}
+
+func TestReadJournal(t *testing.T) {
+	entry := `{"ops":[{"collection":"books","op":"delete","id":"a"}]}`
+
+	tests := []struct {
+		name    string
+		journal string
+		entries int
+		torn    bool
+		wantErr bool
+	}{
+		{"empty", "", 0, false, false},
+		{"complete", entry + "\n" + entry + "\n", 2, false, false},
+		{"torn tail", entry + "\n" + `{"ops":[`, 1, true, false},
+		{"unterminated tail", entry + "\n" + entry, 1, true, false},
+		{"corrupt final line", entry + "\n" + "garbage\n", 1, true, false},
+		{"corrupt middle line", entry + "\n" + "garbage\n" + entry + "\n", 0, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, torn, err := readJournal(strings.NewReader(tt.journal))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if len(entries) != tt.entries || torn != tt.torn {
+				t.Errorf("Expected %d entries (torn %v), got %d (torn %v)", tt.entries, tt.torn, len(entries), torn)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Journal operations
+const (
+	opPut    = "put"
+	opDelete = "delete"
+)
+
+// journalOp is a single idempotent mutation of one collection
+type journalOp struct {
+	Collection string          `json:"collection"`
+	Op         string          `json:"op"`
+	ID         string          `json:"id"`
+	Doc        json.RawMessage `json:"doc,omitempty"`
+}
+
+// journalEntry is one committed transaction; each entry is one line in the journal
+type journalEntry struct {
+	Ops []journalOp `json:"ops"`
+}
+
+// readJournal returns every complete entry in the journal.
+// A torn final line left by a crash mid-append is reported via torn and
+// skipped. A line that cannot be parsed anywhere else means entries after
+// it would be lost, so it is an error.
+func readJournal(r io.Reader) (entries []journalEntry, torn bool, err error) {
+	reader := bufio.NewReader(r)
+	bad := 0
+	for n := 1; ; n++ {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, false, err
+		}
+
+		if len(bytes.TrimSpace(line)) > 0 {
+			if bad > 0 {
+				return nil, false, fmt.Errorf("journal line %d is corrupt and is followed by more entries", bad)
+			}
+
+			var entry journalEntry
+			if jerr := json.Unmarshal(line, &entry); jerr != nil || err == io.EOF {
+				// A line without its trailing newline was never fully written
+				bad = n
+			} else {
+				entries = append(entries, entry)
+			}
+		}
+
+		if err == io.EOF {
+			return entries, bad > 0, nil
+		}
+	}
+}
+
+// writeFileAtomic replaces path with data so readers see either the old or the
+// new content, never a partial write
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	// Clean up the temp file on any failure
+	ok := false
+	defer func() {
+		if !ok {
+			tmp.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	ok = true
+
+	syncDir(dir)
+	return nil
+}
+
+// syncDir flushes a directory entry so a rename survives a crash.
+// Not every platform supports syncing directories, so errors are ignored.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	defer d.Close()
+	d.Sync()
+}
@@ -1,4 +1,4 @@
-import React from 'react';
+import React, { useEffect, useState } from 'react';
 import { 
   AppBar, 
   Toolbar, 
@@ -7,6 +7,7 @@ import {
   Button,
   Box,
   InputBase,
+  Autocomplete,
   alpha,
   styled
 } from '@mui/material';
@@ -16,6 +17,10 @@ import {
   MenuBook as MenuBookIcon
 } from '@mui/icons-material';
 import { Link as RouterLink } from 'react-router-dom';
+import api from '../services/api';
+
+// Wait this long after the last keystroke before asking for completions
+const AUTOCOMPLETE_DELAY_MS = 150;
 
 const Search = styled('div')(({ theme }) => ({
   position: 'relative',
@@ -58,9 +63,53 @@ const StyledInputBase = styled(InputBase)(({ theme }) => ({
   },
 }));
 
-const Navbar = ({ onAddBook, onSearch }) => {
-  const handleSearchChange = (event) => {
-    onSearch(event.target.value);
+const Navbar = ({ onAddBook, onSearch, onSelectISBN }) => {
+  const [input, setInput] = useState('');
+  const [completions, setCompletions] = useState([]);
+
+  // Fetch live suggestions for what has been typed so far
+  useEffect(() => {
+    const prefix = input.trim();
+    if (!prefix) {
+      setCompletions([]);
+      return undefined;
+    }
+
+    let active = true;
+    const timer = setTimeout(async () => {
+      try {
+        const results = await api.autocompleteBooks(prefix);
+        if (active) setCompletions(results);
+      } catch (err) {
+        if (active) setCompletions([]);
+      }
+    }, AUTOCOMPLETE_DELAY_MS);
+
+    return () => {
+      active = false;
+      clearTimeout(timer);
+    };
+  }, [input]);
+
+  const handleInputChange = (event, value) => {
+    setInput(value);
+    // Clearing the box shows every book again
+    if (!value) {
+      onSearch('');
+    }
+  };
+
+  // Run the full search only when a suggestion is picked or Enter is pressed.
+  // An ISBN names exactly one book, so picking one looks that book up directly.
+  const handleSearchSubmit = (event, value) => {
+    if (!value) return;
+    if (typeof value === 'string') {
+      onSearch(value);
+    } else if (value.kind === 'isbn' && onSelectISBN) {
+      onSelectISBN(value.text);
+    } else {
+      onSearch(value.text);
+    }
   };
 
   return (
@@ -101,10 +150,32 @@ const Navbar = ({ onAddBook, onSearch }) => {
               <SearchIconWrapper>
                 <SearchIcon />
               </SearchIconWrapper>
-              <StyledInputBase
-                placeholder="Search…"
-                inputProps={{ 'aria-label': 'search' }}
-                onChange={handleSearchChange}
+              <Autocomplete
+                freeSolo
+                options={completions}
+                filterOptions={(options) => options}
+                getOptionLabel={(option) => (typeof option === 'string' ? option : option.text)}
+                inputValue={input}
+                onInputChange={handleInputChange}
+                onChange={handleSearchSubmit}
+                componentsProps={{ popper: { sx: { minWidth: 320 } } }}
+                renderOption={(props, option) => (
+                  <li {...props} key={`${option.kind}:${option.id}:${option.text}`}>
+                    <Typography variant="body2" sx={{ flexGrow: 1 }}>
+                      {option.text}
+                    </Typography>
+                    <Typography variant="caption" color="text.secondary" sx={{ ml: 1 }}>
+                      {option.kind}
+                    </Typography>
+                  </li>
+                )}
+                renderInput={(params) => (
+                  <StyledInputBase
+                    ref={params.InputProps.ref}
+                    inputProps={{ ...params.inputProps, 'aria-label': 'search' }}
+                    placeholder="Search…"
+                  />
+                )}
               />
             </Search>
             
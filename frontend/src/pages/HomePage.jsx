@@ -81,6 +81,23 @@ const HomePage = () => {
     }
   };
 
+  // Show the single book with a picked ISBN
+  const handleSelectISBN = async (isbn) => {
+    try {
+      setLoading(true);
+      const book = await api.getBookByISBN(isbn);
+      setFilteredBooks([book]);
+      setLoading(false);
+    } catch (err) {
+      setNotification({
+        open: true,
+        message: err.apiError ? err.apiError.message : 'Error looking up ISBN',
+        severity: 'error'
+      });
+      setLoading(false);
+    }
+  };
+
   // Open form for adding a new book
   const handleAddBook = () => {
     setSelectedBook(null);
@@ -98,7 +115,7 @@ const HomePage = () => {
     try {
       if (selectedBook) {
         // Update existing book
-        await api.updateBook(selectedBook.bookId, bookData);
+        await api.updateBook(selectedBook.bookId, bookData, selectedBook.version);
         setNotification({
           open: true,
           message: 'Book updated successfully',
@@ -118,7 +135,9 @@ const HomePage = () => {
     } catch (err) {
       setNotification({
         open: true,
-        message: selectedBook ? 'Failed to update book' : 'Failed to add book',
+        message: err.apiError
+          ? err.apiError.message + (err.apiError.details ? ': ' + err.apiError.details.map((d) => `${d.field} ${d.message}`).join(', ') : '')
+          : selectedBook ? 'Failed to update book' : 'Failed to add book',
         severity: 'error'
       });
     }
@@ -133,7 +152,8 @@ const HomePage = () => {
   // Delete book after confirmation
   const handleDeleteConfirm = async () => {
     try {
-      await api.deleteBook(bookToDelete);
+      const book = books.find((b) => b.bookId === bookToDelete);
+      await api.deleteBook(bookToDelete, book && book.version);
       setNotification({
         open: true,
         message: 'Book deleted successfully',
@@ -144,7 +164,7 @@ const HomePage = () => {
     } catch (err) {
       setNotification({
         open: true,
-        message: 'Failed to delete book',
+        message: err.apiError ? err.apiError.message : 'Failed to delete book',
         severity: 'error'
       });
     }
@@ -159,7 +179,7 @@ const HomePage = () => {
 
   return (
     <>
-      <Navbar onAddBook={handleAddBook} onSearch={handleSearch} />
+      <Navbar onAddBook={handleAddBook} onSearch={handleSearch} onSelectISBN={handleSelectISBN} />
       <Container sx={{ py: 4 }} maxWidth="lg">
         {/* Page Title */}
         <Typography variant="h4" component="h1" gutterBottom>
@@ -2,6 +2,20 @@ import axios from 'axios';
 
 const API_URL = '/books'; // Using proxy in package.json
 
+// Attach the API's error envelope ({ error: { code, message, details, requestId } })
+// to the axios error as `apiError` so callers can show a meaningful message
+const toApiError = (error) => {
+  const body = error.response && error.response.data && error.response.data.error;
+  if (body) {
+    error.apiError = body;
+  }
+  return error;
+};
+
+// Send the version a book was read at so the API rejects the write (412)
+// if someone else changed the book in the meantime
+const ifMatch = (version) => (version !== undefined ? { headers: { 'If-Match': `"${version}"` } } : {});
+
 const api = {
   // Fetch all books
   getBooks: async () => {
@@ -10,7 +24,7 @@ const api = {
       return response.data;
     } catch (error) {
       console.error('Error fetching books:', error);
-      throw error;
+      throw toApiError(error);
     }
   },
 
@@ -21,7 +35,18 @@ const api = {
       return response.data;
     } catch (error) {
       console.error(`Error fetching book with ID ${id}:`, error);
-      throw error;
+      throw toApiError(error);
+    }
+  },
+
+  // Fetch the book with an exact ISBN
+  getBookByISBN: async (isbn) => {
+    try {
+      const response = await axios.get(`${API_URL}/isbn/${encodeURIComponent(isbn)}`);
+      return response.data;
+    } catch (error) {
+      console.error(`Error fetching book with ISBN ${isbn}:`, error);
+      throw toApiError(error);
     }
   },
 
@@ -32,29 +57,29 @@ const api = {
       return response.data;
     } catch (error) {
       console.error('Error creating book:', error);
-      throw error;
+      throw toApiError(error);
     }
   },
 
-  // Update a book
-  updateBook: async (id, bookData) => {
+  // Update a book, optionally only if it is still at the given version
+  updateBook: async (id, bookData, version) => {
     try {
-      const response = await axios.put(`${API_URL}/${id}`, bookData);
+      const response = await axios.put(`${API_URL}/${id}`, bookData, ifMatch(version));
       return response.data;
     } catch (error) {
       console.error(`Error updating book with ID ${id}:`, error);
-      throw error;
+      throw toApiError(error);
     }
   },
 
-  // Delete a book
-  deleteBook: async (id) => {
+  // Delete a book, optionally only if it is still at the given version
+  deleteBook: async (id, version) => {
     try {
-      await axios.delete(`${API_URL}/${id}`);
+      await axios.delete(`${API_URL}/${id}`, ifMatch(version));
       return true;
     } catch (error) {
       console.error(`Error deleting book with ID ${id}:`, error);
-      throw error;
+      throw toApiError(error);
     }
   },
 
@@ -65,7 +90,18 @@ const api = {
       return response.data;
     } catch (error) {
       console.error(`Error searching books with query "${query}":`, error);
-      throw error;
+      throw toApiError(error);
+    }
+  },
+
+  // Complete a partly typed search with matching titles, author names and ISBNs
+  autocompleteBooks: async (prefix, limit = 8) => {
+    try {
+      const response = await axios.get(`${API_URL}/autocomplete?prefix=${encodeURIComponent(prefix)}&limit=${limit}`);
+      return response.data;
+    } catch (error) {
+      console.error(`Error completing "${prefix}":`, error);
+      throw toApiError(error);
     }
   }
 };
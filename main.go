@@ -1,65 +1,155 @@
 package main
 
 import (
-	"flag"
+	"context"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
-	"github.com/gorilla/mux"
+	"github.com/harshakumara/book-api/api"
 	"github.com/harshakumara/book-api/api/handlers"
 	"github.com/harshakumara/book-api/config"
+	"github.com/harshakumara/book-api/storage"
 	"github.com/harshakumara/book-api/utils"
 )
 
 func main() {
-	// Command line flags
-	useMongoDb := flag.Bool("mongodb", false, "Use MongoDB for storage instead of file")
-	port := flag.String("port", "5001", "Port to run the server on")
-	seedData := flag.Bool("seed", false, "Seed the database with sample data")
-	flag.Parse()
+	// Resolve configuration from flags, environment and config file
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	authorPolicy, err := storage.ParseDeletePolicy(cfg.AuthorDeletePolicy)
+	if err != nil {
+		log.Fatal(err)
+	}
+	publisherPolicy, err := storage.ParseDeletePolicy(cfg.PublisherDeletePolicy)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// Initialize storage
-	if *useMongoDb {
+	var store storage.BookStore
+	var authorStore storage.AuthorStore
+	var publisherStore storage.PublisherStore
+	var inventoryStore storage.InventoryStore
+	var orderStore storage.OrderStore
+	var revisionStore storage.RevisionStore
+	if cfg.Storage == config.StorageMongo {
 		log.Println("Using MongoDB for storage")
-		config.ConnectDB()
+		db, err := config.ConnectDB(cfg)
+		if err != nil {
+			log.Fatalf("Failed to connect to MongoDB: %v", err)
+		}
+
+		opts := storage.MongoOptions{
+			BooksCollection:        cfg.Mongo.BooksCollection,
+			AuthorsCollection:      cfg.Mongo.AuthorsCollection,
+			PublishersCollection:   cfg.Mongo.PublishersCollection,
+			ReservationsCollection: cfg.Mongo.ReservationsCollection,
+			AdjustmentsCollection:  cfg.Mongo.AdjustmentsCollection,
+			OrdersCollection:       cfg.Mongo.OrdersCollection,
+			RevisionsCollection:    cfg.Mongo.RevisionsCollection,
+			Timeout:                cfg.Timeouts.Operation,
+		}
+		books := storage.NewMongoBookStore(db, opts)
+		if err := books.EnsureIndexes(context.Background()); err != nil {
+			log.Fatalf("Failed to create book indexes (are there books with the same ISBN?): %v", err)
+		}
+		authorStore = storage.NewMongoAuthorStore(db, opts)
+		publisherStore = storage.NewMongoPublisherStore(db, opts)
+		inventoryStore = storage.NewMongoInventoryStore(db, opts)
+		orderStore = storage.NewMongoOrderStore(db, opts)
+		revisions := storage.NewMongoRevisionStore(db, opts)
+		if err := revisions.EnsureIndexes(context.Background()); err != nil {
+			log.Fatalf("Failed to create revision indexes: %v", err)
+		}
+		revisionStore = revisions
+
+		// Record every book write in the audit log. The other stores record
+		// the book changes they make themselves.
+		store = storage.NewAuditedBookStore(books, revisions)
 
 		// Seed MongoDB if flag is set
-		if *seedData {
+		if cfg.Seed {
 			log.Println("Seeding MongoDB with sample data...")
 			// MongoDB seeding happens on /books endpoint access
 		}
 	} else {
 		log.Println("Using file-based storage")
-		// Create the storage file if it doesn't exist
-		config.NewFileStorage("books.json")
-
 		// Seed file storage if flag is set
-		if *seedData {
+		if cfg.Seed {
 			log.Println("Seeding file storage with sample data...")
-			if err := utils.SeedFileStorage("books.json"); err != nil {
+			if err := utils.SeedFileStorage(cfg.DataFile); err != nil {
 				log.Fatalf("Failed to seed file storage: %v", err)
 			}
 		}
+
+		// Create the storage files if they don't exist
+		fs := config.NewFileStorage(cfg.DataFile)
+		fs.SetCompactEvery(cfg.CompactEvery)
+		// Every file store records its book changes in the audit log in the
+		// same transaction as the change
+		store = storage.NewFileBookStore(fs)
+		authorStore = storage.NewFileAuthorStore(fs)
+		publisherStore = storage.NewFilePublisherStore(fs)
+		inventoryStore = storage.NewFileInventoryStore(fs)
+		orderStore = storage.NewFileOrderStore(fs)
+		revisionStore = storage.NewFileRevisionStore(fs)
 	}
 
-	// Initialize router
-	r := mux.NewRouter()
+	// Index every book for full-text search
+	indexed, err := storage.NewIndexedBookStore(context.Background(), store, authorStore)
+	if err != nil {
+		log.Fatalf("Failed to build the search index: %v", err)
+	}
+	store = indexed
+	authorStore = indexed.Authors(authorStore)
+	publisherStore = indexed.Publishers(publisherStore)
 
-	// Register routes
-	r.HandleFunc("/books", handlers.GetBooks).Methods("GET")
-	r.HandleFunc("/books", handlers.CreateBook).Methods("POST")
-	r.HandleFunc("/books/{id}", handlers.GetBook).Methods("GET")
-	r.HandleFunc("/books/{id}", handlers.UpdateBook).Methods("PUT")
-	r.HandleFunc("/books/{id}", handlers.DeleteBook).Methods("DELETE")
-	r.HandleFunc("/books/search", handlers.SearchBooks).Methods("GET")
+	// Release expired reservations back into stock
+	go sweepReservations(inventoryStore, cfg.Inventory.SweepInterval)
+
+	// Initialize router
+	r := api.NewRouter(api.Handlers{
+		Books:      handlers.NewBookHandler(store, cfg.RequireIfMatch),
+		Authors:    handlers.NewAuthorHandler(authorStore, store, authorPolicy),
+		Publishers: handlers.NewPublisherHandler(publisherStore, store, publisherPolicy),
+		Inventory:  handlers.NewInventoryHandler(inventoryStore, cfg.Inventory.ReservationTTL, cfg.Inventory.MaxReservationTTL),
+		Orders:     handlers.NewOrderHandler(orderStore),
+		History:    handlers.NewHistoryHandler(revisionStore, store, cfg.RequireIfMatch),
+	})
 
 	// Set up server
-	serverPort := *port
-	if envPort := os.Getenv("PORT"); envPort != "" {
-		serverPort = envPort
+	server := &http.Server{
+		Addr:         ":" + cfg.Port,
+		Handler:      r,
+		ReadTimeout:  cfg.Timeouts.Read,
+		WriteTimeout: cfg.Timeouts.Write,
+		IdleTimeout:  cfg.Timeouts.Idle,
 	}
 
-	log.Printf("Server starting on port %s...\n", serverPort)
-	log.Fatal(http.ListenAndServe(":"+serverPort, r))
+	log.Printf("Server starting on port %s...\n", cfg.Port)
+	log.Fatal(server.ListenAndServe())
+}
+
+// sweepReservations expires overdue reservations every interval
+func sweepReservations(store storage.InventoryStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// The restocked books' revisions are attributed to the sweeper
+	ctx := storage.WithChangeInfo(context.Background(), storage.ChangeInfo{Actor: "reservation-sweeper"})
+	for now := range ticker.C {
+		n, err := store.ExpireReservations(ctx, now)
+		if err != nil {
+			log.Printf("Failed to expire reservations: %v", err)
+			continue
+		}
+		if n > 0 {
+			log.Printf("Expired %d reservation(s)", n)
+		}
+	}
 }
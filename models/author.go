@@ -0,0 +1,11 @@
+package models
+
+// Author represents the author entity.
+// Author IDs are UUID strings, matching the values already stored in Book.AuthorID.
+type Author struct {
+	ID          string `json:"authorId" bson:"_id"`
+	Name        string `json:"name" bson:"name"`
+	Biography   string `json:"biography" bson:"biography"`
+	BirthDate   string `json:"birthDate" bson:"birthDate"`
+	Nationality string `json:"nationality" bson:"nationality"`
+}
@@ -17,4 +17,12 @@ type Book struct {
 	Description     string             `json:"description" bson:"description"`
 	Price           float64            `json:"price" bson:"price"`
 	Quantity        int                `json:"quantity" bson:"quantity"`
+	// Version is incremented on every change and served as the book's ETag
+	Version int64 `json:"version" bson:"version"`
+}
+
+// Normalize puts the book's fields in canonical form before it is validated
+// and stored
+func (b *Book) Normalize() {
+	b.ISBN = NormalizeISBN(b.ISBN)
 }
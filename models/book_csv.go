@@ -0,0 +1,104 @@
+package models
+
+import (
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// BookColumns names the CSV columns of a book, in Book field order.
+// They match the book's JSON field names.
+var BookColumns = []string{
+	"bookId",
+	"authorId",
+	"publisherId",
+	"title",
+	"publicationDate",
+	"isbn",
+	"pages",
+	"genre",
+	"description",
+	"price",
+	"quantity",
+	"version",
+}
+
+// CSVRecord returns the book as a CSV row in BookColumns order
+func (b Book) CSVRecord() []string {
+	id := ""
+	if !b.ID.IsZero() {
+		id = b.ID.Hex()
+	}
+
+	return []string{
+		id,
+		b.AuthorID,
+		b.PublisherID,
+		b.Title,
+		b.PublicationDate,
+		b.ISBN,
+		strconv.Itoa(b.Pages),
+		b.Genre,
+		b.Description,
+		strconv.FormatFloat(b.Price, 'f', -1, 64),
+		strconv.Itoa(b.Quantity),
+		strconv.FormatInt(b.Version, 10),
+	}
+}
+
+// SetCSVField sets the field of a BookColumns column from its CSV text.
+// An empty cell sets the zero value. A cell that does not parse is
+// reported as a FieldError.
+func (b *Book) SetCSVField(column, value string) *FieldError {
+	var err error
+	switch column {
+	case "bookId":
+		b.ID = primitive.NilObjectID
+		if value != "" {
+			b.ID, err = primitive.ObjectIDFromHex(value)
+		}
+	case "authorId":
+		b.AuthorID = value
+	case "publisherId":
+		b.PublisherID = value
+	case "title":
+		b.Title = value
+	case "publicationDate":
+		b.PublicationDate = value
+	case "isbn":
+		b.ISBN = value
+	case "pages":
+		b.Pages, err = atoiOrZero(value)
+	case "genre":
+		b.Genre = value
+	case "description":
+		b.Description = value
+	case "price":
+		b.Price = 0
+		if value != "" {
+			b.Price, err = strconv.ParseFloat(value, 64)
+		}
+	case "quantity":
+		b.Quantity, err = atoiOrZero(value)
+	case "version":
+		b.Version = 0
+		if value != "" {
+			b.Version, err = strconv.ParseInt(value, 10, 64)
+		}
+	default:
+		return &FieldError{Field: column, Message: "is not a book column"}
+	}
+
+	if err != nil {
+		return &FieldError{Field: column, Message: "cannot parse " + strconv.Quote(value)}
+	}
+	return nil
+}
+
+// atoiOrZero parses an integer cell, treating an empty cell as 0
+func atoiOrZero(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(value)
+}
@@ -2,6 +2,8 @@ package models
 
 import (
 	"encoding/json"
+	"reflect"
+	"strings"
 	"testing"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -71,3 +73,46 @@ func TestBookSerialization(t *testing.T) {
 		t.Errorf("Expected Quantity %d, got %d", book.Quantity, unmarshaledBook.Quantity)
 	}
 }
+
+func TestBookColumnsMatchFields(t *testing.T) {
+	typ := reflect.TypeOf(Book{})
+	if typ.NumField() != len(BookColumns) {
+		t.Fatalf("Expected %d columns, got %d", typ.NumField(), len(BookColumns))
+	}
+
+	for i, column := range BookColumns {
+		tag := strings.Split(typ.Field(i).Tag.Get("json"), ",")[0]
+		if column != tag {
+			t.Errorf("Column %d: expected %q, got %q", i, tag, column)
+		}
+	}
+}
+
+func TestBookCSVRoundTrip(t *testing.T) {
+	book := Book{
+		ID:       primitive.NewObjectID(),
+		Title:    "Dune, Part One",
+		ISBN:     "9780441172719",
+		Pages:    412,
+		Price:    9.99,
+		Quantity: 3,
+		Version:  7,
+	}
+
+	var got Book
+	for i, value := range book.CSVRecord() {
+		if fe := got.SetCSVField(BookColumns[i], value); fe != nil {
+			t.Fatalf("SetCSVField(%s) failed: %v", BookColumns[i], fe.Message)
+		}
+	}
+	if got != book {
+		t.Errorf("Expected %+v, got %+v", book, got)
+	}
+
+	if fe := got.SetCSVField("pages", "many"); fe == nil || fe.Field != "pages" {
+		t.Errorf("Expected a pages error, got %+v", fe)
+	}
+	if fe := got.SetCSVField("colour", "red"); fe == nil {
+		t.Error("Expected an error for an unknown column")
+	}
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Reservation states
+const (
+	ReservationActive    = "active"
+	ReservationCommitted = "committed"
+	ReservationReleased  = "released"
+	ReservationExpired   = "expired"
+)
+
+// Reservation holds stock of a book for a limited time.
+// The reserved quantity is taken off Book.Quantity when the reservation is
+// created and given back if it is released or expires; committing it makes
+// the decrement permanent.
+type Reservation struct {
+	ID        string             `json:"reservationId" bson:"_id"`
+	BookID    primitive.ObjectID `json:"bookId" bson:"bookId"`
+	Quantity  int                `json:"quantity" bson:"quantity"`
+	Status    string             `json:"status" bson:"status"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+	ExpiresAt time.Time          `json:"expiresAt" bson:"expiresAt"`
+	UpdatedAt time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
+
+// StockAdjustment records a manual change to a book's quantity
+type StockAdjustment struct {
+	ID            string             `json:"adjustmentId" bson:"_id"`
+	BookID        primitive.ObjectID `json:"bookId" bson:"bookId"`
+	Delta         int                `json:"delta" bson:"delta"`
+	Reason        string             `json:"reason" bson:"reason"`
+	QuantityAfter int                `json:"quantityAfter" bson:"quantityAfter"`
+	CreatedAt     time.Time          `json:"createdAt" bson:"createdAt"`
+}
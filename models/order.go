@@ -0,0 +1,77 @@
+package models
+
+import (
+	"math"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Order states
+const (
+	OrderPending   = "pending"
+	OrderPaid      = "paid"
+	OrderShipped   = "shipped"
+	OrderCancelled = "cancelled"
+)
+
+// orderTransitions lists the states each order state may move to
+var orderTransitions = map[string][]string{
+	OrderPending: {OrderPaid, OrderCancelled},
+	OrderPaid:    {OrderShipped, OrderCancelled},
+}
+
+// CanTransition reports whether an order may move from one state to another
+func CanTransition(from, to string) bool {
+	for _, s := range orderTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Order is a sale of one or more books.
+// Prices are copied from the books when the order is placed, and stock is
+// taken off the books when the order is paid.
+type Order struct {
+	ID        string      `json:"orderId" bson:"_id"`
+	Customer  string      `json:"customer" bson:"customer"`
+	Items     []OrderItem `json:"items" bson:"items"`
+	Total     float64     `json:"total" bson:"total"`
+	Status    string      `json:"status" bson:"status"`
+	CreatedAt time.Time   `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time   `json:"updatedAt" bson:"updatedAt"`
+}
+
+// OrderItem is a line of an order
+type OrderItem struct {
+	BookID   primitive.ObjectID `json:"bookId" bson:"bookId"`
+	Title    string             `json:"title" bson:"title"`
+	Quantity int                `json:"quantity" bson:"quantity"`
+	// UnitPrice is the book's price when the order was placed
+	UnitPrice float64 `json:"unitPrice" bson:"unitPrice"`
+	Subtotal  float64 `json:"subtotal" bson:"subtotal"`
+}
+
+// PriceItems snapshots each item's title and price from books, keyed by
+// book ID, and recomputes the subtotals and total
+func (o *Order) PriceItems(books map[primitive.ObjectID]Book) {
+	total := 0.0
+	for i := range o.Items {
+		item := &o.Items[i]
+		book := books[item.BookID]
+		item.Title = book.Title
+		item.UnitPrice = book.Price
+		item.Subtotal = roundCents(book.Price * float64(item.Quantity))
+		total += item.Subtotal
+	}
+
+	o.Total = roundCents(total)
+}
+
+// roundCents rounds an amount to two decimal places
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,18 @@
+package models
+
+// Contact holds a publisher's contact details
+type Contact struct {
+	Email   string `json:"email" bson:"email"`
+	Phone   string `json:"phone" bson:"phone"`
+	Website string `json:"website" bson:"website"`
+}
+
+// Publisher represents the publisher entity.
+// Publisher IDs are UUID strings, matching the values already stored in Book.PublisherID.
+type Publisher struct {
+	ID       string   `json:"publisherId" bson:"_id"`
+	Name     string   `json:"name" bson:"name"`
+	Country  string   `json:"country" bson:"country"`
+	Contact  Contact  `json:"contact" bson:"contact"`
+	Imprints []string `json:"imprints" bson:"imprints"`
+}
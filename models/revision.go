@@ -0,0 +1,82 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Revision actions
+const (
+	RevisionCreate  = "create"
+	RevisionUpdate  = "update"
+	RevisionDelete  = "delete"
+	RevisionRestore = "restore"
+)
+
+// Revision is the immutable record of one change to a book
+type Revision struct {
+	ID     primitive.ObjectID `json:"revisionId" bson:"_id"`
+	BookID primitive.ObjectID `json:"bookId" bson:"bookId"`
+	Action string             `json:"action" bson:"action"`
+	// Version is the book's version after the change, or before a delete
+	Version   int64     `json:"version" bson:"version"`
+	Actor     string    `json:"actor" bson:"actor"`
+	RequestID string    `json:"requestId,omitempty" bson:"requestId,omitempty"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+	// RestoredFrom is the revision a restore went back to
+	RestoredFrom string        `json:"restoredFrom,omitempty" bson:"restoredFrom,omitempty"`
+	Changes      []FieldChange `json:"changes" bson:"changes"`
+	// Book is the book as the change left it; nil for deletes
+	Book *Book `json:"book,omitempty" bson:"book,omitempty"`
+}
+
+// FieldChange is the value of one book field before and after a change.
+// A field a book did not have yet, or no longer has, is null.
+type FieldChange struct {
+	Field  string      `json:"field" bson:"field"`
+	Before interface{} `json:"before" bson:"before"`
+	After  interface{} `json:"after" bson:"after"`
+}
+
+// DiffBooks returns the fields that differ between two states of a book,
+// named as in JSON and in alphabetical order. Either state may be nil.
+// The ID and version are not compared.
+func DiffBooks(before, after *Book) []FieldChange {
+	old, next := bookMap(before), bookMap(after)
+
+	fields := map[string]bool{}
+	for field := range old {
+		fields[field] = true
+	}
+	for field := range next {
+		fields[field] = true
+	}
+	delete(fields, "bookId")
+	delete(fields, "version")
+
+	changes := []FieldChange{}
+	for field := range fields {
+		if !reflect.DeepEqual(old[field], next[field]) {
+			changes = append(changes, FieldChange{Field: field, Before: old[field], After: next[field]})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+
+	return changes
+}
+
+// bookMap returns a book's fields as they appear in JSON, or nil
+func bookMap(b *Book) map[string]interface{} {
+	if b == nil {
+		return nil
+	}
+
+	data, _ := json.Marshal(b)
+	var m map[string]interface{}
+	json.Unmarshal(data, &m)
+	return m
+}
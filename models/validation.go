@@ -0,0 +1,198 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Field length limits enforced by Book.Validate
+const (
+	MaxTitleLength       = 300
+	MaxDescriptionLength = 5000
+	MaxGenreLength       = 100
+	MaxReferenceLength   = 100
+)
+
+// FieldError describes a single invalid field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every invalid field of a record
+type ValidationError struct {
+	Errors []FieldError `json:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fe.Field + ": " + fe.Message
+	}
+
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+// add records a field error
+func (e *ValidationError) add(field, format string, args ...interface{}) {
+	e.Errors = append(e.Errors, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// errOrNil returns e as an error only if it holds field errors
+func (e *ValidationError) errOrNil() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+
+	return e
+}
+
+// Validate checks the book's fields and returns a *ValidationError listing
+// every problem, or nil if the book is valid.
+// ISBN and publication date are optional but must be well-formed when set.
+func (b Book) Validate() error {
+	errs := &ValidationError{}
+
+	if strings.TrimSpace(b.Title) == "" {
+		errs.add("title", "is required")
+	} else if utf8.RuneCountInString(b.Title) > MaxTitleLength {
+		errs.add("title", "must be at most %d characters", MaxTitleLength)
+	}
+	if utf8.RuneCountInString(b.Description) > MaxDescriptionLength {
+		errs.add("description", "must be at most %d characters", MaxDescriptionLength)
+	}
+	if utf8.RuneCountInString(b.Genre) > MaxGenreLength {
+		errs.add("genre", "must be at most %d characters", MaxGenreLength)
+	}
+	if len(b.AuthorID) > MaxReferenceLength {
+		errs.add("authorId", "must be at most %d characters", MaxReferenceLength)
+	}
+	if len(b.PublisherID) > MaxReferenceLength {
+		errs.add("publisherId", "must be at most %d characters", MaxReferenceLength)
+	}
+
+	if b.ISBN != "" && !ValidISBN(b.ISBN) {
+		errs.add("isbn", "must be a valid ISBN-10 or ISBN-13")
+	}
+	if b.PublicationDate != "" && !ValidDate(b.PublicationDate) {
+		errs.add("publicationDate", "must be an ISO-8601 date (YYYY-MM-DD, YYYY-MM or YYYY)")
+	}
+
+	if b.Pages < 0 {
+		errs.add("pages", "must not be negative")
+	}
+	if b.Price < 0 {
+		errs.add("price", "must not be negative")
+	}
+	if b.Quantity < 0 {
+		errs.add("quantity", "must not be negative")
+	}
+
+	return errs.errOrNil()
+}
+
+// Validate checks an order as submitted by a client: it needs at least one
+// item, and every item needs a book and a positive quantity.
+// A book may appear only once per order.
+func (o Order) Validate() error {
+	errs := &ValidationError{}
+
+	if len(o.Items) == 0 {
+		errs.add("items", "must contain at least one item")
+	}
+	if utf8.RuneCountInString(o.Customer) > MaxReferenceLength {
+		errs.add("customer", "must be at most %d characters", MaxReferenceLength)
+	}
+
+	seen := map[string]bool{}
+	for i, item := range o.Items {
+		field := fmt.Sprintf("items[%d]", i)
+		if item.BookID.IsZero() {
+			errs.add(field+".bookId", "is required")
+		} else if seen[item.BookID.Hex()] {
+			errs.add(field+".bookId", "appears more than once")
+		}
+		seen[item.BookID.Hex()] = true
+
+		if item.Quantity <= 0 {
+			errs.add(field+".quantity", "must be positive")
+		}
+	}
+
+	return errs.errOrNil()
+}
+
+// ValidDate reports whether s is an ISO-8601 calendar date at day, month or year precision
+func ValidDate(s string) bool {
+	for _, layout := range []string{"2006-01-02", "2006-01", "2006"} {
+		if _, err := time.Parse(layout, s); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ValidISBN reports whether s is an ISBN-10 or ISBN-13 with a correct check digit.
+// Hyphens and spaces are ignored.
+func ValidISBN(s string) bool {
+	digits := strings.NewReplacer("-", "", " ", "").Replace(s)
+
+	switch len(digits) {
+	case 10:
+		sum := 0
+		for i, c := range digits {
+			var d int
+			switch {
+			case c >= '0' && c <= '9':
+				d = int(c - '0')
+			case (c == 'X' || c == 'x') && i == 9:
+				d = 10
+			default:
+				return false
+			}
+			sum += (10 - i) * d
+		}
+		return sum%11 == 0
+	case 13:
+		sum := 0
+		for i, c := range digits {
+			if c < '0' || c > '9' {
+				return false
+			}
+			d := int(c - '0')
+			if i%2 == 1 {
+				d *= 3
+			}
+			sum += d
+		}
+		return sum%10 == 0
+	}
+
+	return false
+}
+
+// NormalizeISBN returns the canonical form of an ISBN: hyphens and spaces
+// are removed and a valid ISBN-10 is converted to its ISBN-13.
+// Anything that is not a valid ISBN is returned with only the separators
+// removed, for Validate to report.
+func NormalizeISBN(s string) string {
+	digits := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(s))
+	if len(digits) != 10 || !ValidISBN(digits) {
+		return digits
+	}
+
+	isbn := "978" + digits[:9]
+	sum := 0
+	for i, c := range isbn {
+		d := int(c - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+
+	return isbn + string(rune('0'+(10-sum%10)%10))
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestValidISBN(t *testing.T) {
+	valid := []string{"9780743273565", "978-0-7432-7356-5", "0306406152", "0-8044-2957-X", "080442957x"}
+	for _, isbn := range valid {
+		if !ValidISBN(isbn) {
+			t.Errorf("Expected %q to be valid", isbn)
+		}
+	}
+
+	invalid := []string{"9780743273566", "0306406153", "12345", "97807432735X5", "X306406152", ""}
+	for _, isbn := range invalid {
+		if ValidISBN(isbn) {
+			t.Errorf("Expected %q to be invalid", isbn)
+		}
+	}
+}
+
+func TestNormalizeISBN(t *testing.T) {
+	tests := map[string]string{
+		"978-0-7432-7356-5": "9780743273565",
+		"0306406152":        "9780306406157",
+		"0-8044-2957-x":     "9780804429573",
+		"0 306 40615 3":     "0306406153",
+		"":                  "",
+	}
+
+	for in, want := range tests {
+		if got := NormalizeISBN(in); got != want {
+			t.Errorf("NormalizeISBN(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestBookValidate(t *testing.T) {
+	book := Book{Title: "The Great Gatsby", ISBN: "9780743273565", PublicationDate: "1925-04-10", Pages: 180, Price: 15.99, Quantity: 5}
+	if err := book.Validate(); err != nil {
+		t.Fatalf("Expected valid book, got %v", err)
+	}
+
+	bad := Book{
+		Title:           strings.Repeat("x", MaxTitleLength+1),
+		ISBN:            "9780743273566",
+		PublicationDate: "10/04/1925",
+		Pages:           -1,
+		Price:           -0.5,
+		Quantity:        -3,
+	}
+
+	var verr *ValidationError
+	if err := bad.Validate(); !errors.As(err, &verr) {
+		t.Fatalf("Expected ValidationError, got %v", err)
+	}
+
+	fields := map[string]bool{}
+	for _, fe := range verr.Errors {
+		fields[fe.Field] = true
+	}
+	for _, want := range []string{"title", "isbn", "publicationDate", "pages", "price", "quantity"} {
+		if !fields[want] {
+			t.Errorf("Expected an error for %s, got %+v", want, verr.Errors)
+		}
+	}
+
+	if err := (Book{}).Validate(); err == nil {
+		t.Error("Expected a book without a title to be invalid")
+	}
+}
+
+func TestOrderValidate(t *testing.T) {
+	book := primitive.NewObjectID()
+
+	valid := Order{Items: []OrderItem{{BookID: book, Quantity: 1}}}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("Expected valid order, got %v", err)
+	}
+
+	bad := Order{Items: []OrderItem{{BookID: book, Quantity: 1}, {BookID: book, Quantity: 0}, {Quantity: 2}}}
+
+	var verr *ValidationError
+	if err := bad.Validate(); !errors.As(err, &verr) {
+		t.Fatalf("Expected *ValidationError, got %v", err)
+	}
+	fields := map[string]bool{}
+	for _, fe := range verr.Errors {
+		fields[fe.Field] = true
+	}
+	for _, f := range []string{"items[1].bookId", "items[1].quantity", "items[2].bookId"} {
+		if !fields[f] {
+			t.Errorf("Expected an error for %s, got %v", f, verr.Errors)
+		}
+	}
+
+	if err := (Order{}).Validate(); err == nil {
+		t.Error("Expected an order without items to be invalid")
+	}
+}
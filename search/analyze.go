@@ -0,0 +1,56 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// stopWords are common English words that carry no meaning for search
+var stopWords = map[string]bool{}
+
+func init() {
+	for _, w := range strings.Fields(`
+		a about above after again against all am an and any are as at be
+		because been before being below between both but by can could did do
+		does doing down during each few for from further had has have having
+		he her here hers herself him himself his how i if in into is it its
+		itself just me more most my myself no nor not of off on once only or
+		other our ours ourselves out over own same she should so some such
+		than that the their theirs them themselves then there these they this
+		those through to too under until up very was we were what when where
+		which while who whom why will with would you your yours yourself
+		yourselves`) {
+		stopWords[w] = true
+	}
+}
+
+// Tokenize splits text into lower-case words at anything that is not a
+// letter or digit
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Analyze turns text into index terms: it tokenizes, drops stop words and
+// single letters, and stems what is left
+func Analyze(text string) []string {
+	var terms []string
+	for _, token := range Tokenize(text) {
+		if indexable(token) {
+			terms = append(terms, Stem(token))
+		}
+	}
+
+	return terms
+}
+
+// indexable reports whether Analyze keeps a token
+func indexable(token string) bool {
+	if stopWords[token] {
+		return false
+	}
+	r, size := utf8.DecodeRuneInString(token)
+	return size < len(token) || unicode.IsDigit(r)
+}
@@ -0,0 +1,211 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Entry is something a prefix can be completed to, such as a book title.
+// Kind and ID say what it is and which record it belongs to.
+type Entry struct {
+	Kind string
+	Text string
+	ID   string
+}
+
+// Completer finds the entries in which a prefix starts the text or one of
+// its words. Keys are kept in a sorted slice so a lookup is a binary search
+// followed by a scan of the matching keys. Writes merge their keys in
+// straight away, so lookups only read and run in parallel; PutMany loads
+// many documents with a single merge. It is safe for concurrent use.
+type Completer struct {
+	mutex  sync.RWMutex
+	sorted []completionKey
+	docs   map[string]int // document -> generation of its current keys
+	gen    int
+}
+
+// completionKey is the lower-cased text of an entry from the start of one
+// of its words. Keys of older generations of a document are stale.
+type completionKey struct {
+	key   string
+	start bool // the key is the whole text, not a later word
+	doc   string
+	gen   int
+	entry Entry
+}
+
+// NewCompleter creates an empty Completer
+func NewCompleter() *Completer {
+	return &Completer{docs: map[string]int{}}
+}
+
+// Put replaces the entries of a document
+func (c *Completer) Put(doc string, entries []Entry) {
+	c.PutMany(map[string][]Entry{doc: entries})
+}
+
+// PutMany replaces the entries of every document in docs
+func (c *Completer) PutMany(docs map[string][]Entry) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var added []completionKey
+	stale := false
+	for doc, entries := range docs {
+		c.gen++
+		if _, ok := c.docs[doc]; ok {
+			stale = true
+		}
+		c.docs[doc] = c.gen
+		for _, e := range entries {
+			for i, key := range completionKeys(e.Text) {
+				added = append(added, completionKey{key: key, start: i == 0, doc: doc, gen: c.gen, entry: e})
+			}
+		}
+	}
+
+	c.merge(added, stale)
+}
+
+// Remove drops the entries of a document
+func (c *Completer) Remove(doc string) {
+	c.RemoveMany([]string{doc})
+}
+
+// RemoveMany drops the entries of every document in docs
+func (c *Completer) RemoveMany(docs []string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	stale := false
+	for _, doc := range docs {
+		if _, ok := c.docs[doc]; ok {
+			delete(c.docs, doc)
+			stale = true
+		}
+	}
+	c.merge(nil, stale)
+}
+
+// completionKeys returns the lower-cased text from the start of each word
+func completionKeys(text string) []string {
+	text = strings.ToLower(strings.TrimSpace(text))
+
+	var keys []string
+	inWord := false
+	for i, r := range text {
+		wordChar := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if wordChar && !inWord {
+			keys = append(keys, text[i:])
+		}
+		inWord = wordChar
+	}
+	if len(keys) > 0 && keys[0] != text {
+		// Text starting with punctuation still completes from its first character
+		keys = append([]string{text}, keys...)
+	}
+
+	return keys
+}
+
+// merge folds added keys into the sorted ones, dropping stale keys if
+// there may be any. The caller holds the write lock.
+func (c *Completer) merge(added []completionKey, stale bool) {
+	if len(added) == 0 && !stale {
+		return
+	}
+
+	sort.Slice(added, func(i, j int) bool {
+		if added[i].key != added[j].key {
+			return added[i].key < added[j].key
+		}
+		return added[i].doc < added[j].doc
+	})
+	merged := make([]completionKey, 0, len(c.sorted)+len(added))
+	i, j := 0, 0
+	for i < len(c.sorted) || j < len(added) {
+		var next completionKey
+		if j == len(added) || (i < len(c.sorted) && c.sorted[i].key <= added[j].key) {
+			next, i = c.sorted[i], i+1
+		} else {
+			next, j = added[j], j+1
+		}
+		if c.docs[next.doc] == next.gen {
+			merged = append(merged, next)
+		}
+	}
+
+	c.sorted = merged
+}
+
+// Complete returns up to n entries completing prefix, ignoring case.
+// Entries whose text starts with the prefix come before those where a
+// later word does; then entries shared by more documents, such as an
+// author of several books, come first.
+func (c *Completer) Complete(prefix string, n int) []Entry {
+	entries, _ := c.CompleteDocs(prefix, n)
+	return entries
+}
+
+// CompleteDocs is Complete that also returns the documents each entry
+// belongs to, sorted
+func (c *Completer) CompleteDocs(prefix string, n int) ([]Entry, [][]string) {
+	prefix = strings.ToLower(strings.TrimSpace(prefix))
+	if prefix == "" || n <= 0 {
+		return []Entry{}, [][]string{}
+	}
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	type match struct {
+		entry Entry
+		start bool
+		docs  map[string]bool
+	}
+	matches := map[Entry]*match{}
+	var order []*match
+	first := sort.Search(len(c.sorted), func(i int) bool { return c.sorted[i].key >= prefix })
+	for _, k := range c.sorted[first:] {
+		if !strings.HasPrefix(k.key, prefix) {
+			break
+		}
+
+		m, ok := matches[k.entry]
+		if !ok {
+			m = &match{entry: k.entry, docs: map[string]bool{}}
+			matches[k.entry] = m
+			order = append(order, m)
+		}
+		m.start = m.start || k.start
+		m.docs[k.doc] = true
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := order[i], order[j]
+		if a.start != b.start {
+			return a.start
+		}
+		if len(a.docs) != len(b.docs) {
+			return len(a.docs) > len(b.docs)
+		}
+		return a.entry.Text < b.entry.Text
+	})
+
+	if len(order) > n {
+		order = order[:n]
+	}
+	entries := make([]Entry, len(order))
+	docs := make([][]string, len(order))
+	for i, m := range order {
+		entries[i] = m.entry
+		for doc := range m.docs {
+			docs[i] = append(docs[i], doc)
+		}
+		sort.Strings(docs[i])
+	}
+	return entries, docs
+}
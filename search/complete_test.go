@@ -0,0 +1,60 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func texts(entries []Entry) []string {
+	out := []string{}
+	for _, e := range entries {
+		out = append(out, e.Text)
+	}
+	return out
+}
+
+func TestCompleter(t *testing.T) {
+	c := NewCompleter()
+	c.Put("1", []Entry{{Kind: "title", Text: "Harry Potter and the Chamber of Secrets", ID: "1"}, {Kind: "author", Text: "J.K. Rowling", ID: "a"}})
+	c.Put("2", []Entry{{Kind: "title", Text: "Harry Potter and the Goblet of Fire", ID: "2"}, {Kind: "author", Text: "J.K. Rowling", ID: "a"}})
+	c.Put("3", []Entry{{Kind: "title", Text: "The Great Gatsby", ID: "3"}, {Kind: "isbn", Text: "9780743273565", ID: "3"}})
+
+	if got := texts(c.Complete("HARRY P", 10)); !reflect.DeepEqual(got, []string{"Harry Potter and the Chamber of Secrets", "Harry Potter and the Goblet of Fire"}) {
+		t.Errorf("Expected both titles, got %v", got)
+	}
+	if got := texts(c.Complete("gobl", 10)); !reflect.DeepEqual(got, []string{"Harry Potter and the Goblet of Fire"}) {
+		t.Errorf("Expected a match on a later word, got %v", got)
+	}
+	if got := c.Complete("rowl", 10); len(got) != 1 || got[0].Kind != "author" {
+		t.Errorf("Expected one author entry for two books, got %v", got)
+	}
+	if got := texts(c.Complete("97807", 10)); !reflect.DeepEqual(got, []string{"9780743273565"}) {
+		t.Errorf("Expected an ISBN completion, got %v", got)
+	}
+
+	// Start-of-text matches rank before later-word matches
+	c.Put("4", []Entry{{Kind: "title", Text: "Great Expectations", ID: "4"}})
+	if got := texts(c.Complete("great", 10)); !reflect.DeepEqual(got, []string{"Great Expectations", "The Great Gatsby"}) {
+		t.Errorf("Expected the title starting with the prefix first, got %v", got)
+	}
+	if got := c.Complete("great", 1); len(got) != 1 {
+		t.Errorf("Expected the limit to apply, got %v", got)
+	}
+
+	// Replacing and removing documents drops their old keys
+	c.Put("3", []Entry{{Kind: "title", Text: "Tender Is the Night", ID: "3"}})
+	c.Remove("4")
+	if got := c.Complete("great", 10); len(got) != 0 {
+		t.Errorf("Expected no stale completions, got %v", got)
+	}
+	if got := texts(c.Complete("tender", 10)); !reflect.DeepEqual(got, []string{"Tender Is the Night"}) {
+		t.Errorf("Expected the new title, got %v", got)
+	}
+	c.RemoveMany([]string{"1", "2", "missing"})
+	if got := c.Complete("harry", 10); len(got) != 0 {
+		t.Errorf("Expected the removed documents to be gone, got %v", got)
+	}
+	if got := c.Complete("  ", 10); len(got) != 0 {
+		t.Errorf("Expected nothing for a blank prefix, got %v", got)
+	}
+}
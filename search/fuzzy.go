@@ -0,0 +1,269 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// AutoFuzziness lets a word's length decide how many edits it may be from
+// a match: none for words of up to 2 letters, one up to 5 and two beyond
+const AutoFuzziness = -1
+
+// MaxFuzziness is the most edits a query word may be from a match
+const MaxFuzziness = 2
+
+// maxEdits returns how many edits word may be from a match
+func maxEdits(word string, fuzziness int) int {
+	if fuzziness != AutoFuzziness {
+		return fuzziness
+	}
+
+	switch n := utf8.RuneCountInString(word); {
+	case n <= 2:
+		return 0
+	case n <= 5:
+		return 1
+	}
+	return 2
+}
+
+// Distance returns the number of single-letter insertions, deletions,
+// substitutions and swaps of adjacent letters that turn a into b
+func Distance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+
+	// rows i-2, i-1 and i of the edit distance matrix
+	before, prev, cur := make([]int, len(t)+1), make([]int, len(t)+1), make([]int, len(t)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		cur[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				cur[j] = minInt(cur[j], before[j-2]+1)
+			}
+		}
+		before, prev, cur = prev, cur, before
+	}
+
+	return prev[len(t)]
+}
+
+// minInt returns the smallest of values
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+// trigrams returns the distinct three-letter sequences of a word padded
+// with a marker at each end, so "cat" gives "$ca", "cat" and "at$"
+func trigrams(word string) []string {
+	r := []rune("$" + word + "$")
+	seen := map[string]bool{}
+	var grams []string
+	for i := 0; i+3 <= len(r); i++ {
+		gram := string(r[i : i+3])
+		if !seen[gram] {
+			seen[gram] = true
+			grams = append(grams, gram)
+		}
+	}
+
+	return grams
+}
+
+// addWord counts a document containing word in a fuzzy field.
+// The caller holds the write lock.
+func (x *Index) addWord(word string) {
+	if w, ok := x.words[word]; ok {
+		w.docs++
+		return
+	}
+
+	x.words[word] = &vocabWord{term: Stem(word), docs: 1}
+	for _, gram := range trigrams(word) {
+		if x.grams[gram] == nil {
+			x.grams[gram] = map[string]bool{}
+		}
+		x.grams[gram][word] = true
+	}
+}
+
+// removeWord undoes addWord. The caller holds the write lock.
+func (x *Index) removeWord(word string) {
+	w, ok := x.words[word]
+	if !ok {
+		return
+	}
+	if w.docs--; w.docs > 0 {
+		return
+	}
+
+	delete(x.words, word)
+	for _, gram := range trigrams(word) {
+		delete(x.grams[gram], word)
+		if len(x.grams[gram]) == 0 {
+			delete(x.grams, gram)
+		}
+	}
+}
+
+// candidate is a vocabulary word close to a query word
+type candidate struct {
+	word     string
+	term     string
+	distance int
+	docs     int
+}
+
+// candidates returns the vocabulary words at most k edits from word,
+// closest and then most common first. Each edit changes at most three
+// trigrams, so only words sharing enough trigrams with word are compared.
+// The caller holds the read lock.
+func (x *Index) candidates(word string, k int) []candidate {
+	if k <= 0 {
+		return nil
+	}
+
+	grams := trigrams(word)
+	shared := map[string]int{}
+	for _, gram := range grams {
+		for w := range x.grams[gram] {
+			shared[w]++
+		}
+	}
+
+	need := len(grams) - 3*k
+	length := utf8.RuneCountInString(word)
+	var found []candidate
+	consider := func(w string) {
+		if diff := utf8.RuneCountInString(w) - length; diff > k || -diff > k {
+			return
+		}
+		if d := Distance(word, w); d <= k {
+			found = append(found, candidate{word: w, term: x.words[w].term, distance: d, docs: x.words[w].docs})
+		}
+	}
+	if need > 0 {
+		for w, n := range shared {
+			if n >= need {
+				consider(w)
+			}
+		}
+	} else {
+		// Short words can match without sharing a trigram
+		for w := range x.words {
+			consider(w)
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		a, b := found[i], found[j]
+		if a.distance != b.distance {
+			return a.distance < b.distance
+		}
+		if a.docs != b.docs {
+			return a.docs > b.docs
+		}
+		return a.word < b.word
+	})
+
+	return found
+}
+
+// SearchFuzzy is Search with typo tolerance: a query word whose term is
+// not indexed also matches the words of the fuzzy fields at most
+// fuzziness edits away (or AutoFuzziness), scoring less the further they are
+func (x *Index) SearchFuzzy(query string, fuzziness int) []Hit {
+	x.mutex.RLock()
+	defer x.mutex.RUnlock()
+
+	boosts := map[string]float64{}
+	for _, token := range Tokenize(query) {
+		if !indexable(token) {
+			continue
+		}
+		if term := Stem(token); x.postings[term] != nil {
+			boosts[term] = 1
+			continue
+		}
+
+		for _, c := range x.candidates(token, maxEdits(token, fuzziness)) {
+			if boost := 1 / float64(1+c.distance); boost > boosts[c.term] {
+				boosts[c.term] = boost
+			}
+		}
+	}
+
+	return x.rank(boosts)
+}
+
+// Suggest returns up to n corrections of a query in which each word whose
+// term is not indexed is replaced by a close word of the fuzzy fields,
+// best first. It returns nothing if no word needs or has a correction.
+func (x *Index) Suggest(query string, fuzziness, n int) []string {
+	x.mutex.RLock()
+	defer x.mutex.RUnlock()
+
+	tokens := Tokenize(query)
+	options := make([][]candidate, len(tokens))
+	corrected := false
+	for i, token := range tokens {
+		if indexable(token) && x.postings[Stem(token)] == nil {
+			options[i] = x.candidates(token, maxEdits(token, fuzziness))
+		}
+		if len(options[i]) == 0 {
+			options[i] = []candidate{{word: token}}
+		} else {
+			corrected = true
+		}
+	}
+	if !corrected {
+		return []string{}
+	}
+
+	// The best suggestion takes every word's closest correction; the
+	// others swap in the next-closest correction of one word
+	type suggestion struct {
+		words []string
+		cost  int
+	}
+	best := suggestion{words: make([]string, len(tokens))}
+	for i, opts := range options {
+		best.words[i] = opts[0].word
+		best.cost += opts[0].distance
+	}
+	suggestions := []suggestion{best}
+	for i, opts := range options {
+		for _, c := range opts[1:] {
+			words := append([]string{}, best.words...)
+			words[i] = c.word
+			suggestions = append(suggestions, suggestion{words: words, cost: best.cost - opts[0].distance + c.distance})
+		}
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].cost < suggestions[j].cost
+	})
+
+	out := []string{}
+	for _, s := range suggestions {
+		if len(out) == n {
+			break
+		}
+		out = append(out, strings.Join(s.words, " "))
+	}
+
+	return out
+}
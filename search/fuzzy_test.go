@@ -0,0 +1,76 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDistance(t *testing.T) {
+	for _, c := range []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"potter", "potter", 0},
+		{"poter", "potter", 1},
+		{"gatsbi", "gatsby", 1},
+		{"hobbti", "hobbit", 1},
+		{"kitten", "sitting", 3},
+		{"", "abc", 3},
+		{"café", "cafe", 1},
+	} {
+		if got := Distance(c.a, c.b); got != c.want {
+			t.Errorf("Distance(%q, %q) = %d, want %d", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func fuzzyTestIndex() *Index {
+	x := NewIndex(map[string]float64{"title": 3, "author": 2, "description": 1}, "title", "author")
+	x.Put("potter", map[string]string{"title": "Harry Potter and the Sorcerer's Stone", "author": "J.K. Rowling"})
+	x.Put("gatsby", map[string]string{"title": "The Great Gatsby", "author": "F. Scott Fitzgerald"})
+	x.Put("hobbit", map[string]string{"title": "The Hobbit", "description": "A hobbit named Bilbo, not Harry"})
+	return x
+}
+
+func TestSearchFuzzy(t *testing.T) {
+	x := fuzzyTestIndex()
+
+	if hits := x.Search("harry poter"); len(hits) != 2 {
+		t.Fatalf("Expected exact search to match harry only, got %+v", hits)
+	}
+	hits := x.SearchFuzzy("harry poter", AutoFuzziness)
+	if len(hits) != 2 || hits[0].ID != "potter" {
+		t.Errorf("Expected the misspelled title first, got %+v", hits)
+	}
+	if hits := x.SearchFuzzy("fitzgerlad", 1); len(hits) != 0 {
+		t.Errorf("Expected a swap and a deletion to need two edits, got %+v", hits)
+	}
+	if hits := x.SearchFuzzy("fitzgerlad", 2); len(hits) != 1 || hits[0].ID != "gatsby" {
+		t.Errorf("Expected an author match within two edits, got %+v", hits)
+	}
+
+	// Only title and author words are corrected to
+	if hits := x.SearchFuzzy("bilbi", 2); len(hits) != 0 {
+		t.Errorf("Expected description words not to be fuzzy matched, got %+v", hits)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	x := fuzzyTestIndex()
+
+	if got := x.Suggest("harry poter", AutoFuzziness, 3); !reflect.DeepEqual(got, []string{"harry potter"}) {
+		t.Errorf("Expected one correction, got %v", got)
+	}
+	if got := x.Suggest("the hobbit", AutoFuzziness, 3); len(got) != 0 {
+		t.Errorf("Expected no suggestions for known words, got %v", got)
+	}
+	if got := x.Suggest("poter", 0, 3); len(got) != 0 {
+		t.Errorf("Expected no suggestions without fuzziness, got %v", got)
+	}
+
+	x.Remove("potter")
+	if got := x.Suggest("harry poter", AutoFuzziness, 3); len(got) != 0 {
+		t.Errorf("Expected removed words to leave the vocabulary, got %v", got)
+	}
+}
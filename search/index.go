@@ -0,0 +1,196 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// BM25 parameters: k1 limits how much repeating a term helps, b how much
+// long documents are penalized
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// Hit is one ranked search result
+type Hit struct {
+	ID    string
+	Score float64
+}
+
+// Index is an inverted index over documents made of named text fields,
+// ranked with BM25. Each field's terms count Weights[field] times, so a
+// match in a heavily weighted field scores higher. It is safe for
+// concurrent use.
+type Index struct {
+	weights map[string]float64
+	fuzzy   map[string]bool // fields whose words feed fuzzy matching
+
+	mutex    sync.RWMutex
+	postings map[string]map[string]float64 // term -> document -> weighted frequency
+	docs     map[string]document
+	length   float64 // sum of every document's length
+
+	words map[string]*vocabWord      // word of a fuzzy field -> its term and document count
+	grams map[string]map[string]bool // trigram -> words containing it
+}
+
+// document records what was indexed for a document so it can be removed
+type document struct {
+	length float64
+	terms  []string
+	words  []string
+}
+
+// vocabWord is a word that fuzzy queries can be corrected to
+type vocabWord struct {
+	term string
+	docs int
+}
+
+// NewIndex creates an empty index. Fields missing from weights count once.
+// The words of fuzzyFields are the ones SearchFuzzy and Suggest correct
+// misspelled query words to.
+func NewIndex(weights map[string]float64, fuzzyFields ...string) *Index {
+	x := &Index{
+		weights:  weights,
+		fuzzy:    map[string]bool{},
+		postings: map[string]map[string]float64{},
+		docs:     map[string]document{},
+		words:    map[string]*vocabWord{},
+		grams:    map[string]map[string]bool{},
+	}
+	for _, field := range fuzzyFields {
+		x.fuzzy[field] = true
+	}
+
+	return x
+}
+
+// Put indexes a document, replacing any earlier version with the same ID
+func (x *Index) Put(id string, fields map[string]string) {
+	freqs := map[string]float64{}
+	words := map[string]bool{}
+	var length float64
+	for field, text := range fields {
+		weight, ok := x.weights[field]
+		if !ok {
+			weight = 1
+		}
+		for _, term := range Analyze(text) {
+			freqs[term] += weight
+			length += weight
+		}
+		if x.fuzzy[field] {
+			for _, token := range Tokenize(text) {
+				if indexable(token) {
+					words[token] = true
+				}
+			}
+		}
+	}
+
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+
+	x.remove(id)
+
+	doc := document{length: length, terms: make([]string, 0, len(freqs))}
+	for term, freq := range freqs {
+		if x.postings[term] == nil {
+			x.postings[term] = map[string]float64{}
+		}
+		x.postings[term][id] = freq
+		doc.terms = append(doc.terms, term)
+	}
+	for word := range words {
+		x.addWord(word)
+		doc.words = append(doc.words, word)
+	}
+	x.docs[id] = doc
+	x.length += length
+}
+
+// Remove drops a document from the index
+func (x *Index) Remove(id string) {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+
+	x.remove(id)
+}
+
+func (x *Index) remove(id string) {
+	doc, ok := x.docs[id]
+	if !ok {
+		return
+	}
+
+	for _, term := range doc.terms {
+		delete(x.postings[term], id)
+		if len(x.postings[term]) == 0 {
+			delete(x.postings, term)
+		}
+	}
+	for _, word := range doc.words {
+		x.removeWord(word)
+	}
+	delete(x.docs, id)
+	x.length -= doc.length
+}
+
+// Len returns the number of indexed documents
+func (x *Index) Len() int {
+	x.mutex.RLock()
+	defer x.mutex.RUnlock()
+
+	return len(x.docs)
+}
+
+// Search returns the documents matching any term of the query, best first.
+// Equal scores are ordered by ID so results are stable.
+func (x *Index) Search(query string) []Hit {
+	x.mutex.RLock()
+	defer x.mutex.RUnlock()
+
+	boosts := map[string]float64{}
+	for _, term := range Analyze(query) {
+		boosts[term] = 1
+	}
+
+	return x.rank(boosts)
+}
+
+// rank scores every document containing one of the terms, multiplying each
+// term's BM25 score by its boost. The caller holds the read lock.
+func (x *Index) rank(boosts map[string]float64) []Hit {
+	n := float64(len(x.docs))
+	if n == 0 {
+		return []Hit{}
+	}
+	avgLength := x.length / n
+
+	scores := map[string]float64{}
+	for term, boost := range boosts {
+		postings := x.postings[term]
+		df := float64(len(postings))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for id, freq := range postings {
+			norm := 1 - b + b*x.docs[id].length/avgLength
+			scores[id] += boost * idf * freq * (k1 + 1) / (freq + k1*norm)
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, Hit{ID: id, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+
+	return hits
+}
@@ -0,0 +1,43 @@
+package search
+
+import "testing"
+
+func TestIndexRanking(t *testing.T) {
+	x := NewIndex(map[string]float64{"title": 3, "description": 1})
+	x.Put("hobbit", map[string]string{"title": "The Hobbit", "description": "A hobbit and thirteen dwarves go on an adventure"})
+	x.Put("rings", map[string]string{"title": "The Lord of the Rings", "description": "Frodo the hobbit carries the ring"})
+	x.Put("dracula", map[string]string{"title": "Dracula", "description": "A vampire novel"})
+
+	hits := x.Search("hobbits")
+	if len(hits) != 2 || hits[0].ID != "hobbit" || hits[1].ID != "rings" {
+		t.Fatalf("Expected the title match first, got %+v", hits)
+	}
+	if hits[0].Score <= hits[1].Score || hits[1].Score <= 0 {
+		t.Errorf("Expected positive, decreasing scores, got %+v", hits)
+	}
+
+	if hits := x.Search("the of and"); len(hits) != 0 {
+		t.Errorf("Expected stop words to match nothing, got %+v", hits)
+	}
+}
+
+func TestIndexUpdates(t *testing.T) {
+	x := NewIndex(nil)
+	x.Put("a", map[string]string{"title": "Vampire stories"})
+	x.Put("b", map[string]string{"title": "Ghost stories"})
+
+	// Replacing a document drops its old terms
+	x.Put("a", map[string]string{"title": "Werewolf stories"})
+	if hits := x.Search("vampire"); len(hits) != 0 {
+		t.Errorf("Expected the old terms to be gone, got %+v", hits)
+	}
+	if hits := x.Search("werewolf"); len(hits) != 1 || hits[0].ID != "a" {
+		t.Errorf("Expected the new terms to match, got %+v", hits)
+	}
+
+	x.Remove("a")
+	if hits := x.Search("stories"); len(hits) != 1 || hits[0].ID != "b" || x.Len() != 1 {
+		t.Errorf("Expected only b after removing a, got %+v", hits)
+	}
+	x.Remove("a")
+}
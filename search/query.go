@@ -0,0 +1,380 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Comparison operators of field terms
+const (
+	OpEqual        = "="
+	OpLess         = "<"
+	OpLessEqual    = "<="
+	OpGreater      = ">"
+	OpGreaterEqual = ">="
+)
+
+// Expr is a node of a parsed query: And, Or, Not, Text or Field
+type Expr interface {
+	// Pos returns the 1-based position of the node in the query
+	Pos() int
+}
+
+// And matches what every one of its expressions matches
+type And struct {
+	Exprs []Expr
+	At    int
+}
+
+// Or matches what any one of its expressions matches
+type Or struct {
+	Exprs []Expr
+	At    int
+}
+
+// Not matches what its expression does not
+type Not struct {
+	Expr Expr
+	At   int
+}
+
+// Text is a bare word or a quoted phrase
+type Text struct {
+	Value string
+	At    int
+}
+
+// Field is a term such as price:<20. Op is OpEqual when none was given.
+type Field struct {
+	Name    string
+	Op      string
+	Value   string
+	At      int
+	OpAt    int
+	ValueAt int
+}
+
+// Pos returns the position of the first expression
+func (e *And) Pos() int { return e.At }
+
+// Pos returns the position of the first expression
+func (e *Or) Pos() int { return e.At }
+
+// Pos returns the position of the NOT or -
+func (e *Not) Pos() int { return e.At }
+
+// Pos returns the position of the word or opening quote
+func (e *Text) Pos() int { return e.At }
+
+// Pos returns the position of the field name
+func (e *Field) Pos() int { return e.At }
+
+// MaxQueryDepth is how deeply parentheses and NOT may nest in a query.
+// Parsing and matching recurse once per level, so this bounds the stack a
+// client can make them use. Each level can add up to four levels to a
+// MongoDB filter, which must stay within the server's limit of 100.
+const MaxQueryDepth = 20
+
+// SyntaxError is a query that cannot be parsed. Position is 1-based and
+// counts characters, not bytes.
+type SyntaxError struct {
+	Position int
+	Message  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at position %d: %s", e.Position, e.Message)
+}
+
+// ParseQuery parses a query such as
+//
+//	genre:Fantasy price:<20 pages:>300 "young wizard"
+//
+// Terms next to each other, or joined by AND, must all match. OR binds
+// looser, so a b OR c means (a AND b) OR c. NOT or a leading - negates a
+// term, and parentheses group. A field term is name:value, name:<value,
+// name:<=value, name:>value or name:>=value; values and bare phrases may
+// be quoted.
+// AND, OR and NOT are only operators in upper case.
+func ParseQuery(query string) (Expr, error) {
+	tokens, err := lex(query)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens, end: len([]rune(query)) + 1}
+	if len(tokens) == 0 {
+		return nil, &SyntaxError{Position: 1, Message: "query is empty"}
+	}
+
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t, ok := p.peek(); ok {
+		return nil, &SyntaxError{Position: t.pos, Message: fmt.Sprintf("unexpected %s", t.describe())}
+	}
+
+	return expr, nil
+}
+
+// token kinds
+const (
+	tokText = iota
+	tokField
+	tokLParen
+	tokRParen
+	tokAnd
+	tokOr
+	tokNot
+)
+
+type token struct {
+	kind int
+	pos  int
+	text string // word, phrase or value
+	// field terms only
+	name    string
+	op      string
+	opPos   int
+	textPos int
+}
+
+// describe names a token in error messages
+func (t token) describe() string {
+	switch t.kind {
+	case tokLParen:
+		return `"("`
+	case tokRParen:
+		return `")"`
+	case tokAnd:
+		return "AND"
+	case tokOr:
+		return "OR"
+	case tokNot:
+		return "NOT"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// lex splits a query into tokens
+func lex(query string) ([]token, error) {
+	r := []rune(query)
+	var tokens []token
+
+	// word reads a bare word starting at i, stopping at a colon when stopAtColon is set
+	word := func(i int, stopAtColon bool) int {
+		for i < len(r) && !unicode.IsSpace(r[i]) && r[i] != '(' && r[i] != ')' && r[i] != '"' && !(stopAtColon && r[i] == ':') {
+			i++
+		}
+		return i
+	}
+
+	// phrase reads a quoted phrase whose opening quote is at i
+	phrase := func(i int) (string, int, error) {
+		var b strings.Builder
+		for j := i + 1; j < len(r); j++ {
+			switch {
+			case r[j] == '\\' && j+1 < len(r):
+				j++
+				b.WriteRune(r[j])
+			case r[j] == '"':
+				return b.String(), j + 1, nil
+			default:
+				b.WriteRune(r[j])
+			}
+		}
+		return "", 0, &SyntaxError{Position: i + 1, Message: "missing closing quote"}
+	}
+
+	for i := 0; i < len(r); {
+		switch c := r[i]; {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokLParen, pos: i + 1})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokRParen, pos: i + 1})
+			i++
+		case c == '"':
+			text, next, err := phrase(i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokText, pos: i + 1, text: text})
+			i = next
+		case c == '-' && i+1 < len(r) && !unicode.IsSpace(r[i+1]) && r[i+1] != ')':
+			tokens = append(tokens, token{kind: tokNot, pos: i + 1})
+			i++
+		default:
+			end := word(i, true)
+			if end == i {
+				// A colon with no field name before it
+				return nil, &SyntaxError{Position: i + 1, Message: "expected a field name before :"}
+			}
+			text := string(r[i:end])
+
+			if end == len(r) || r[end] != ':' {
+				kind := tokText
+				switch text {
+				case "AND":
+					kind = tokAnd
+				case "OR":
+					kind = tokOr
+				case "NOT":
+					kind = tokNot
+				}
+				tokens = append(tokens, token{kind: kind, pos: i + 1, text: text})
+				i = end
+				continue
+			}
+
+			t := token{kind: tokField, pos: i + 1, name: text, op: OpEqual}
+			i = end + 1
+			t.opPos = i + 1
+			for _, op := range []string{OpLessEqual, OpGreaterEqual, OpLess, OpGreater, OpEqual} {
+				if strings.HasPrefix(string(r[i:]), op) {
+					t.op = op
+					i += len(op)
+					break
+				}
+			}
+
+			t.textPos = i + 1
+			if i < len(r) && r[i] == '"' {
+				value, next, err := phrase(i)
+				if err != nil {
+					return nil, err
+				}
+				t.text, i = value, next
+			} else {
+				end := word(i, false)
+				t.text, i = string(r[i:end]), end
+			}
+			if t.text == "" {
+				return nil, &SyntaxError{Position: t.textPos, Message: fmt.Sprintf("expected a value after %s:", t.name)}
+			}
+			tokens = append(tokens, t)
+		}
+	}
+
+	return tokens, nil
+}
+
+// parser is a recursive descent parser over tokens. end is the position
+// just past the query, used for errors at its end.
+type parser struct {
+	tokens []token
+	i      int
+	end    int
+	depth  int
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.i == len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.i], true
+}
+
+// parseOr parses terms separated by OR
+func (p *parser) parseOr() (Expr, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	exprs := []Expr{first}
+	for {
+		t, ok := p.peek()
+		if !ok || t.kind != tokOr {
+			break
+		}
+		p.i++
+
+		next, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, next)
+	}
+
+	if len(exprs) == 1 {
+		return first, nil
+	}
+	return &Or{Exprs: exprs, At: first.Pos()}, nil
+}
+
+// parseAnd parses terms next to each other or separated by AND
+func (p *parser) parseAnd() (Expr, error) {
+	first, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	exprs := []Expr{first}
+	for {
+		t, ok := p.peek()
+		if !ok || t.kind == tokOr || t.kind == tokRParen {
+			break
+		}
+		if t.kind == tokAnd {
+			p.i++
+		}
+
+		next, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, next)
+	}
+
+	if len(exprs) == 1 {
+		return first, nil
+	}
+	return &And{Exprs: exprs, At: first.Pos()}, nil
+}
+
+// parseUnary parses a term, a negated term or a parenthesized group
+func (p *parser) parseUnary() (Expr, error) {
+	t, ok := p.peek()
+	if !ok {
+		return nil, &SyntaxError{Position: p.end, Message: "expected a term at the end of the query"}
+	}
+	p.i++
+
+	if t.kind == tokNot || t.kind == tokLParen {
+		if p.depth == MaxQueryDepth {
+			return nil, &SyntaxError{Position: t.pos, Message: fmt.Sprintf("query nests more than %d levels deep", MaxQueryDepth)}
+		}
+		p.depth++
+		defer func() { p.depth-- }()
+	}
+
+	switch t.kind {
+	case tokText:
+		return &Text{Value: t.text, At: t.pos}, nil
+	case tokField:
+		return &Field{Name: t.name, Op: t.op, Value: t.text, At: t.pos, OpAt: t.opPos, ValueAt: t.textPos}, nil
+	case tokNot:
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{Expr: expr, At: t.pos}, nil
+	case tokLParen:
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing, ok := p.peek(); !ok || closing.kind != tokRParen {
+			return nil, &SyntaxError{Position: t.pos, Message: `missing ")" for this "("`}
+		}
+		p.i++
+		return expr, nil
+	}
+
+	return nil, &SyntaxError{Position: t.pos, Message: fmt.Sprintf("expected a term, found %s", t.describe())}
+}
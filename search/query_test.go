@@ -0,0 +1,107 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// format prints an expression in a compact, fully parenthesized form
+func format(e Expr) string {
+	join := func(exprs []Expr, sep string) string {
+		parts := make([]string, len(exprs))
+		for i, x := range exprs {
+			parts[i] = format(x)
+		}
+		return "(" + strings.Join(parts, sep) + ")"
+	}
+
+	switch e := e.(type) {
+	case *And:
+		return join(e.Exprs, " AND ")
+	case *Or:
+		return join(e.Exprs, " OR ")
+	case *Not:
+		return "NOT " + format(e.Expr)
+	case *Text:
+		return fmt.Sprintf("%q", e.Value)
+	case *Field:
+		return fmt.Sprintf("%s%s%q", e.Name, e.Op, e.Value)
+	}
+	return "?"
+}
+
+func TestParseQuery(t *testing.T) {
+	for query, want := range map[string]string{
+		`genre:Fantasy price:<20 pages:>300 "young wizard"`: `(genre="Fantasy" AND price<"20" AND pages>"300" AND "young wizard")`,
+		`hobbit`:                            `"hobbit"`,
+		`a b OR c`:                          `(("a" AND "b") OR "c")`,
+		`a AND (b OR c)`:                    `("a" AND ("b" OR "c"))`,
+		`-genre:Horror NOT title:"it"`:      `(NOT genre="Horror" AND NOT title="it")`,
+		`price:>=9.5 price:<=20 pages:=100`: `(price>="9.5" AND price<="20" AND pages="100")`,
+		`"say \"hi\"" and or`:               `("say \"hi\"" AND "and" AND "or")`,
+		`title:"The Hobbit"`:                `title="The Hobbit"`,
+	} {
+		expr, err := ParseQuery(query)
+		if err != nil {
+			t.Errorf("ParseQuery(%q) failed: %v", query, err)
+			continue
+		}
+		if got := format(expr); got != want {
+			t.Errorf("ParseQuery(%q) = %s, want %s", query, got, want)
+		}
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	for query, position := range map[string]int{
+		"":                   1,
+		"   ":                1,
+		`"young wizard`:      1,
+		"price:":             7,
+		"genre: Fantasy":     7,
+		"(a OR b":            1,
+		"a OR":               5,
+		"a)":                 2,
+		"OR a":               1,
+		":x":                 1,
+		"wizard NOT":         11,
+		`title:"unfinished`:  7,
+		"pages:>300 (b) ) c": 16,
+	} {
+		_, err := ParseQuery(query)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("ParseQuery(%q): expected a syntax error, got %v", query, err)
+			continue
+		}
+		if syntaxErr.Position != position {
+			t.Errorf("ParseQuery(%q): expected position %d, got %d (%v)", query, position, syntaxErr.Position, err)
+		}
+	}
+}
+
+func TestParseQueryDepth(t *testing.T) {
+	nested := strings.Repeat("(", MaxQueryDepth) + "a" + strings.Repeat(")", MaxQueryDepth)
+	if _, err := ParseQuery(nested); err != nil {
+		t.Fatalf("ParseQuery at the depth limit: %v", err)
+	}
+
+	for query, position := range map[string]int{
+		"(" + nested + ")": MaxQueryDepth + 1,
+		strings.Repeat("NOT ", MaxQueryDepth+1) + "a":      4*MaxQueryDepth + 1,
+		strings.Repeat("-(", 100000) + "a":                 MaxQueryDepth + 1,
+		"x " + strings.Repeat("(-", MaxQueryDepth/2) + "(": MaxQueryDepth + 3,
+	} {
+		_, err := ParseQuery(query)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("ParseQuery(%.20q...): expected a syntax error, got %v", query, err)
+			continue
+		}
+		if syntaxErr.Position != position {
+			t.Errorf("ParseQuery(%.20q...): expected position %d, got %d (%v)", query, position, syntaxErr.Position, err)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package search
+
+import "strings"
+
+// Stem reduces an English word to its stem with the Porter algorithm, so
+// "wizards", "wizardry" and "wizard" share a term. The word must be lower
+// case; words that are not plain ASCII letters are returned unchanged.
+func Stem(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	for i := 0; i < len(word); i++ {
+		if word[i] < 'a' || word[i] > 'z' {
+			return word
+		}
+	}
+
+	w := []byte(word)
+	w = step1a(w)
+	w = step1b(w)
+	w = step1c(w)
+	w = step2(w)
+	w = step3(w)
+	w = step4(w)
+	w = step5(w)
+
+	return string(w)
+}
+
+// consonant reports whether w[i] is a consonant. Y is a consonant unless
+// it follows one.
+func consonant(w []byte, i int) bool {
+	switch w[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !consonant(w, i-1)
+	}
+
+	return true
+}
+
+// measure counts the vowel-consonant sequences in w, the m of [C](VC)^m[V]
+func measure(w []byte) int {
+	m := 0
+	i := 0
+	for i < len(w) && consonant(w, i) {
+		i++
+	}
+	for i < len(w) {
+		for i < len(w) && !consonant(w, i) {
+			i++
+		}
+		if i == len(w) {
+			break
+		}
+		for i < len(w) && consonant(w, i) {
+			i++
+		}
+		m++
+	}
+
+	return m
+}
+
+// hasVowel reports whether w contains a vowel
+func hasVowel(w []byte) bool {
+	for i := range w {
+		if !consonant(w, i) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// doubleConsonant reports whether w ends with a double consonant
+func doubleConsonant(w []byte) bool {
+	n := len(w)
+	return n >= 2 && w[n-1] == w[n-2] && consonant(w, n-1)
+}
+
+// cvc reports whether w ends consonant-vowel-consonant, where the last
+// consonant is not w, x or y
+func cvc(w []byte) bool {
+	n := len(w)
+	if n < 3 || !consonant(w, n-1) || consonant(w, n-2) || !consonant(w, n-3) {
+		return false
+	}
+
+	switch w[n-1] {
+	case 'w', 'x', 'y':
+		return false
+	}
+	return true
+}
+
+// rule replaces a suffix when the remaining stem satisfies cond
+type rule struct {
+	suffix, replacement string
+	cond                func(stem []byte) bool
+}
+
+// applyRules applies the rule with the longest matching suffix, if its
+// condition holds. Shorter matches are not tried.
+func applyRules(w []byte, rules []rule) []byte {
+	best := -1
+	for i, r := range rules {
+		if strings.HasSuffix(string(w), r.suffix) && (best < 0 || len(r.suffix) > len(rules[best].suffix)) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return w
+	}
+
+	r := rules[best]
+	stem := w[:len(w)-len(r.suffix)]
+	if r.cond != nil && !r.cond(stem) {
+		return w
+	}
+	return append(stem[:len(stem):len(stem)], r.replacement...)
+}
+
+func mGreater(n int) func([]byte) bool {
+	return func(stem []byte) bool { return measure(stem) > n }
+}
+
+func step1a(w []byte) []byte {
+	return applyRules(w, []rule{
+		{"sses", "ss", nil},
+		{"ies", "i", nil},
+		{"ss", "ss", nil},
+		{"s", "", nil},
+	})
+}
+
+func step1b(w []byte) []byte {
+	s := string(w)
+	if strings.HasSuffix(s, "eed") {
+		if measure(w[:len(w)-3]) > 0 {
+			return w[:len(w)-1]
+		}
+		return w
+	}
+
+	var stem []byte
+	switch {
+	case strings.HasSuffix(s, "ed") && hasVowel(w[:len(w)-2]):
+		stem = w[:len(w)-2]
+	case strings.HasSuffix(s, "ing") && hasVowel(w[:len(w)-3]):
+		stem = w[:len(w)-3]
+	default:
+		return w
+	}
+
+	t := string(stem)
+	switch {
+	case strings.HasSuffix(t, "at"), strings.HasSuffix(t, "bl"), strings.HasSuffix(t, "iz"):
+		return append(stem[:len(stem):len(stem)], 'e')
+	case doubleConsonant(stem):
+		switch stem[len(stem)-1] {
+		case 'l', 's', 'z':
+			return stem
+		}
+		return stem[:len(stem)-1]
+	case measure(stem) == 1 && cvc(stem):
+		return append(stem[:len(stem):len(stem)], 'e')
+	}
+	return stem
+}
+
+func step1c(w []byte) []byte {
+	if w[len(w)-1] == 'y' && hasVowel(w[:len(w)-1]) {
+		return append(w[:len(w)-1:len(w)-1], 'i')
+	}
+	return w
+}
+
+func step2(w []byte) []byte {
+	m0 := mGreater(0)
+	return applyRules(w, []rule{
+		{"ational", "ate", m0},
+		{"tional", "tion", m0},
+		{"enci", "ence", m0},
+		{"anci", "ance", m0},
+		{"izer", "ize", m0},
+		{"abli", "able", m0},
+		{"alli", "al", m0},
+		{"entli", "ent", m0},
+		{"eli", "e", m0},
+		{"ousli", "ous", m0},
+		{"ization", "ize", m0},
+		{"ation", "ate", m0},
+		{"ator", "ate", m0},
+		{"alism", "al", m0},
+		{"iveness", "ive", m0},
+		{"fulness", "ful", m0},
+		{"ousness", "ous", m0},
+		{"aliti", "al", m0},
+		{"iviti", "ive", m0},
+		{"biliti", "ble", m0},
+	})
+}
+
+func step3(w []byte) []byte {
+	m0 := mGreater(0)
+	return applyRules(w, []rule{
+		{"icate", "ic", m0},
+		{"ative", "", m0},
+		{"alize", "al", m0},
+		{"iciti", "ic", m0},
+		{"ical", "ic", m0},
+		{"ful", "", m0},
+		{"ness", "", m0},
+	})
+}
+
+func step4(w []byte) []byte {
+	m1 := mGreater(1)
+	return applyRules(w, []rule{
+		{"al", "", m1},
+		{"ance", "", m1},
+		{"ence", "", m1},
+		{"er", "", m1},
+		{"ic", "", m1},
+		{"able", "", m1},
+		{"ible", "", m1},
+		{"ant", "", m1},
+		{"ement", "", m1},
+		{"ment", "", m1},
+		{"ent", "", m1},
+		{"ion", "", func(stem []byte) bool {
+			n := len(stem)
+			return measure(stem) > 1 && n > 0 && (stem[n-1] == 's' || stem[n-1] == 't')
+		}},
+		{"ou", "", m1},
+		{"ism", "", m1},
+		{"ate", "", m1},
+		{"iti", "", m1},
+		{"ous", "", m1},
+		{"ive", "", m1},
+		{"ize", "", m1},
+	})
+}
+
+func step5(w []byte) []byte {
+	if n := len(w); w[n-1] == 'e' {
+		stem := w[:n-1]
+		if m := measure(stem); m > 1 || (m == 1 && !cvc(stem)) {
+			w = stem
+		}
+	}
+	if n := len(w); n > 1 && w[n-1] == 'l' && doubleConsonant(w) && measure(w) > 1 {
+		w = w[:n-1]
+	}
+
+	return w
+}
@@ -0,0 +1,70 @@
+package search
+
+import "testing"
+
+func TestStem(t *testing.T) {
+	tests := map[string]string{
+		"caresses":       "caress",
+		"ponies":         "poni",
+		"cats":           "cat",
+		"feed":           "feed",
+		"agreed":         "agre",
+		"plastered":      "plaster",
+		"motoring":       "motor",
+		"sing":           "sing",
+		"conflated":      "conflat",
+		"troubled":       "troubl",
+		"sized":          "size",
+		"hopping":        "hop",
+		"falling":        "fall",
+		"filing":         "file",
+		"happy":          "happi",
+		"relational":     "relat",
+		"conditional":    "condit",
+		"generalization": "gener",
+		"replacement":    "replac",
+		"adoption":       "adopt",
+		"controlling":    "control",
+		"wizards":        "wizard",
+		"running":        "run",
+		"is":             "is",
+		"café":           "café",
+	}
+
+	for word, want := range tests {
+		if got := Stem(word); got != want {
+			t.Errorf("Stem(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	got := Analyze("The Wizards of Earth-Sea, a 1968 novel!")
+	want := []string{"wizard", "earth", "sea", "1968", "novel"}
+
+	if len(got) != len(want) {
+		t.Fatalf("Analyze = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Analyze = %v, want %v", got, want)
+			break
+		}
+	}
+}
+
+func TestAnalyzeKeepsSingleDigits(t *testing.T) {
+	// Single digits are kept whatever their script; single letters are not
+	got := Analyze("Volume 3 ٣ x é")
+	want := []string{"volum", "3", "٣"}
+
+	if len(got) != len(want) {
+		t.Fatalf("Analyze = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Analyze = %v, want %v", got, want)
+			break
+		}
+	}
+}
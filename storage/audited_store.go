@@ -0,0 +1,350 @@
+package storage
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/harshakumara/book-api/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ChangeInfo says who made a write and why, for the revision it records
+type ChangeInfo struct {
+	Actor     string
+	RequestID string
+	// RestoredFrom is set by restores to the revision being restored
+	RestoredFrom string
+}
+
+type changeInfoKey struct{}
+
+// WithChangeInfo returns a context whose writes are attributed to info
+func WithChangeInfo(ctx context.Context, info ChangeInfo) context.Context {
+	return context.WithValue(ctx, changeInfoKey{}, info)
+}
+
+// ChangeInfoFromContext returns the ChangeInfo set by WithChangeInfo, or
+// the zero value
+func ChangeInfoFromContext(ctx context.Context) ChangeInfo {
+	info, _ := ctx.Value(changeInfoKey{}).(ChangeInfo)
+	return info
+}
+
+// AuditedBookStore wraps a BookStore that does not record revisions itself,
+// such as the MongoDB and memory stores, so that every write made through it
+// is recorded as a Revision, attributed to the ChangeInfo of the write's
+// context. A revision is recorded after its write succeeds; the write has
+// committed by then, so if recording fails the error is logged and the
+// write still succeeds. The file stores record revisions in the same
+// transaction as the write and must not be wrapped.
+type AuditedBookStore struct {
+	BookStore
+	revisions RevisionStore
+}
+
+// NewAuditedBookStore records the writes made through books in revisions
+func NewAuditedBookStore(books BookStore, revisions RevisionStore) *AuditedBookStore {
+	return &AuditedBookStore{BookStore: books, revisions: revisions}
+}
+
+// Create stores a new book and records its creation
+func (s *AuditedBookStore) Create(ctx context.Context, book models.Book) (models.Book, error) {
+	book, err := s.BookStore.Create(ctx, book)
+	if err != nil {
+		return models.Book{}, err
+	}
+
+	s.record(ctx, newRevision(ctx, nil, &book))
+	return book, nil
+}
+
+// Update replaces the book with the given ID and records the change
+func (s *AuditedBookStore) Update(ctx context.Context, id string, book models.Book, version int64) (models.Book, error) {
+	var updated models.Book
+	before, err := s.guarded(ctx, id, version, func(version int64) error {
+		var err error
+		updated, err = s.BookStore.Update(ctx, id, book, version)
+		return err
+	})
+	if err != nil {
+		return models.Book{}, err
+	}
+
+	s.record(ctx, newRevision(ctx, &before, &updated))
+	return updated, nil
+}
+
+// Patch changes the book with the given ID and records the change
+func (s *AuditedBookStore) Patch(ctx context.Context, id string, version int64, apply func(models.Book) (models.Book, error)) (models.Book, error) {
+	var before models.Book
+	book, err := s.BookStore.Patch(ctx, id, version, func(current models.Book) (models.Book, error) {
+		before = current
+		return apply(current)
+	})
+	if err != nil {
+		return models.Book{}, err
+	}
+
+	s.record(ctx, newRevision(ctx, &before, &book))
+	return book, nil
+}
+
+// Delete removes the book with the given ID and records what it was
+func (s *AuditedBookStore) Delete(ctx context.Context, id string, version int64) error {
+	before, err := s.guarded(ctx, id, version, func(version int64) error {
+		return s.BookStore.Delete(ctx, id, version)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.record(ctx, newRevision(ctx, &before, nil))
+	return nil
+}
+
+// BulkUpsert writes the books and records every one that was written
+func (s *AuditedBookStore) BulkUpsert(ctx context.Context, books []models.Book, atomic bool) ([]BulkResult, error) {
+	var ids []string
+	for _, b := range books {
+		if !b.ID.IsZero() {
+			ids = append(ids, b.ID.Hex())
+		}
+	}
+	existing, err := s.byID(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := s.BookStore.BulkUpsert(ctx, books, atomic)
+	if err != nil {
+		return results, err
+	}
+
+	var revisions []models.Revision
+	for _, r := range results {
+		if r.Err != nil {
+			continue
+		}
+
+		book := r.Book
+		var before *models.Book
+		if b, ok := existing[book.ID.Hex()]; ok && !r.Created {
+			before = &b
+		}
+		revisions = append(revisions, newRevision(ctx, before, &book)...)
+	}
+
+	s.record(ctx, revisions)
+	return results, nil
+}
+
+// DeleteMany removes the books and records every one deleted
+func (s *AuditedBookStore) DeleteMany(ctx context.Context, ids []string, versions []int64, atomic bool) ([]error, error) {
+	existing, err := s.byID(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	errs, err := s.BookStore.DeleteMany(ctx, ids, versions, atomic)
+	if err != nil {
+		return errs, err
+	}
+
+	var revisions []models.Revision
+	for i, itemErr := range errs {
+		if b, ok := existing[ids[i]]; ok && itemErr == nil {
+			revisions = append(revisions, newRevision(ctx, &b, nil)...)
+		}
+	}
+
+	s.record(ctx, revisions)
+	return errs, nil
+}
+
+// DeleteWhere removes the books matching the filter and records each one.
+// The matching books are looked up first and deleted by ID at the version
+// read, so a book only counts if it is recorded as it was deleted; one
+// changed in between is left alone.
+func (s *AuditedBookStore) DeleteWhere(ctx context.Context, filter BookFilter) (int64, error) {
+	page, err := s.BookStore.List(ctx, BookQuery{Filter: filter})
+	if err != nil {
+		return 0, err
+	}
+
+	if len(page.Books) == 0 {
+		return 0, nil
+	}
+
+	ids := make([]string, len(page.Books))
+	versions := make([]int64, len(page.Books))
+	for i, b := range page.Books {
+		ids[i], versions[i] = b.ID.Hex(), b.Version
+	}
+	errs, err := s.DeleteMany(ctx, ids, versions, false)
+
+	var deleted int64
+	for _, itemErr := range errs {
+		if itemErr == nil {
+			deleted++
+		}
+	}
+	return deleted, err
+}
+
+// guarded reads a book and runs write conditional on the version it read,
+// so the revision's before state is exactly what the write replaced.
+// An unconditional write that loses a race is retried.
+func (s *AuditedBookStore) guarded(ctx context.Context, id string, version int64, write func(version int64) error) (models.Book, error) {
+	const attempts = 3
+
+	for attempt := 1; ; attempt++ {
+		current, err := s.BookStore.Get(ctx, id)
+		if err != nil {
+			return models.Book{}, err
+		}
+		if version != AnyVersion && current.Version != version {
+			return models.Book{}, ErrVersionMismatch
+		}
+
+		err = write(current.Version)
+		if err == nil {
+			return current, nil
+		}
+		if err != ErrVersionMismatch || version != AnyVersion || attempt == attempts {
+			return models.Book{}, err
+		}
+	}
+}
+
+// byID returns the books with the given IDs keyed by ID
+func (s *AuditedBookStore) byID(ctx context.Context, ids []string) (map[string]models.Book, error) {
+	books := map[string]models.Book{}
+	if len(ids) == 0 {
+		return books, nil
+	}
+
+	found, err := s.BookStore.GetMany(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range found {
+		books[b.ID.Hex()] = b
+	}
+
+	return books, nil
+}
+
+// record stores the revisions of a write that has committed
+func (s *AuditedBookStore) record(ctx context.Context, revisions []models.Revision) {
+	recordCommitted(ctx, s.revisions, revisions)
+}
+
+// recordCommitted stores the revisions of a write that has already
+// committed, if there are any. Failing the write would not undo it, so a
+// failure is logged instead.
+func recordCommitted(ctx context.Context, store RevisionStore, revisions []models.Revision) {
+	if len(revisions) == 0 {
+		return
+	}
+
+	if err := store.Record(ctx, revisions); err != nil {
+		log.Printf("[%s] WARNING: recording %d revisions failed: %v", ChangeInfoFromContext(ctx).RequestID, len(revisions), err)
+	}
+}
+
+// newRevision describes the change from before to after, either of which
+// is nil if the book did not exist. An update that changed no field
+// returns no revision.
+func newRevision(ctx context.Context, before, after *models.Book) []models.Revision {
+	changes := models.DiffBooks(before, after)
+	if before != nil && after != nil && len(changes) == 0 {
+		return nil
+	}
+
+	info := ChangeInfoFromContext(ctx)
+	rev := models.Revision{
+		ID:        primitive.NewObjectID(),
+		Actor:     info.Actor,
+		RequestID: info.RequestID,
+		// MongoDB keeps milliseconds; truncating keeps the backends alike
+		CreatedAt: time.Now().UTC().Truncate(time.Millisecond),
+		Changes:   changes,
+	}
+
+	switch {
+	case after == nil:
+		rev.Action, rev.BookID, rev.Version = models.RevisionDelete, before.ID, before.Version
+	case info.RestoredFrom != "":
+		rev.Action, rev.RestoredFrom = models.RevisionRestore, info.RestoredFrom
+	case before == nil:
+		rev.Action = models.RevisionCreate
+	default:
+		rev.Action = models.RevisionUpdate
+	}
+	if after != nil {
+		book := *after
+		rev.BookID, rev.Version, rev.Book = book.ID, book.Version, &book
+	}
+
+	return []models.Revision{rev}
+}
+
+// findRevision returns the revision with the given ID
+func findRevision(revisions []models.Revision, id string) (models.Revision, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.Revision{}, ErrInvalidID
+	}
+
+	for _, r := range revisions {
+		if r.ID == objID {
+			return r, nil
+		}
+	}
+
+	return models.Revision{}, ErrNotFound
+}
+
+// bookHistory returns the revisions of a book, oldest first
+func bookHistory(revisions []models.Revision, bookID string) []models.Revision {
+	history := []models.Revision{}
+	for _, r := range revisions {
+		if r.BookID.Hex() == bookID {
+			history = append(history, r)
+		}
+	}
+
+	sortRevisions(history)
+	return history
+}
+
+// revisionsSince returns up to limit revisions recorded at or after since,
+// oldest first
+func revisionsSince(revisions []models.Revision, since time.Time, limit int) []models.Revision {
+	found := []models.Revision{}
+	for _, r := range revisions {
+		if !r.CreatedAt.Before(since) {
+			found = append(found, r)
+		}
+	}
+
+	sortRevisions(found)
+	if limit > 0 && len(found) > limit {
+		found = found[:limit]
+	}
+	return found
+}
+
+// sortRevisions orders revisions oldest first. IDs break ties, as they
+// increase for revisions recorded by the same process.
+func sortRevisions(revisions []models.Revision) {
+	sort.SliceStable(revisions, func(i, j int) bool {
+		a, b := revisions[i], revisions[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.ID.Hex() < b.ID.Hex()
+	})
+}
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/harshakumara/book-api/models"
+)
+
+func TestAuditedBookStoreRecordsWrites(t *testing.T) {
+	revisions := NewMemoryRevisionStore()
+	books := NewAuditedBookStore(NewMemoryBookStore(), revisions)
+	ctx := WithChangeInfo(context.Background(), ChangeInfo{Actor: "alice", RequestID: "req-1"})
+
+	book, _ := books.Create(ctx, models.Book{Title: "Dune", Price: 9.99})
+	id := book.ID.Hex()
+
+	book.Price = 12.5
+	book, _ = books.Update(ctx, id, book, AnyVersion)
+	// Writes that change nothing leave no revision
+	books.Update(ctx, id, book, AnyVersion)
+	books.Patch(ctx, id, AnyVersion, func(b models.Book) (models.Book, error) {
+		b.Genre = "Science Fiction"
+		return b, nil
+	})
+	if err := books.Delete(ctx, id, 1); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("Expected a stale delete to fail, got %v", err)
+	}
+	if err := books.Delete(ctx, id, AnyVersion); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	history, _ := revisions.History(ctx, id)
+	var actions []string
+	for _, r := range history {
+		actions = append(actions, r.Action)
+		if r.Actor != "alice" || r.RequestID != "req-1" || r.BookID != book.ID {
+			t.Errorf("Expected the revision to be attributed, got %+v", r)
+		}
+	}
+	if want := []string{"create", "update", "update", "delete"}; !reflect.DeepEqual(actions, want) {
+		t.Fatalf("Expected %v, got %v", want, actions)
+	}
+
+	if want := []models.FieldChange{{Field: "price", Before: 9.99, After: 12.5}}; !reflect.DeepEqual(history[1].Changes, want) {
+		t.Errorf("Expected the price change, got %+v", history[1].Changes)
+	}
+	if history[2].Book == nil || history[2].Book.Genre != "Science Fiction" || history[2].Version != 4 {
+		t.Errorf("Expected the patched book, got %+v", history[2])
+	}
+	if history[3].Book != nil || history[3].Version != 4 || len(history[3].Changes) == 0 {
+		t.Errorf("Expected the delete to record what was removed, got %+v", history[3])
+	}
+}
+
+func TestAuditedBookStoreRecordsBulkWrites(t *testing.T) {
+	revisions := NewMemoryRevisionStore()
+	books := NewAuditedBookStore(NewMemoryBookStore(), revisions)
+	ctx := context.Background()
+	start := time.Now().UTC().Add(-time.Second)
+
+	existing, _ := books.Create(ctx, models.Book{Title: "Emma", Genre: "Novel"})
+	existing.Title = "Emma (annotated)"
+	if _, err := books.BulkUpsert(ctx, []models.Book{existing, {Title: "Persuasion", Genre: "Novel"}}, false); err != nil {
+		t.Fatalf("BulkUpsert failed: %v", err)
+	}
+	if n, err := books.DeleteWhere(ctx, BookFilter{Genre: "Novel"}); err != nil || n != 2 {
+		t.Fatalf("Expected 2 deleted, got %d, %v", n, err)
+	}
+
+	log, _ := revisions.Since(ctx, start, 0)
+	var actions []string
+	for _, r := range log {
+		actions = append(actions, r.Action)
+	}
+	if want := []string{"create", "update", "create", "delete", "delete"}; !reflect.DeepEqual(actions, want) {
+		t.Errorf("Expected %v, got %v", want, actions)
+	}
+
+	if limited, _ := revisions.Since(ctx, start, 2); len(limited) != 2 || limited[0].ID != log[0].ID {
+		t.Errorf("Expected the two oldest revisions, got %+v", limited)
+	}
+	if later, _ := revisions.Since(ctx, time.Now().Add(time.Hour), 0); len(later) != 0 {
+		t.Errorf("Expected nothing recorded in the future, got %+v", later)
+	}
+}
+
+// failingRevisionStore is a RevisionStore that cannot record anything
+type failingRevisionStore struct {
+	*MemoryRevisionStore
+}
+
+func (failingRevisionStore) Record(ctx context.Context, revisions []models.Revision) error {
+	return errors.New("revision log unavailable")
+}
+
+func TestAuditedBookStoreKeepsWritesWhenRecordingFails(t *testing.T) {
+	memory := NewMemoryBookStore()
+	books := NewAuditedBookStore(memory, failingRevisionStore{NewMemoryRevisionStore()})
+	ctx := context.Background()
+
+	// The write has committed, so it must not be reported as failed
+	book, err := books.Create(ctx, models.Book{Title: "Dune"})
+	if err != nil {
+		t.Fatalf("Expected the create to succeed, got %v", err)
+	}
+	if _, err := memory.Get(ctx, book.ID.Hex()); err != nil {
+		t.Errorf("Expected the book to be stored, got %v", err)
+	}
+	if err := books.Delete(ctx, book.ID.Hex(), AnyVersion); err != nil {
+		t.Errorf("Expected the delete to succeed, got %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"errors"
+
+	"github.com/harshakumara/book-api/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// errBulkAborted rolls back an all-or-nothing bulk transaction
+var errBulkAborted = errors.New("bulk operation aborted")
+
+// repeatedBookError fails a book that appears earlier in the same bulk
+// upsert. Only the first copy is written, as a bulk delete deletes only the
+// first copy of a repeated ID.
+func repeatedBookError() error {
+	return &models.ValidationError{Errors: []models.FieldError{{Field: "bookId", Message: "appears more than once in the request"}}}
+}
+
+// checkBulkVersion checks the version a bulk upsert's book must replace,
+// if it names one, or that it is new if it must be
+func checkBulkVersion(books []models.Book, book models.Book) error {
+	if book.Version == 0 {
+		return nil
+	}
+
+	i := findBook(books, book.ID.Hex())
+	if book.Version == CreateOnly {
+		if i >= 0 {
+			return ErrConflict
+		}
+		return nil
+	}
+	if i < 0 {
+		return ErrNotFound
+	}
+	if books[i].Version != book.Version {
+		return ErrVersionMismatch
+	}
+
+	return nil
+}
+
+// upsertBook creates or replaces one book in books, as BulkUpsert does.
+// The book's version must already have been checked.
+func upsertBook(books *[]models.Book, book models.Book) BulkResult {
+	if book.ID.IsZero() {
+		book.ID = primitive.NewObjectID()
+	}
+
+	if i := findBook(*books, book.ID.Hex()); i >= 0 {
+		book.Version = (*books)[i].Version + 1
+		(*books)[i] = book
+		return BulkResult{Book: book}
+	}
+
+	book.Version = 1
+	*books = append(*books, book)
+	return BulkResult{Book: book, Created: true}
+}
+
+// deleteBook removes one book from books, as DeleteMany does
+func deleteBook(books *[]models.Book, id string, version int64) error {
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return ErrInvalidID
+	}
+
+	i := findBook(*books, id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	if version != AnyVersion && (*books)[i].Version != version {
+		return ErrVersionMismatch
+	}
+
+	*books = append((*books)[:i:i], (*books)[i+1:]...)
+	return nil
+}
+
+// bulkVersion returns the version item i of a bulk delete must be at
+func bulkVersion(versions []int64, i int) int64 {
+	if versions == nil {
+		return AnyVersion
+	}
+	return versions[i]
+}
+
+// booksWithIDs returns the books whose ID is one of ids
+func booksWithIDs(books []models.Book, ids []string) []models.Book {
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	found := []models.Book{}
+	for _, b := range books {
+		if wanted[b.ID.Hex()] {
+			found = append(found, b)
+		}
+	}
+
+	return found
+}
+
+// booksWithISBN returns the books whose ISBN is one of isbns
+func booksWithISBN(books []models.Book, isbns []string) []models.Book {
+	wanted := make(map[string]bool, len(isbns))
+	for _, isbn := range isbns {
+		wanted[isbn] = true
+	}
+
+	found := []models.Book{}
+	for _, b := range books {
+		if b.ISBN != "" && wanted[b.ISBN] {
+			found = append(found, b)
+		}
+	}
+
+	return found
+}
+
+// notApplied marks every successful result of an aborted bulk upsert
+func notApplied(results []BulkResult) {
+	for i := range results {
+		if results[i].Err == nil {
+			results[i] = BulkResult{Book: results[i].Book, Err: ErrNotApplied}
+		}
+	}
+}
+
+// notAppliedErrs marks every successful item of an aborted bulk delete
+func notAppliedErrs(errs []error) {
+	for i := range errs {
+		if errs[i] == nil {
+			errs[i] = ErrNotApplied
+		}
+	}
+}
+
+// anyFailed reports whether any result of a bulk upsert failed
+func anyFailed(results []BulkResult) bool {
+	for _, r := range results {
+		if r.Err != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// anyErr reports whether any item of a bulk delete failed
+func anyErr(errs []error) bool {
+	for _, err := range errs {
+		if err != nil {
+			return true
+		}
+	}
+
+	return false
+}
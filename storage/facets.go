@@ -0,0 +1,226 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/harshakumara/book-api/models"
+)
+
+// Facets that can be counted
+const (
+	FacetGenre     = "genre"
+	FacetPrice     = "price"
+	FacetDecade    = "decade"
+	FacetStock     = "stock"
+	FacetPublisher = "publisher"
+)
+
+// FacetNames lists every facet
+var FacetNames = []string{FacetGenre, FacetPrice, FacetDecade, FacetStock, FacetPublisher}
+
+// Values of the stock facet
+const (
+	InStock    = "inStock"
+	OutOfStock = "outOfStock"
+)
+
+// PriceBand is one bucket of the price facet, from Min up to but not
+// including Max. The last band has no Max.
+type PriceBand struct {
+	Label string
+	Min   float64
+	Max   float64
+}
+
+// PriceBands are the buckets of the price facet, cheapest first
+var PriceBands = []PriceBand{
+	{Label: "0-10", Min: 0, Max: 10},
+	{Label: "10-20", Min: 10, Max: 20},
+	{Label: "20-50", Min: 20, Max: 50},
+	{Label: "50+", Min: 50},
+}
+
+// FacetCount is the number of books sharing one value of a facet
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// Facets maps each requested facet to its counts. Genres and publishers
+// are ordered by count, price bands cheapest first and decades oldest first.
+type Facets map[string][]FacetCount
+
+// ParseFacets parses a comma-separated list of facet names
+func ParseFacets(spec string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if indexOfFacet(name) < 0 {
+			return nil, fmt.Errorf("%w: unknown facet %q (want %s)", ErrInvalidQuery, name, strings.Join(FacetNames, ", "))
+		}
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+// indexOfFacet returns the position of a facet in FacetNames, or -1
+func indexOfFacet(name string) int {
+	for i, n := range FacetNames {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// contains reports whether a price falls in the band
+func (band PriceBand) contains(price float64) bool {
+	return price >= band.Min && (band.Max == 0 || price < band.Max)
+}
+
+// decadePrefix returns the first three digits of the years in a decade
+// label such as "1990s"
+func decadePrefix(label string) (string, bool) {
+	if len(label) != 5 || label[3:] != "0s" || !isDigits(label[:3]) {
+		return "", false
+	}
+	return label[:3], true
+}
+
+// bookDecade returns the decade label of a book's publication date
+func bookDecade(b models.Book) (string, bool) {
+	if len(b.PublicationDate) < 4 || !isDigits(b.PublicationDate[:4]) {
+		return "", false
+	}
+	return b.PublicationDate[:3] + "0s", true
+}
+
+// isDigits reports whether s consists of ASCII digits only
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// facetValue returns the value a book counts towards in a facet.
+// Books without a genre, publisher or publication date are not counted there.
+func facetValue(name string, b models.Book) (string, bool) {
+	switch name {
+	case FacetGenre:
+		return b.Genre, b.Genre != ""
+	case FacetPrice:
+		for _, band := range PriceBands {
+			if band.contains(b.Price) {
+				return band.Label, true
+			}
+		}
+	case FacetDecade:
+		return bookDecade(b)
+	case FacetStock:
+		if b.Quantity > 0 {
+			return InStock, true
+		}
+		return OutOfStock, true
+	case FacetPublisher:
+		return b.PublisherID, b.PublisherID != ""
+	}
+
+	return "", false
+}
+
+// CountFacets counts the named facets over books
+func CountFacets(books []models.Book, names []string) Facets {
+	counts := make(map[string]map[string]int64, len(names))
+	for _, name := range names {
+		counts[name] = map[string]int64{}
+	}
+	for _, b := range books {
+		for _, name := range names {
+			if value, ok := facetValue(name, b); ok {
+				counts[name][value]++
+			}
+		}
+	}
+
+	return sortFacets(counts)
+}
+
+// countMatchingFacets counts the named facets over the books matching the filter
+func countMatchingFacets(books []models.Book, filter BookFilter, names []string) (Facets, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	matched := []models.Book{}
+	for _, b := range books {
+		if filter.Matches(b) {
+			matched = append(matched, b)
+		}
+	}
+
+	return CountFacets(matched, names), nil
+}
+
+// sortFacets turns raw counts into Facets in each facet's order
+func sortFacets(counts map[string]map[string]int64) Facets {
+	facets := make(Facets, len(counts))
+	for name, values := range counts {
+		list := make([]FacetCount, 0, len(values))
+		for value, n := range values {
+			list = append(list, FacetCount{Value: value, Count: n})
+		}
+
+		sort.Slice(list, func(i, j int) bool {
+			a, b := list[i], list[j]
+			switch name {
+			case FacetPrice:
+				return priceBandIndex(a.Value) < priceBandIndex(b.Value)
+			case FacetDecade, FacetStock:
+				return a.Value < b.Value
+			}
+			if a.Count != b.Count {
+				return a.Count > b.Count
+			}
+			return a.Value < b.Value
+		})
+		facets[name] = list
+	}
+
+	return facets
+}
+
+// priceBandIndex returns the position of the band with the given label in
+// PriceBands, or -1
+func priceBandIndex(label string) int {
+	for i, band := range PriceBands {
+		if band.Label == label {
+			return i
+		}
+	}
+	return -1
+}
+
+// Validate checks the facet selections of a filter
+func (f BookFilter) Validate() error {
+	if f.PriceBand != "" && priceBandIndex(f.PriceBand) < 0 {
+		labels := make([]string, len(PriceBands))
+		for i, band := range PriceBands {
+			labels[i] = band.Label
+		}
+		return fmt.Errorf("%w: priceBand must be one of %s", ErrInvalidQuery, strings.Join(labels, ", "))
+	}
+	if _, ok := decadePrefix(f.Decade); f.Decade != "" && !ok {
+		return fmt.Errorf("%w: decade must look like 1990s", ErrInvalidQuery)
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/harshakumara/book-api/models"
+)
+
+func TestCountFacets(t *testing.T) {
+	books := []models.Book{
+		{Genre: "Fantasy", PublisherID: "p1", Price: 9.99, Quantity: 1, PublicationDate: "1997-06-26"},
+		{Genre: "Fantasy", PublisherID: "p2", Price: 10, Quantity: 0, PublicationDate: "1954-07-29"},
+		{Genre: "Novel", PublisherID: "p1", Price: 75, Quantity: 3},
+		{Price: 20, Quantity: 2, PublicationDate: "1958"},
+	}
+
+	got := CountFacets(books, FacetNames)
+	want := Facets{
+		FacetGenre:     {{Value: "Fantasy", Count: 2}, {Value: "Novel", Count: 1}},
+		FacetPrice:     {{Value: "0-10", Count: 1}, {Value: "10-20", Count: 1}, {Value: "20-50", Count: 1}, {Value: "50+", Count: 1}},
+		FacetDecade:    {{Value: "1950s", Count: 2}, {Value: "1990s", Count: 1}},
+		FacetStock:     {{Value: InStock, Count: 3}, {Value: OutOfStock, Count: 1}},
+		FacetPublisher: {{Value: "p1", Count: 2}, {Value: "p2", Count: 1}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestFacetSelectionsFilter(t *testing.T) {
+	book := models.Book{Price: 20, PublicationDate: "1995-01-01"}
+
+	for filter, want := range map[BookFilter]bool{
+		{PriceBand: "20-50"}:                  true,
+		{PriceBand: "10-20"}:                  false,
+		{Decade: "1990s"}:                     true,
+		{Decade: "1980s"}:                     false,
+		{PriceBand: "20-50", Decade: "1990s"}: true,
+	} {
+		if got := filter.Matches(book); got != want {
+			t.Errorf("Expected %+v to match %v, got %v", filter, want, got)
+		}
+	}
+
+	for _, filter := range []BookFilter{{PriceBand: "0-5"}, {Decade: "199"}, {Decade: "1995s"}} {
+		if err := filter.Validate(); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("Expected %+v to be rejected, got %v", filter, err)
+		}
+	}
+}
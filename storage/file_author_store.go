@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"context"
+	"sort"
+
+	"github.com/harshakumara/book-api/config"
+	"github.com/harshakumara/book-api/models"
+)
+
+// FileAuthorStore is an AuthorStore backed by the JSON file storage
+type FileAuthorStore struct {
+	fs *config.FileStorage
+}
+
+// NewFileAuthorStore creates an AuthorStore on top of the given file storage
+func NewFileAuthorStore(fs *config.FileStorage) *FileAuthorStore {
+	return &FileAuthorStore{fs: fs}
+}
+
+// List returns all authors ordered by name
+func (s *FileAuthorStore) List(ctx context.Context) ([]models.Author, error) {
+	var authors []models.Author
+	s.fs.View(func(ds *config.Dataset) error {
+		authors = append([]models.Author{}, ds.Authors...)
+		return nil
+	})
+
+	sort.SliceStable(authors, func(i, j int) bool {
+		if authors[i].Name != authors[j].Name {
+			return authors[i].Name < authors[j].Name
+		}
+		return authors[i].ID < authors[j].ID
+	})
+
+	return authors, nil
+}
+
+// Get returns a single author by ID
+func (s *FileAuthorStore) Get(ctx context.Context, id string) (models.Author, error) {
+	var author models.Author
+	err := s.fs.View(func(ds *config.Dataset) error {
+		i := findAuthor(ds.Authors, id)
+		if i < 0 {
+			return ErrNotFound
+		}
+
+		author = ds.Authors[i]
+		return nil
+	})
+
+	return author, err
+}
+
+// Create stores a new author
+func (s *FileAuthorStore) Create(ctx context.Context, author models.Author) (models.Author, error) {
+	if author.ID == "" {
+		author.ID = models.NewUUID()
+	}
+
+	err := s.fs.Transact(func(ds *config.Dataset) error {
+		if findAuthor(ds.Authors, author.ID) >= 0 {
+			return ErrConflict
+		}
+
+		ds.Authors = append(ds.Authors, author)
+		return nil
+	})
+	if err != nil {
+		return models.Author{}, err
+	}
+
+	return author, nil
+}
+
+// Update replaces the author with the given ID
+func (s *FileAuthorStore) Update(ctx context.Context, id string, author models.Author) (models.Author, error) {
+	// Preserve the original ID
+	author.ID = id
+
+	err := s.fs.Transact(func(ds *config.Dataset) error {
+		i := findAuthor(ds.Authors, id)
+		if i < 0 {
+			return ErrNotFound
+		}
+
+		ds.Authors[i] = author
+		return nil
+	})
+	if err != nil {
+		return models.Author{}, err
+	}
+
+	return author, nil
+}
+
+// Delete removes the author, handling their books according to policy.
+// The author, any affected books and their revisions change in a single
+// transaction.
+func (s *FileAuthorStore) Delete(ctx context.Context, id string, policy DeletePolicy) error {
+	return auditedTransact(ctx, s.fs, func(ds *config.Dataset) error {
+		i := findAuthor(ds.Authors, id)
+		if i < 0 {
+			return ErrNotFound
+		}
+
+		books := []models.Book{}
+		for _, b := range ds.Books {
+			if b.AuthorID != id {
+				books = append(books, b)
+				continue
+			}
+
+			switch policy {
+			case DeleteCascade:
+				// Drop the book along with its author
+			case DeleteOrphan:
+				b.AuthorID = ""
+				b.Version++
+				books = append(books, b)
+			default:
+				return ErrHasBooks
+			}
+		}
+
+		ds.Books = books
+		ds.Authors = append(ds.Authors[:i], ds.Authors[i+1:]...)
+		return nil
+	})
+}
+
+// findAuthor returns the index of the author with the given ID, or -1
+func findAuthor(authors []models.Author, id string) int {
+	for i, a := range authors {
+		if a.ID == id {
+			return i
+		}
+	}
+
+	return -1
+}
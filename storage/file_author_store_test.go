@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/harshakumara/book-api/config"
+	"github.com/harshakumara/book-api/models"
+)
+
+func newTestFileStores(t *testing.T) (*FileBookStore, *FileAuthorStore) {
+	fs := config.NewFileStorage(filepath.Join(t.TempDir(), "books.json"))
+	t.Cleanup(func() { fs.Close() })
+
+	return NewFileBookStore(fs), NewFileAuthorStore(fs)
+}
+
+func TestFileBookStoreRejectsUnknownAuthor(t *testing.T) {
+	ctx := context.Background()
+	books, authors := newTestFileStores(t)
+
+	var refErr *ReferenceError
+	if _, err := books.Create(ctx, models.Book{Title: "Orphan", AuthorID: "missing"}); !errors.As(err, &refErr) {
+		t.Fatalf("Expected ReferenceError, got %v", err)
+	}
+
+	author, err := authors.Create(ctx, models.Author{Name: "Known"})
+	if err != nil {
+		t.Fatalf("Create author failed: %v", err)
+	}
+	if _, err := books.Create(ctx, models.Book{Title: "Owned", AuthorID: author.ID}); err != nil {
+		t.Errorf("Expected book with existing author to be created, got %v", err)
+	}
+}
+
+func TestFileAuthorStoreDeletePolicies(t *testing.T) {
+	ctx := context.Background()
+
+	setup := func(t *testing.T) (*FileBookStore, *FileAuthorStore, models.Author, models.Book) {
+		books, authors := newTestFileStores(t)
+		author, _ := authors.Create(ctx, models.Author{Name: "Author"})
+		book, err := books.Create(ctx, models.Book{Title: "Book", AuthorID: author.ID})
+		if err != nil {
+			t.Fatalf("Create book failed: %v", err)
+		}
+		return books, authors, author, book
+	}
+
+	t.Run("reject", func(t *testing.T) {
+		_, authors, author, _ := setup(t)
+		if err := authors.Delete(ctx, author.ID, DeleteReject); !errors.Is(err, ErrHasBooks) {
+			t.Fatalf("Expected ErrHasBooks, got %v", err)
+		}
+		if _, err := authors.Get(ctx, author.ID); err != nil {
+			t.Errorf("Expected author to survive a rejected delete, got %v", err)
+		}
+	})
+
+	t.Run("cascade", func(t *testing.T) {
+		books, authors, author, book := setup(t)
+		if err := authors.Delete(ctx, author.ID, DeleteCascade); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		if _, err := books.Get(ctx, book.ID.Hex()); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected book to be deleted with its author, got %v", err)
+		}
+	})
+
+	t.Run("orphan", func(t *testing.T) {
+		books, authors, author, book := setup(t)
+		if err := authors.Delete(ctx, author.ID, DeleteOrphan); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		got, err := books.Get(ctx, book.ID.Hex())
+		if err != nil {
+			t.Fatalf("Expected book to survive, got %v", err)
+		}
+		if got.AuthorID != "" {
+			t.Errorf("Expected authorId to be cleared, got %q", got.AuthorID)
+		}
+	})
+}
@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/harshakumara/book-api/config"
+	"github.com/harshakumara/book-api/models"
+)
+
+// FileInventoryStore is an InventoryStore backed by the JSON file storage.
+// Every operation runs in a single locked transaction, so stock checks and
+// decrements cannot interleave, and records the stock change as a revision
+// of the book.
+type FileInventoryStore struct {
+	fs *config.FileStorage
+}
+
+// NewFileInventoryStore creates an InventoryStore on top of the given file storage
+func NewFileInventoryStore(fs *config.FileStorage) *FileInventoryStore {
+	return &FileInventoryStore{fs: fs}
+}
+
+// AdjustStock changes a book's quantity by delta and records the reason
+func (s *FileInventoryStore) AdjustStock(ctx context.Context, bookID string, delta int, reason string) (models.StockAdjustment, error) {
+	var adjustment models.StockAdjustment
+
+	err := auditedTransact(ctx, s.fs, func(ds *config.Dataset) error {
+		i := findBook(ds.Books, bookID)
+		if i < 0 {
+			return ErrNotFound
+		}
+		if ds.Books[i].Quantity+delta < 0 {
+			return ErrInsufficientStock
+		}
+
+		addStock(&ds.Books[i], delta)
+		adjustment = models.StockAdjustment{
+			ID:            models.NewUUID(),
+			BookID:        ds.Books[i].ID,
+			Delta:         delta,
+			Reason:        reason,
+			QuantityAfter: ds.Books[i].Quantity,
+			CreatedAt:     time.Now().UTC(),
+		}
+		ds.StockAdjustments = append(ds.StockAdjustments, adjustment)
+		return nil
+	})
+
+	return adjustment, err
+}
+
+// Reserve takes quantity off a book's stock for ttl
+func (s *FileInventoryStore) Reserve(ctx context.Context, bookID string, quantity int, ttl time.Duration) (models.Reservation, error) {
+	var reservation models.Reservation
+
+	err := auditedTransact(ctx, s.fs, func(ds *config.Dataset) error {
+		i := findBook(ds.Books, bookID)
+		if i < 0 {
+			return ErrNotFound
+		}
+		if ds.Books[i].Quantity < quantity {
+			return ErrInsufficientStock
+		}
+
+		addStock(&ds.Books[i], -quantity)
+		now := time.Now().UTC()
+		reservation = models.Reservation{
+			ID:        models.NewUUID(),
+			BookID:    ds.Books[i].ID,
+			Quantity:  quantity,
+			Status:    models.ReservationActive,
+			CreatedAt: now,
+			ExpiresAt: now.Add(ttl),
+			UpdatedAt: now,
+		}
+		ds.Reservations = append(ds.Reservations, reservation)
+		return nil
+	})
+
+	return reservation, err
+}
+
+// GetReservation returns a reservation by ID
+func (s *FileInventoryStore) GetReservation(ctx context.Context, id string) (models.Reservation, error) {
+	var reservation models.Reservation
+	err := s.fs.View(func(ds *config.Dataset) error {
+		i := findReservation(ds.Reservations, id)
+		if i < 0 {
+			return ErrNotFound
+		}
+
+		reservation = ds.Reservations[i]
+		return nil
+	})
+
+	return reservation, err
+}
+
+// ReleaseReservation returns an active reservation's stock to the book
+func (s *FileInventoryStore) ReleaseReservation(ctx context.Context, id string) (models.Reservation, error) {
+	return s.close(ctx, id, models.ReservationReleased)
+}
+
+// CommitReservation makes an active reservation's stock decrement permanent
+func (s *FileInventoryStore) CommitReservation(ctx context.Context, id string) (models.Reservation, error) {
+	return s.close(ctx, id, models.ReservationCommitted)
+}
+
+// ExpireReservations releases every active reservation that expired before now
+func (s *FileInventoryStore) ExpireReservations(ctx context.Context, now time.Time) (int, error) {
+	expired := 0
+	err := auditedTransact(ctx, s.fs, func(ds *config.Dataset) error {
+		for i := range ds.Reservations {
+			r := &ds.Reservations[i]
+			if r.Status == models.ReservationActive && !now.Before(r.ExpiresAt) {
+				restock(ds, r, models.ReservationExpired, now)
+				expired++
+			}
+		}
+		return nil
+	})
+
+	return expired, err
+}
+
+// close moves an active reservation to its final status.
+// An expired reservation is released instead and reported as expired.
+func (s *FileInventoryStore) close(ctx context.Context, id, status string) (models.Reservation, error) {
+	var reservation models.Reservation
+	var expired bool
+
+	err := auditedTransact(ctx, s.fs, func(ds *config.Dataset) error {
+		i := findReservation(ds.Reservations, id)
+		if i < 0 {
+			return ErrNotFound
+		}
+
+		r := &ds.Reservations[i]
+		switch r.Status {
+		case models.ReservationActive:
+		case models.ReservationExpired:
+			return ErrReservationExpired
+		default:
+			return ErrReservationClosed
+		}
+
+		now := time.Now().UTC()
+		switch {
+		case !now.Before(r.ExpiresAt):
+			restock(ds, r, models.ReservationExpired, now)
+			expired = true
+		case status == models.ReservationReleased:
+			restock(ds, r, status, now)
+		default:
+			r.Status = status
+			r.UpdatedAt = now
+		}
+
+		reservation = *r
+		return nil
+	})
+	if err == nil && expired {
+		err = ErrReservationExpired
+	}
+
+	return reservation, err
+}
+
+// restock closes a reservation and gives its quantity back to the book, if
+// the book still exists
+func restock(ds *config.Dataset, r *models.Reservation, status string, now time.Time) {
+	if i := findBook(ds.Books, r.BookID.Hex()); i >= 0 {
+		addStock(&ds.Books[i], r.Quantity)
+	}
+
+	r.Status = status
+	r.UpdatedAt = now
+}
+
+// addStock changes a book's quantity, which makes it a new version
+func addStock(book *models.Book, delta int) {
+	book.Quantity += delta
+	book.Version++
+}
+
+// findReservation returns the index of the reservation with the given ID, or -1
+func findReservation(reservations []models.Reservation, id string) int {
+	for i, r := range reservations {
+		if r.ID == id {
+			return i
+		}
+	}
+
+	return -1
+}
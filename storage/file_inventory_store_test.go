@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/harshakumara/book-api/config"
+	"github.com/harshakumara/book-api/models"
+)
+
+func newTestInventory(t *testing.T, quantity int) (*FileBookStore, *FileInventoryStore, string) {
+	fs := config.NewFileStorage(filepath.Join(t.TempDir(), "books.json"))
+	t.Cleanup(func() { fs.Close() })
+
+	books := NewFileBookStore(fs)
+	book, err := books.Create(context.Background(), models.Book{Title: "Stocked", Quantity: quantity})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	return books, NewFileInventoryStore(fs), book.ID.Hex()
+}
+
+func quantityOf(t *testing.T, books *FileBookStore, id string) int {
+	book, err := books.Get(context.Background(), id)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+
+	return book.Quantity
+}
+
+// TestConcurrentReservationsNeverOversell reserves more copies than exist
+// from many goroutines and checks stock stops at zero
+func TestConcurrentReservationsNeverOversell(t *testing.T) {
+	ctx := context.Background()
+	books, inventory, id := newTestInventory(t, 10)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	reserved, rejected := 0, 0
+	for i := 0; i < 25; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := inventory.Reserve(ctx, id, 1, time.Minute)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				reserved++
+			case errors.Is(err, ErrInsufficientStock):
+				rejected++
+			default:
+				t.Errorf("Reserve failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if reserved != 10 || rejected != 15 {
+		t.Errorf("Expected 10 reserved and 15 rejected, got %d and %d", reserved, rejected)
+	}
+	if q := quantityOf(t, books, id); q != 0 {
+		t.Errorf("Expected quantity 0, got %d", q)
+	}
+}
+
+func TestAdjustStock(t *testing.T) {
+	ctx := context.Background()
+	books, inventory, id := newTestInventory(t, 3)
+
+	adjustment, err := inventory.AdjustStock(ctx, id, 5, "restock")
+	if err != nil {
+		t.Fatalf("AdjustStock failed: %v", err)
+	}
+	if adjustment.QuantityAfter != 8 {
+		t.Errorf("Expected quantity after 8, got %d", adjustment.QuantityAfter)
+	}
+
+	if _, err := inventory.AdjustStock(ctx, id, -9, "shrinkage"); !errors.Is(err, ErrInsufficientStock) {
+		t.Errorf("Expected ErrInsufficientStock, got %v", err)
+	}
+	if q := quantityOf(t, books, id); q != 8 {
+		t.Errorf("Expected rejected adjustment to leave quantity 8, got %d", q)
+	}
+}
+
+func TestReservationLifecycle(t *testing.T) {
+	ctx := context.Background()
+	books, inventory, id := newTestInventory(t, 5)
+
+	released, _ := inventory.Reserve(ctx, id, 2, time.Minute)
+	committed, _ := inventory.Reserve(ctx, id, 2, time.Minute)
+	if q := quantityOf(t, books, id); q != 1 {
+		t.Fatalf("Expected quantity 1 while reserved, got %d", q)
+	}
+
+	if _, err := inventory.ReleaseReservation(ctx, released.ID); err != nil {
+		t.Fatalf("ReleaseReservation failed: %v", err)
+	}
+	if _, err := inventory.CommitReservation(ctx, committed.ID); err != nil {
+		t.Fatalf("CommitReservation failed: %v", err)
+	}
+	if q := quantityOf(t, books, id); q != 3 {
+		t.Errorf("Expected quantity 3 after release and commit, got %d", q)
+	}
+
+	if _, err := inventory.ReleaseReservation(ctx, committed.ID); !errors.Is(err, ErrReservationClosed) {
+		t.Errorf("Expected ErrReservationClosed releasing a committed reservation, got %v", err)
+	}
+}
+
+func TestExpireReservations(t *testing.T) {
+	ctx := context.Background()
+	books, inventory, id := newTestInventory(t, 5)
+
+	reservation, _ := inventory.Reserve(ctx, id, 4, time.Minute)
+
+	n, err := inventory.ExpireReservations(ctx, time.Now().Add(2*time.Minute))
+	if err != nil || n != 1 {
+		t.Fatalf("Expected 1 expired reservation, got %d (%v)", n, err)
+	}
+	if q := quantityOf(t, books, id); q != 5 {
+		t.Errorf("Expected expired reservation to restock, got quantity %d", q)
+	}
+	if _, err := inventory.CommitReservation(ctx, reservation.ID); !errors.Is(err, ErrReservationExpired) {
+		t.Errorf("Expected ErrReservationExpired, got %v", err)
+	}
+}
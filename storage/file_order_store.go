@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/harshakumara/book-api/config"
+	"github.com/harshakumara/book-api/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FileOrderStore is an OrderStore backed by the JSON file storage.
+// Status changes and the stock movements they cause happen in one transaction.
+type FileOrderStore struct {
+	fs *config.FileStorage
+}
+
+// NewFileOrderStore creates an OrderStore on top of the given file storage
+func NewFileOrderStore(fs *config.FileStorage) *FileOrderStore {
+	return &FileOrderStore{fs: fs}
+}
+
+// List returns orders, newest first, optionally only those with the given status
+func (s *FileOrderStore) List(ctx context.Context, status string) ([]models.Order, error) {
+	orders := []models.Order{}
+	err := s.fs.View(func(ds *config.Dataset) error {
+		for _, o := range ds.Orders {
+			if status == "" || o.Status == status {
+				orders = append(orders, o)
+			}
+		}
+		return nil
+	})
+
+	sortOrders(orders)
+	return orders, err
+}
+
+// Get returns a single order by ID
+func (s *FileOrderStore) Get(ctx context.Context, id string) (models.Order, error) {
+	var order models.Order
+	err := s.fs.View(func(ds *config.Dataset) error {
+		i := findOrder(ds.Orders, id)
+		if i < 0 {
+			return ErrNotFound
+		}
+
+		order = ds.Orders[i]
+		return nil
+	})
+
+	return order, err
+}
+
+// Create places a pending order, copying each item's title and price from its book
+func (s *FileOrderStore) Create(ctx context.Context, order models.Order) (models.Order, error) {
+	order.Items = append([]models.OrderItem{}, order.Items...)
+
+	err := s.fs.Transact(func(ds *config.Dataset) error {
+		books := map[primitive.ObjectID]models.Book{}
+		for i, item := range order.Items {
+			j := findBook(ds.Books, item.BookID.Hex())
+			if j < 0 {
+				return &ReferenceError{Field: fmt.Sprintf("items[%d].bookId", i), Resource: "book", ID: item.BookID.Hex()}
+			}
+			books[item.BookID] = ds.Books[j]
+		}
+
+		now := time.Now().UTC()
+		order.ID = models.NewUUID()
+		order.Status = models.OrderPending
+		order.CreatedAt = now
+		order.UpdatedAt = now
+		order.PriceItems(books)
+
+		ds.Orders = append(ds.Orders, order)
+		return nil
+	})
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	return order, nil
+}
+
+// Transition moves an order to a new status, moving stock as needed and
+// recording the stock changes as revisions of the books
+func (s *FileOrderStore) Transition(ctx context.Context, id, status string) (models.Order, error) {
+	var order models.Order
+
+	err := auditedTransact(ctx, s.fs, func(ds *config.Dataset) error {
+		i := findOrder(ds.Orders, id)
+		if i < 0 {
+			return ErrNotFound
+		}
+
+		o := &ds.Orders[i]
+		if !models.CanTransition(o.Status, status) {
+			return ErrInvalidTransition
+		}
+
+		switch {
+		case status == models.OrderPaid:
+			// Check every item before touching stock so a short item leaves
+			// the whole order untouched
+			for _, item := range o.Items {
+				j := findBook(ds.Books, item.BookID.Hex())
+				if j < 0 || ds.Books[j].Quantity < item.Quantity {
+					return fmt.Errorf("%w for book %s", ErrInsufficientStock, item.BookID.Hex())
+				}
+			}
+			for _, item := range o.Items {
+				addStock(&ds.Books[findBook(ds.Books, item.BookID.Hex())], -item.Quantity)
+			}
+		case status == models.OrderCancelled && o.Status == models.OrderPaid:
+			for _, item := range o.Items {
+				// Books deleted since the order was paid have nothing to restock
+				if j := findBook(ds.Books, item.BookID.Hex()); j >= 0 {
+					addStock(&ds.Books[j], item.Quantity)
+				}
+			}
+		}
+
+		o.Status = status
+		o.UpdatedAt = time.Now().UTC()
+		order = *o
+		return nil
+	})
+
+	return order, err
+}
+
+// findOrder returns the index of the order with the given ID, or -1
+func findOrder(orders []models.Order, id string) int {
+	for i, o := range orders {
+		if o.ID == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// sortOrders orders newest first, breaking ties by ID
+func sortOrders(orders []models.Order) {
+	sort.Slice(orders, func(i, j int) bool {
+		if !orders[i].CreatedAt.Equal(orders[j].CreatedAt) {
+			return orders[i].CreatedAt.After(orders[j].CreatedAt)
+		}
+		return orders[i].ID < orders[j].ID
+	})
+}
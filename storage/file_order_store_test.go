@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/harshakumara/book-api/models"
+)
+
+func TestFileOrderLifecycle(t *testing.T) {
+	ctx := context.Background()
+	books, _, id := newTestInventory(t, 5)
+	orders := NewFileOrderStore(books.fs)
+
+	book, _ := books.Get(ctx, id)
+	book.Price = 12.5
+	if _, err := books.Update(ctx, id, book, AnyVersion); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	order, err := orders.Create(ctx, models.Order{Items: []models.OrderItem{{BookID: book.ID, Quantity: 3, UnitPrice: 0.01}}})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if order.Status != models.OrderPending || order.Items[0].UnitPrice != 12.5 || order.Total != 37.5 {
+		t.Errorf("Expected pending order priced from the book, got %+v", order)
+	}
+	if q := quantityOf(t, books, id); q != 5 {
+		t.Errorf("Expected pending order to leave stock alone, got quantity %d", q)
+	}
+
+	// A later price change does not affect the placed order
+	book.Price = 99
+	books.Update(ctx, id, book, AnyVersion)
+
+	if _, err := orders.Transition(ctx, order.ID, models.OrderShipped); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Expected shipping a pending order to fail, got %v", err)
+	}
+
+	paid, err := orders.Transition(ctx, order.ID, models.OrderPaid)
+	if err != nil {
+		t.Fatalf("Pay failed: %v", err)
+	}
+	if paid.Total != 37.5 {
+		t.Errorf("Expected snapshot total 37.5, got %v", paid.Total)
+	}
+	if q := quantityOf(t, books, id); q != 2 {
+		t.Errorf("Expected paying to take 3 off stock, got quantity %d", q)
+	}
+
+	if _, err := orders.Transition(ctx, order.ID, models.OrderCancelled); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+	if q := quantityOf(t, books, id); q != 5 {
+		t.Errorf("Expected cancelling a paid order to restock, got quantity %d", q)
+	}
+}
+
+func TestFileOrderPayInsufficientStock(t *testing.T) {
+	ctx := context.Background()
+	books, _, id := newTestInventory(t, 2)
+	orders := NewFileOrderStore(books.fs)
+
+	plenty, _ := books.Create(ctx, models.Book{Title: "Plenty", Quantity: 10})
+	book, _ := books.Get(ctx, id)
+
+	order, err := orders.Create(ctx, models.Order{Items: []models.OrderItem{
+		{BookID: plenty.ID, Quantity: 4},
+		{BookID: book.ID, Quantity: 3},
+	}})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	if _, err := orders.Transition(ctx, order.ID, models.OrderPaid); !errors.Is(err, ErrInsufficientStock) {
+		t.Fatalf("Expected ErrInsufficientStock, got %v", err)
+	}
+	if q := quantityOf(t, books, plenty.ID.Hex()); q != 10 {
+		t.Errorf("Expected failed payment to leave every book untouched, got quantity %d", q)
+	}
+	if got, _ := orders.Get(ctx, order.ID); got.Status != models.OrderPending {
+		t.Errorf("Expected order to stay pending, got %s", got.Status)
+	}
+}
+
+func TestFileOrderRejectsUnknownBook(t *testing.T) {
+	books, _, _ := newTestInventory(t, 1)
+	orders := NewFileOrderStore(books.fs)
+
+	var refErr *ReferenceError
+	_, err := orders.Create(context.Background(), models.Order{Items: []models.OrderItem{{BookID: [12]byte{1}, Quantity: 1}}})
+	if !errors.As(err, &refErr) || refErr.Field != "items[0].bookId" {
+		t.Errorf("Expected ReferenceError on items[0].bookId, got %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"context"
+	"sort"
+
+	"github.com/harshakumara/book-api/config"
+	"github.com/harshakumara/book-api/models"
+)
+
+// FilePublisherStore is a PublisherStore backed by the JSON file storage
+type FilePublisherStore struct {
+	fs *config.FileStorage
+}
+
+// NewFilePublisherStore creates a PublisherStore on top of the given file storage
+func NewFilePublisherStore(fs *config.FileStorage) *FilePublisherStore {
+	return &FilePublisherStore{fs: fs}
+}
+
+// List returns all publishers ordered by name
+func (s *FilePublisherStore) List(ctx context.Context) ([]models.Publisher, error) {
+	var publishers []models.Publisher
+	s.fs.View(func(ds *config.Dataset) error {
+		publishers = append([]models.Publisher{}, ds.Publishers...)
+		return nil
+	})
+
+	sort.SliceStable(publishers, func(i, j int) bool {
+		if publishers[i].Name != publishers[j].Name {
+			return publishers[i].Name < publishers[j].Name
+		}
+		return publishers[i].ID < publishers[j].ID
+	})
+
+	return publishers, nil
+}
+
+// Get returns a single publisher by ID
+func (s *FilePublisherStore) Get(ctx context.Context, id string) (models.Publisher, error) {
+	var publisher models.Publisher
+	err := s.fs.View(func(ds *config.Dataset) error {
+		i := findPublisher(ds.Publishers, id)
+		if i < 0 {
+			return ErrNotFound
+		}
+
+		publisher = ds.Publishers[i]
+		return nil
+	})
+
+	return publisher, err
+}
+
+// Create stores a new publisher
+func (s *FilePublisherStore) Create(ctx context.Context, publisher models.Publisher) (models.Publisher, error) {
+	if publisher.ID == "" {
+		publisher.ID = models.NewUUID()
+	}
+
+	err := s.fs.Transact(func(ds *config.Dataset) error {
+		if findPublisher(ds.Publishers, publisher.ID) >= 0 {
+			return ErrConflict
+		}
+
+		ds.Publishers = append(ds.Publishers, publisher)
+		return nil
+	})
+	if err != nil {
+		return models.Publisher{}, err
+	}
+
+	return publisher, nil
+}
+
+// Update replaces the publisher with the given ID
+func (s *FilePublisherStore) Update(ctx context.Context, id string, publisher models.Publisher) (models.Publisher, error) {
+	// Preserve the original ID
+	publisher.ID = id
+
+	err := s.fs.Transact(func(ds *config.Dataset) error {
+		i := findPublisher(ds.Publishers, id)
+		if i < 0 {
+			return ErrNotFound
+		}
+
+		ds.Publishers[i] = publisher
+		return nil
+	})
+	if err != nil {
+		return models.Publisher{}, err
+	}
+
+	return publisher, nil
+}
+
+// Delete removes the publisher, handling its books according to policy.
+// The publisher, any affected books and their revisions change in a single
+// transaction.
+func (s *FilePublisherStore) Delete(ctx context.Context, id string, policy DeletePolicy) error {
+	return auditedTransact(ctx, s.fs, func(ds *config.Dataset) error {
+		i := findPublisher(ds.Publishers, id)
+		if i < 0 {
+			return ErrNotFound
+		}
+
+		books := []models.Book{}
+		for _, b := range ds.Books {
+			if b.PublisherID != id {
+				books = append(books, b)
+				continue
+			}
+
+			switch policy {
+			case DeleteCascade:
+				// Drop the book along with its publisher
+			case DeleteOrphan:
+				b.PublisherID = ""
+				b.Version++
+				books = append(books, b)
+			default:
+				return ErrHasBooks
+			}
+		}
+
+		ds.Books = books
+		ds.Publishers = append(ds.Publishers[:i], ds.Publishers[i+1:]...)
+		return nil
+	})
+}
+
+// findPublisher returns the index of the publisher with the given ID, or -1
+func findPublisher(publishers []models.Publisher, id string) int {
+	for i, a := range publishers {
+		if a.ID == id {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/harshakumara/book-api/config"
+	"github.com/harshakumara/book-api/models"
+)
+
+func newTestPublisherStores(t *testing.T) (*FileBookStore, *FilePublisherStore) {
+	fs := config.NewFileStorage(filepath.Join(t.TempDir(), "books.json"))
+	t.Cleanup(func() { fs.Close() })
+
+	return NewFileBookStore(fs), NewFilePublisherStore(fs)
+}
+
+func TestFileBookStoreRejectsUnknownPublisher(t *testing.T) {
+	ctx := context.Background()
+	books, publishers := newTestPublisherStores(t)
+
+	var refErr *ReferenceError
+	if _, err := books.Create(ctx, models.Book{Title: "Dangling", PublisherID: "missing"}); !errors.As(err, &refErr) || refErr.Field != "publisherId" {
+		t.Fatalf("Expected publisherId ReferenceError, got %v", err)
+	}
+
+	publisher, err := publishers.Create(ctx, models.Publisher{Name: "Press", Imprints: []string{"Classics"}})
+	if err != nil {
+		t.Fatalf("Create publisher failed: %v", err)
+	}
+	if _, err := books.Create(ctx, models.Book{Title: "Published", PublisherID: publisher.ID}); err != nil {
+		t.Errorf("Expected book with existing publisher to be created, got %v", err)
+	}
+}
+
+func TestFilePublisherStoreDeletePolicies(t *testing.T) {
+	ctx := context.Background()
+
+	setup := func(t *testing.T) (*FileBookStore, *FilePublisherStore, models.Publisher, models.Book) {
+		books, publishers := newTestPublisherStores(t)
+		publisher, _ := publishers.Create(ctx, models.Publisher{Name: "Press"})
+		book, err := books.Create(ctx, models.Book{Title: "Book", PublisherID: publisher.ID})
+		if err != nil {
+			t.Fatalf("Create book failed: %v", err)
+		}
+		return books, publishers, publisher, book
+	}
+
+	t.Run("reject", func(t *testing.T) {
+		_, publishers, publisher, _ := setup(t)
+		if err := publishers.Delete(ctx, publisher.ID, DeleteReject); !errors.Is(err, ErrHasBooks) {
+			t.Fatalf("Expected ErrHasBooks, got %v", err)
+		}
+		if _, err := publishers.Get(ctx, publisher.ID); err != nil {
+			t.Errorf("Expected publisher to survive a rejected delete, got %v", err)
+		}
+	})
+
+	t.Run("cascade", func(t *testing.T) {
+		books, publishers, publisher, book := setup(t)
+		if err := publishers.Delete(ctx, publisher.ID, DeleteCascade); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		if _, err := books.Get(ctx, book.ID.Hex()); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected book to be deleted with its publisher, got %v", err)
+		}
+	})
+
+	t.Run("orphan", func(t *testing.T) {
+		books, publishers, publisher, book := setup(t)
+		if err := publishers.Delete(ctx, publisher.ID, DeleteOrphan); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		got, err := books.Get(ctx, book.ID.Hex())
+		if err != nil {
+			t.Fatalf("Expected book to survive, got %v", err)
+		}
+		if got.PublisherID != "" {
+			t.Errorf("Expected publisherId to be cleared, got %q", got.PublisherID)
+		}
+	})
+}
@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/harshakumara/book-api/config"
+	"github.com/harshakumara/book-api/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FileRevisionStore is a RevisionStore backed by the JSON file storage.
+// The file stores record their own book changes in the same transaction as
+// the change (see auditedTransact), so their books need no AuditedBookStore.
+type FileRevisionStore struct {
+	fs *config.FileStorage
+}
+
+// NewFileRevisionStore creates a RevisionStore on top of the given file storage
+func NewFileRevisionStore(fs *config.FileStorage) *FileRevisionStore {
+	return &FileRevisionStore{fs: fs}
+}
+
+// Record appends revisions to the log in one transaction
+func (s *FileRevisionStore) Record(ctx context.Context, revisions []models.Revision) error {
+	return s.fs.Transact(func(ds *config.Dataset) error {
+		ds.Revisions = append(ds.Revisions, revisions...)
+		return nil
+	})
+}
+
+// Get returns a single revision by ID
+func (s *FileRevisionStore) Get(ctx context.Context, id string) (models.Revision, error) {
+	var revision models.Revision
+	err := s.fs.View(func(ds *config.Dataset) error {
+		var err error
+		revision, err = findRevision(ds.Revisions, id)
+		return err
+	})
+
+	return revision, err
+}
+
+// History returns every revision of a book, oldest first
+func (s *FileRevisionStore) History(ctx context.Context, bookID string) ([]models.Revision, error) {
+	var history []models.Revision
+	err := s.fs.View(func(ds *config.Dataset) error {
+		history = bookHistory(ds.Revisions, bookID)
+		return nil
+	})
+
+	return history, err
+}
+
+// Since returns up to limit revisions recorded at or after since, oldest first
+func (s *FileRevisionStore) Since(ctx context.Context, since time.Time, limit int) ([]models.Revision, error) {
+	var revisions []models.Revision
+	err := s.fs.View(func(ds *config.Dataset) error {
+		revisions = revisionsSince(ds.Revisions, since, limit)
+		return nil
+	})
+
+	return revisions, err
+}
+
+// auditedTransact runs fn as a FileStorage transaction and appends a
+// revision of every book fn created, changed or deleted to the same
+// transaction, so a change and its revision are written together or not at
+// all. Revisions are attributed to the ChangeInfo of ctx.
+func auditedTransact(ctx context.Context, fs *config.FileStorage, fn func(ds *config.Dataset) error) error {
+	return fs.Transact(func(ds *config.Dataset) error {
+		before := append([]models.Book{}, ds.Books...)
+		if err := fn(ds); err != nil {
+			return err
+		}
+
+		ds.Revisions = append(ds.Revisions, bookRevisions(ctx, before, ds.Books)...)
+		return nil
+	})
+}
+
+// bookRevisions describes the change from one set of books to another: a
+// revision for every book that was added, is at a new version or was
+// removed, in that order
+func bookRevisions(ctx context.Context, before, after []models.Book) []models.Revision {
+	removed := make(map[primitive.ObjectID]int, len(before))
+	for i, b := range before {
+		removed[b.ID] = i
+	}
+
+	var revisions []models.Revision
+	for i := range after {
+		j, ok := removed[after[i].ID]
+		switch {
+		case !ok:
+			revisions = append(revisions, newRevision(ctx, nil, &after[i])...)
+		case before[j].Version != after[i].Version:
+			revisions = append(revisions, newRevision(ctx, &before[j], &after[i])...)
+		}
+		delete(removed, after[i].ID)
+	}
+	for i := range before {
+		if _, ok := removed[before[i].ID]; ok {
+			revisions = append(revisions, newRevision(ctx, &before[i], nil)...)
+		}
+	}
+
+	return revisions
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/harshakumara/book-api/config"
+	"github.com/harshakumara/book-api/models"
+)
+
+func TestFileRevisionStorePersists(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "books.json")
+
+	fs := config.NewFileStorage(path)
+	books := NewFileBookStore(fs)
+	book, _ := books.Create(ctx, models.Book{Title: "Middlemarch", Pages: 880})
+	book.Pages = 904
+	books.Update(ctx, book.ID.Hex(), book, AnyVersion)
+	fs.Close()
+
+	// Revisions survive a restart through the journal
+	fs, err := config.OpenFileStorage(path)
+	if err != nil {
+		t.Fatalf("OpenFileStorage failed: %v", err)
+	}
+	defer fs.Close()
+	revisions := NewFileRevisionStore(fs)
+
+	history, err := revisions.History(ctx, book.ID.Hex())
+	if err != nil || len(history) != 2 {
+		t.Fatalf("Expected 2 revisions, got %+v, %v", history, err)
+	}
+	change := history[1].Changes
+	if len(change) != 1 || change[0].Field != "pages" || change[0].Before != 880.0 || change[0].After != 904.0 {
+		t.Errorf("Expected the pages change, got %+v", change)
+	}
+
+	got, err := revisions.Get(ctx, history[0].ID.Hex())
+	if err != nil || got.Action != models.RevisionCreate || got.Book == nil || got.Book.Pages != 880 {
+		t.Errorf("Expected the create revision, got %+v, %v", got, err)
+	}
+	if _, err := revisions.Get(ctx, "nope"); !errors.Is(err, ErrInvalidID) {
+		t.Errorf("Expected ErrInvalidID, got %v", err)
+	}
+	if _, err := revisions.Get(ctx, book.ID.Hex()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestFileStoresRecordEveryBookChange(t *testing.T) {
+	ctx := WithChangeInfo(context.Background(), ChangeInfo{Actor: "bob", RequestID: "req-7"})
+	fs := config.NewFileStorage(filepath.Join(t.TempDir(), "books.json"))
+	defer fs.Close()
+
+	books := NewFileBookStore(fs)
+	authors := NewFileAuthorStore(fs)
+	inventory := NewFileInventoryStore(fs)
+	orders := NewFileOrderStore(fs)
+	revisions := NewFileRevisionStore(fs)
+
+	author, _ := authors.Create(ctx, models.Author{Name: "George Eliot"})
+	kept, _ := books.Create(ctx, models.Book{Title: "Middlemarch", AuthorID: author.ID, Quantity: 5})
+	dropped, _ := books.Create(ctx, models.Book{Title: "Romola", AuthorID: author.ID})
+
+	inventory.AdjustStock(ctx, kept.ID.Hex(), 2, "restock")
+	// A failed write records nothing
+	if _, err := inventory.Reserve(ctx, kept.ID.Hex(), 100, time.Minute); !errors.Is(err, ErrInsufficientStock) {
+		t.Fatalf("Expected ErrInsufficientStock, got %v", err)
+	}
+	reservation, _ := inventory.Reserve(ctx, kept.ID.Hex(), 1, time.Minute)
+	inventory.ReleaseReservation(ctx, reservation.ID)
+	order, _ := orders.Create(ctx, models.Order{Items: []models.OrderItem{{BookID: kept.ID, Quantity: 3}}})
+	orders.Transition(ctx, order.ID, models.OrderPaid)
+	orders.Transition(ctx, order.ID, models.OrderCancelled)
+	books.Delete(ctx, dropped.ID.Hex(), AnyVersion)
+	authors.Delete(ctx, author.ID, DeleteOrphan)
+
+	history, _ := revisions.History(ctx, kept.ID.Hex())
+	var quantities []interface{}
+	for _, r := range history[1 : len(history)-1] {
+		if len(r.Changes) != 1 || r.Changes[0].Field != "quantity" {
+			t.Fatalf("Expected a quantity change, got %+v", r.Changes)
+		}
+		quantities = append(quantities, r.Changes[0].After)
+	}
+	if want := []interface{}{7.0, 6.0, 7.0, 4.0, 7.0}; !reflect.DeepEqual(quantities, want) {
+		t.Errorf("Expected quantities %v, got %v", want, quantities)
+	}
+	orphaned := history[len(history)-1]
+	if orphaned.Version != 7 || len(orphaned.Changes) != 1 || orphaned.Changes[0].Field != "authorId" || orphaned.Changes[0].After != "" {
+		t.Errorf("Expected the orphaning to be recorded, got %+v", orphaned)
+	}
+	for _, r := range history {
+		if r.Actor != "bob" || r.RequestID != "req-7" {
+			t.Errorf("Expected the revision to be attributed, got %+v", r)
+		}
+	}
+
+	// Cascading deletes are recorded too
+	author, _ = authors.Create(ctx, models.Author{Name: "Anne Brontë"})
+	cascaded, _ := books.Create(ctx, models.Book{Title: "Agnes Grey", AuthorID: author.ID})
+	authors.Delete(ctx, author.ID, DeleteCascade)
+	history, _ = revisions.History(ctx, cascaded.ID.Hex())
+	if len(history) != 2 || history[1].Action != models.RevisionDelete {
+		t.Errorf("Expected the cascaded delete to be recorded, got %+v", history)
+	}
+}
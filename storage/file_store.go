@@ -0,0 +1,407 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/harshakumara/book-api/config"
+	"github.com/harshakumara/book-api/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FileBookStore is a BookStore backed by a JSON file.
+// It keeps an index of ISBNs, built on first use, to keep them unique.
+// Every write records its revisions in the same transaction.
+type FileBookStore struct {
+	fs *config.FileStorage
+
+	mutex sync.Mutex
+	isbns isbnIndex
+}
+
+// NewFileBookStore creates a BookStore on top of the given file storage
+func NewFileBookStore(fs *config.FileStorage) *FileBookStore {
+	return &FileBookStore{fs: fs}
+}
+
+// List returns one page of books matching the query
+func (s *FileBookStore) List(ctx context.Context, q BookQuery) (BookPage, error) {
+	books, err := s.fs.ReadBooks()
+	if err != nil {
+		return BookPage{}, err
+	}
+
+	return applyQuery(books, q)
+}
+
+// Facets counts the named facets over the books matching the filter
+func (s *FileBookStore) Facets(ctx context.Context, filter BookFilter, names []string) (Facets, error) {
+	books, err := s.fs.ReadBooks()
+	if err != nil {
+		return nil, err
+	}
+
+	return countMatchingFacets(books, filter, names)
+}
+
+// Get returns a single book by ID
+func (s *FileBookStore) Get(ctx context.Context, id string) (models.Book, error) {
+	books, err := s.fs.ReadBooks()
+	if err != nil {
+		return models.Book{}, err
+	}
+
+	for _, b := range books {
+		if b.ID.Hex() == id {
+			return b, nil
+		}
+	}
+
+	return models.Book{}, ErrNotFound
+}
+
+// GetMany returns the books with the given IDs
+func (s *FileBookStore) GetMany(ctx context.Context, ids []string) ([]models.Book, error) {
+	books, err := s.fs.ReadBooks()
+	if err != nil {
+		return nil, err
+	}
+
+	return booksWithIDs(books, ids), nil
+}
+
+// Create stores a new book
+func (s *FileBookStore) Create(ctx context.Context, book models.Book) (models.Book, error) {
+	// Generate new ID if not provided
+	if book.ID.IsZero() {
+		book.ID = primitive.NewObjectID()
+	}
+	if book.Version <= 0 {
+		book.Version = 1
+	}
+
+	err := s.transact(ctx, func(ds *config.Dataset, isbns isbnIndex) error {
+		if err := checkBookReferences(ds, book); err != nil {
+			return err
+		}
+		if err := isbns.claim(ds.Books, book); err != nil {
+			return err
+		}
+
+		ds.Books = append(ds.Books, book)
+		return nil
+	})
+	if err != nil {
+		return models.Book{}, err
+	}
+
+	return book, nil
+}
+
+// Update replaces the book with the given ID
+func (s *FileBookStore) Update(ctx context.Context, id string, book models.Book, version int64) (models.Book, error) {
+	return s.Patch(ctx, id, version, func(models.Book) (models.Book, error) {
+		return book, nil
+	})
+}
+
+// Patch applies a change to the book with the given ID in one transaction
+func (s *FileBookStore) Patch(ctx context.Context, id string, version int64, apply func(models.Book) (models.Book, error)) (models.Book, error) {
+	var book models.Book
+
+	err := s.transact(ctx, func(ds *config.Dataset, isbns isbnIndex) error {
+		i, err := findBookVersion(ds.Books, id, version)
+		if err != nil {
+			return err
+		}
+
+		current := ds.Books[i]
+		if book, err = apply(current); err != nil {
+			return err
+		}
+		// Preserve the original ID
+		book.ID = current.ID
+		book.Version = current.Version + 1
+
+		if err := checkBookReferences(ds, book); err != nil {
+			return err
+		}
+		if err := isbns.claim(ds.Books, book); err != nil {
+			return err
+		}
+
+		ds.Books[i] = book
+		return nil
+	})
+	if err != nil {
+		return models.Book{}, err
+	}
+
+	return book, nil
+}
+
+// Delete removes the book with the given ID
+func (s *FileBookStore) Delete(ctx context.Context, id string, version int64) error {
+	return auditedTransact(ctx, s.fs, func(ds *config.Dataset) error {
+		i, err := findBookVersion(ds.Books, id, version)
+		if err != nil {
+			return err
+		}
+
+		ds.Books = append(ds.Books[:i:i], ds.Books[i+1:]...)
+		return nil
+	})
+}
+
+// Search returns books whose title or description contains the keyword
+func (s *FileBookStore) Search(ctx context.Context, keyword string) ([]models.Book, error) {
+	allBooks, err := s.fs.ReadBooks()
+	if err != nil {
+		return nil, err
+	}
+
+	keyword = strings.ToLower(keyword)
+
+	// Use goroutines and channels for concurrent search
+	results := make(chan models.Book)
+	var wg sync.WaitGroup
+
+	// Divide the books into chunks for parallel processing
+	chunkSize := 10 // Adjust based on expected dataset size
+	if len(allBooks) < chunkSize {
+		chunkSize = 1
+	}
+
+	chunks := (len(allBooks) + chunkSize - 1) / chunkSize
+
+	// Launch goroutines to search each chunk
+	for i := 0; i < chunks; i++ {
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+
+			for j := start; j < end && j < len(allBooks); j++ {
+				book := allBooks[j]
+				title := strings.ToLower(book.Title)
+				description := strings.ToLower(book.Description)
+
+				if strings.Contains(title, keyword) || strings.Contains(description, keyword) {
+					results <- book
+				}
+			}
+		}(i*chunkSize, (i+1)*chunkSize)
+	}
+
+	// Close the channel once all goroutines are done
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Collect results
+	searchResults := []models.Book{}
+	for book := range results {
+		searchResults = append(searchResults, book)
+	}
+
+	return searchResults, nil
+}
+
+// SearchPattern returns books whose title or description matches the pattern
+func (s *FileBookStore) SearchPattern(ctx context.Context, p *Pattern) ([]models.Book, error) {
+	allBooks, err := s.fs.ReadBooks()
+	if err != nil {
+		return nil, err
+	}
+
+	return searchPattern(ctx, allBooks, p)
+}
+
+// SearchStructured returns the books matching a structured query
+func (s *FileBookStore) SearchStructured(ctx context.Context, q *StructuredQuery) ([]models.Book, error) {
+	allBooks, err := s.fs.ReadBooks()
+	if err != nil {
+		return nil, err
+	}
+
+	return searchStructured(ctx, allBooks, q)
+}
+
+// FindByISBN returns the books whose ISBN is one of isbns, using the index
+func (s *FileBookStore) FindByISBN(ctx context.Context, isbns []string) ([]models.Book, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	found := []models.Book{}
+	err := s.fs.View(func(ds *config.Dataset) error {
+		if s.isbns == nil {
+			s.isbns = buildISBNIndex(ds.Books)
+		}
+
+		seen := map[string]bool{}
+		for _, isbn := range isbns {
+			if i := s.isbns.owner(ds.Books, isbn); i >= 0 && !seen[isbn] {
+				seen[isbn] = true
+				found = append(found, ds.Books[i])
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return found, nil
+}
+
+// transact runs fn in an audited transaction with a copy of the ISBN
+// index, which replaces the store's index only if the transaction commits
+func (s *FileBookStore) transact(ctx context.Context, fn func(ds *config.Dataset, isbns isbnIndex) error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var next isbnIndex
+	err := auditedTransact(ctx, s.fs, func(ds *config.Dataset) error {
+		if s.isbns == nil {
+			s.isbns = buildISBNIndex(ds.Books)
+		}
+
+		next = s.isbns.clone()
+		return fn(ds, next)
+	})
+	if err == nil {
+		s.isbns = next
+	}
+
+	return err
+}
+
+// checkBookReferences verifies that the records a book points at exist
+func checkBookReferences(ds *config.Dataset, book models.Book) error {
+	if book.AuthorID != "" && findAuthor(ds.Authors, book.AuthorID) < 0 {
+		return &ReferenceError{Field: "authorId", Resource: "author", ID: book.AuthorID}
+	}
+	if book.PublisherID != "" && findPublisher(ds.Publishers, book.PublisherID) < 0 {
+		return &ReferenceError{Field: "publisherId", Resource: "publisher", ID: book.PublisherID}
+	}
+
+	return nil
+}
+
+// findBookVersion returns the index of the book with the given ID, checking
+// that it is at version unless version is AnyVersion
+func findBookVersion(books []models.Book, id string, version int64) (int, error) {
+	i := findBook(books, id)
+	if i < 0 {
+		return -1, ErrNotFound
+	}
+	if version != AnyVersion && books[i].Version != version {
+		return -1, ErrVersionMismatch
+	}
+
+	return i, nil
+}
+
+// findBook returns the index of the book with the given ID, or -1
+func findBook(books []models.Book, id string) int {
+	for i, b := range books {
+		if b.ID.Hex() == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// BulkUpsert creates or replaces every book in a single transaction
+func (s *FileBookStore) BulkUpsert(ctx context.Context, books []models.Book, atomic bool) ([]BulkResult, error) {
+	results := make([]BulkResult, len(books))
+
+	err := s.transact(ctx, func(ds *config.Dataset, isbns isbnIndex) error {
+		seen := map[primitive.ObjectID]bool{}
+		for i, book := range books {
+			if book.ID.IsZero() {
+				book.ID = primitive.NewObjectID()
+			}
+			if seen[book.ID] {
+				results[i] = BulkResult{Book: book, Err: repeatedBookError()}
+				continue
+			}
+			seen[book.ID] = true
+			if err := checkBulkVersion(ds.Books, book); err != nil {
+				results[i] = BulkResult{Book: book, Err: err}
+				continue
+			}
+			if err := checkBookReferences(ds, book); err != nil {
+				results[i] = BulkResult{Book: book, Err: err}
+				continue
+			}
+			if err := isbns.claim(ds.Books, book); err != nil {
+				results[i] = BulkResult{Book: book, Err: err}
+				continue
+			}
+			results[i] = upsertBook(&ds.Books, book)
+		}
+
+		if atomic && anyFailed(results) {
+			return errBulkAborted
+		}
+		return nil
+	})
+	if err == errBulkAborted {
+		notApplied(results)
+		return results, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// DeleteMany removes the books with the given IDs in a single transaction
+func (s *FileBookStore) DeleteMany(ctx context.Context, ids []string, versions []int64, atomic bool) ([]error, error) {
+	errs := make([]error, len(ids))
+
+	err := auditedTransact(ctx, s.fs, func(ds *config.Dataset) error {
+		for i, id := range ids {
+			errs[i] = deleteBook(&ds.Books, id, bulkVersion(versions, i))
+		}
+
+		if atomic && anyErr(errs) {
+			return errBulkAborted
+		}
+		return nil
+	})
+	if err == errBulkAborted {
+		notAppliedErrs(errs)
+		return errs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return errs, nil
+}
+
+// DeleteWhere removes every book matching the filter in a single transaction
+func (s *FileBookStore) DeleteWhere(ctx context.Context, filter BookFilter) (int64, error) {
+	var deleted int64
+
+	err := auditedTransact(ctx, s.fs, func(ds *config.Dataset) error {
+		kept := []models.Book{}
+		for _, b := range ds.Books {
+			if filter.Matches(b) {
+				deleted++
+			} else {
+				kept = append(kept, b)
+			}
+		}
+
+		ds.Books = kept
+		return nil
+	})
+
+	return deleted, err
+}
@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/harshakumara/book-api/config"
+	"github.com/harshakumara/book-api/models"
+)
+
+func TestFileBookStoreCRUD(t *testing.T) {
+	ctx := context.Background()
+	store := NewFileBookStore(config.NewFileStorage(filepath.Join(t.TempDir(), "books.json")))
+
+	created, err := store.Create(ctx, models.Book{Title: "Test Book", Description: "A searchable description"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if created.ID.IsZero() {
+		t.Fatal("Expected Create to generate an ID")
+	}
+
+	got, err := store.Get(ctx, created.ID.Hex())
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Title != "Test Book" {
+		t.Errorf("Expected title %q, got %q", "Test Book", got.Title)
+	}
+
+	results, err := store.Search(ctx, "SEARCHABLE")
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("Expected 1 search result, got %d", len(results))
+	}
+
+	if _, err := store.Update(ctx, created.ID.Hex(), models.Book{Title: "Updated"}, AnyVersion); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	got, _ = store.Get(ctx, created.ID.Hex())
+	if got.Title != "Updated" {
+		t.Errorf("Expected updated title, got %q", got.Title)
+	}
+
+	if err := store.Delete(ctx, created.ID.Hex(), AnyVersion); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := store.Get(ctx, created.ID.Hex()); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound after delete, got %v", err)
+	}
+	if err := store.Delete(ctx, created.ID.Hex(), AnyVersion); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound deleting missing book, got %v", err)
+	}
+}
+
+func TestFileBookStoreConcurrentCreates(t *testing.T) {
+	ctx := context.Background()
+	store := NewFileBookStore(config.NewFileStorage(filepath.Join(t.TempDir(), "books.json")))
+
+	const writers = 50
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := store.Create(ctx, models.Book{Title: fmt.Sprintf("Book %d", i)}); err != nil {
+				t.Errorf("Create failed: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	page, err := store.List(ctx, BookQuery{})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(page.Books) != writers {
+		t.Errorf("Expected %d books, got %d", writers, len(page.Books))
+	}
+}
+
+func TestFileBookStoreVersions(t *testing.T) {
+	ctx := context.Background()
+	store := NewFileBookStore(config.NewFileStorage(filepath.Join(t.TempDir(), "books.json")))
+
+	book, _ := store.Create(ctx, models.Book{Title: "Versioned"})
+	if book.Version != 1 {
+		t.Fatalf("Expected version 1 on create, got %d", book.Version)
+	}
+
+	updated, err := store.Update(ctx, book.ID.Hex(), models.Book{Title: "Versioned", Version: 99}, 1)
+	if err != nil || updated.Version != 2 {
+		t.Fatalf("Expected version 2 after update, got %d (%v)", updated.Version, err)
+	}
+
+	if _, err := store.Update(ctx, book.ID.Hex(), models.Book{Title: "Stale"}, 1); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("Expected ErrVersionMismatch, got %v", err)
+	}
+	if err := store.Delete(ctx, book.ID.Hex(), 1); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("Expected ErrVersionMismatch deleting a stale version, got %v", err)
+	}
+	if err := store.Delete(ctx, book.ID.Hex(), 2); err != nil {
+		t.Errorf("Expected delete at the current version to succeed, got %v", err)
+	}
+}
+
+func TestFileBookStoreBulkUpsert(t *testing.T) {
+	ctx := context.Background()
+	store := NewFileBookStore(config.NewFileStorage(filepath.Join(t.TempDir(), "books.json")))
+
+	batch := []models.Book{{Title: "Kept"}, {Title: "Orphan", AuthorID: "no-such-author"}}
+
+	// A bad reference aborts the whole atomic batch
+	results, err := store.BulkUpsert(ctx, batch, true)
+	if err != nil {
+		t.Fatalf("BulkUpsert failed: %v", err)
+	}
+	var refErr *ReferenceError
+	if !errors.Is(results[0].Err, ErrNotApplied) || !errors.As(results[1].Err, &refErr) {
+		t.Fatalf("Expected not applied and a reference error, got %v, %v", results[0].Err, results[1].Err)
+	}
+	if page, _ := store.List(ctx, BookQuery{}); page.Total != 0 {
+		t.Fatalf("Expected nothing written, got %d books", page.Total)
+	}
+
+	// Best effort writes the rest
+	results, err = store.BulkUpsert(ctx, batch, false)
+	if err != nil {
+		t.Fatalf("BulkUpsert failed: %v", err)
+	}
+	if results[0].Err != nil || !results[0].Created || results[1].Err == nil {
+		t.Fatalf("Expected the first book created and the second rejected, got %+v", results)
+	}
+
+	replaced := results[0].Book
+	replaced.Title = "Replaced"
+	results, _ = store.BulkUpsert(ctx, []models.Book{replaced}, true)
+	if results[0].Created || results[0].Book.Version != 2 {
+		t.Errorf("Expected a replacement at version 2, got %+v", results[0])
+	}
+}
+
+func TestFileBookStoreUniqueISBN(t *testing.T) {
+	ctx := context.Background()
+	store := NewFileBookStore(config.NewFileStorage(filepath.Join(t.TempDir(), "books.json")))
+
+	first, err := store.Create(ctx, models.Book{Title: "First", ISBN: "9780306406157"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := store.Create(ctx, models.Book{Title: "Second", ISBN: "9780306406157"}); !errors.Is(err, ErrDuplicateISBN) || !errors.Is(err, ErrConflict) {
+		t.Fatalf("Expected ErrDuplicateISBN, got %v", err)
+	}
+
+	// A deleted book frees its ISBN
+	if err := store.Delete(ctx, first.ID.Hex(), AnyVersion); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := store.Create(ctx, models.Book{Title: "Second", ISBN: "9780306406157"}); err != nil {
+		t.Fatalf("Expected the ISBN to be free again, got %v", err)
+	}
+
+	// An aborted transaction does not keep its claims
+	batch := []models.Book{{Title: "Claimed", ISBN: "9780262033848"}, {Title: "Orphan", AuthorID: "no-such-author"}}
+	if _, err := store.BulkUpsert(ctx, batch, true); err != nil {
+		t.Fatalf("BulkUpsert failed: %v", err)
+	}
+	if _, err := store.Create(ctx, models.Book{Title: "Claimed", ISBN: "9780262033848"}); err != nil {
+		t.Fatalf("Expected the aborted batch to leave the ISBN free, got %v", err)
+	}
+
+	found, err := store.FindByISBN(ctx, []string{"9780262033848", "9780262033848", "9780131103627"})
+	if err != nil || len(found) != 1 || found[0].Title != "Claimed" {
+		t.Errorf("Expected one book by ISBN, got %+v, %v", found, err)
+	}
+}
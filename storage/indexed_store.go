@@ -0,0 +1,568 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/harshakumara/book-api/models"
+	"github.com/harshakumara/book-api/search"
+)
+
+// searchWeights makes a match in the title count more than one in the
+// author's name or genre, and those more than one in the description
+var searchWeights = map[string]float64{
+	"title":       3,
+	"author":      2,
+	"genre":       2,
+	"description": 1,
+}
+
+// SearchHit is a book with its relevance to a full-text query
+type SearchHit struct {
+	models.Book
+	Score float64 `json:"score"`
+}
+
+// maxSuggestions caps the corrected queries SearchFuzzy returns
+const maxSuggestions = 3
+
+// RankedSearcher is implemented by stores that rank full-text search
+// results by relevance
+type RankedSearcher interface {
+	// SearchRanked returns the books matching any word of the query, most
+	// relevant first. Limit 0 means no limit.
+	SearchRanked(ctx context.Context, query string, limit int) ([]SearchHit, error)
+	// SearchFuzzy is SearchRanked tolerating typos: query words that match
+	// nothing also match title and author words up to fuzziness edits away
+	// (search.AutoFuzziness picks by word length). It also returns the
+	// query with those words corrected, if any were.
+	SearchFuzzy(ctx context.Context, query string, fuzziness, limit int) ([]SearchHit, []string, error)
+}
+
+// Completion is a title, author name or ISBN that completes a typed prefix.
+// ID is the book's ID, or the author's for author completions.
+type Completion struct {
+	Kind string `json:"kind"`
+	Text string `json:"text"`
+	ID   string `json:"id"`
+}
+
+// Kinds of completion
+const (
+	CompletionTitle  = "title"
+	CompletionAuthor = "author"
+	CompletionISBN   = "isbn"
+)
+
+// Autocompleter is implemented by stores that complete search-as-you-type
+// prefixes from an index rather than by scanning storage
+type Autocompleter interface {
+	// Autocomplete returns up to limit completions of prefix, best first
+	Autocomplete(ctx context.Context, prefix string, limit int) ([]Completion, error)
+}
+
+// IndexedBookStore wraps a BookStore with a full-text index of every book's
+// title, description, genre and author name, ranked with BM25, and a
+// completion index of titles, author names and ISBNs.
+// The index is built when the store is created and updated by every write
+// made through it. Writes that bypass it, such as another server sharing the
+// same MongoDB database, are not indexed until the next Rebuild.
+type IndexedBookStore struct {
+	BookStore
+	authors AuthorStore
+
+	mutex     sync.RWMutex
+	index     *search.Index
+	completer *search.Completer
+	names     map[string]string      // author ID -> name
+	books     map[string]indexedBook // book ID -> what was indexed
+}
+
+// indexedBook records the version of a book that was indexed, so a slow
+// write cannot replace a newer one, and its author and publisher
+type indexedBook struct {
+	version     int64
+	authorID    string
+	publisherID string
+}
+
+// NewIndexedBookStore indexes every book in books. authors supplies author
+// names and may be nil, in which case names are not searchable.
+func NewIndexedBookStore(ctx context.Context, books BookStore, authors AuthorStore) (*IndexedBookStore, error) {
+	s := &IndexedBookStore{BookStore: books, authors: authors}
+	if err := s.Rebuild(ctx); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Rebuild re-indexes every book from the underlying stores
+func (s *IndexedBookStore) Rebuild(ctx context.Context) error {
+	names := map[string]string{}
+	if s.authors != nil {
+		authors, err := s.authors.List(ctx)
+		if err != nil {
+			return err
+		}
+		for _, a := range authors {
+			names[a.ID] = a.Name
+		}
+	}
+
+	page, err := s.BookStore.List(ctx, BookQuery{})
+	if err != nil {
+		return err
+	}
+
+	index := search.NewIndex(searchWeights, "title", "author")
+	completions := make(map[string][]search.Entry, len(page.Books))
+	books := make(map[string]indexedBook, len(page.Books))
+	for _, book := range page.Books {
+		index.Put(book.ID.Hex(), searchFields(book, names[book.AuthorID]))
+		completions[book.ID.Hex()] = completionEntries(book, names[book.AuthorID])
+		books[book.ID.Hex()] = indexedOf(book)
+	}
+	completer := search.NewCompleter()
+	completer.PutMany(completions)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.index, s.completer, s.names, s.books = index, completer, names, books
+	return nil
+}
+
+// Authors wraps an AuthorStore so that renaming or deleting an author
+// re-indexes their books
+func (s *IndexedBookStore) Authors(authors AuthorStore) AuthorStore {
+	return &indexedAuthorStore{AuthorStore: authors, books: s}
+}
+
+// Publishers wraps a PublisherStore so that deleting a publisher re-indexes
+// or drops its books
+func (s *IndexedBookStore) Publishers(publishers PublisherStore) PublisherStore {
+	return &indexedPublisherStore{PublisherStore: publishers, books: s}
+}
+
+// SearchRanked returns the books matching any word of the query, most
+// relevant first, read fresh from the underlying store
+func (s *IndexedBookStore) SearchRanked(ctx context.Context, query string, limit int) ([]SearchHit, error) {
+	s.mutex.RLock()
+	hits := s.index.Search(query)
+	s.mutex.RUnlock()
+
+	return s.hydrate(ctx, hits, limit)
+}
+
+// SearchFuzzy returns the books matching the query with typos tolerated,
+// most relevant first, and corrections of the misspelled words
+func (s *IndexedBookStore) SearchFuzzy(ctx context.Context, query string, fuzziness, limit int) ([]SearchHit, []string, error) {
+	s.mutex.RLock()
+	hits := s.index.SearchFuzzy(query, fuzziness)
+	suggestions := s.index.Suggest(query, fuzziness, maxSuggestions)
+	s.mutex.RUnlock()
+
+	results, err := s.hydrate(ctx, hits, limit)
+	return results, suggestions, err
+}
+
+// Autocomplete returns up to limit titles, author names and ISBNs that
+// start with prefix or have a word that does. A prefix of digits and
+// hyphens is matched against ISBNs without the hyphens. The books behind
+// the completions are checked against the underlying store, and any that
+// were deleted without going through this store are dropped from the index
+// and the completions looked up again.
+func (s *IndexedBookStore) Autocomplete(ctx context.Context, prefix string, limit int) ([]Completion, error) {
+	if compact := strings.NewReplacer("-", "", " ", "").Replace(prefix); compact != "" && strings.Trim(compact, "0123456789Xx") == "" {
+		prefix = compact
+	}
+
+	for {
+		s.mutex.RLock()
+		entries, docs := s.completer.CompleteDocs(prefix, limit)
+		s.mutex.RUnlock()
+		if len(entries) == 0 {
+			return []Completion{}, nil
+		}
+
+		var ids []string
+		for _, d := range docs {
+			ids = append(ids, d...)
+		}
+		books, err := s.BookStore.GetMany(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+
+		live := make(map[string]bool, len(books))
+		for _, b := range books {
+			live[b.ID.Hex()] = true
+		}
+		var stale []string
+		for _, id := range ids {
+			if !live[id] {
+				stale = append(stale, id)
+			}
+		}
+		if len(stale) > 0 {
+			// Each pass drops at least one book, so this ends
+			s.removeAll(stale)
+			continue
+		}
+
+		completions := make([]Completion, len(entries))
+		for i, e := range entries {
+			completions[i] = Completion(e)
+		}
+		return completions, nil
+	}
+}
+
+// hydrate reads the books of the first limit hits from the underlying store
+func (s *IndexedBookStore) hydrate(ctx context.Context, hits []search.Hit, limit int) ([]SearchHit, error) {
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+
+	ids := make([]string, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ID
+	}
+	books, err := s.BookStore.GetMany(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]models.Book, len(books))
+	for _, b := range books {
+		byID[b.ID.Hex()] = b
+	}
+
+	results := []SearchHit{}
+	var stale []string
+	for _, hit := range hits {
+		book, ok := byID[hit.ID]
+		if !ok {
+			// Deleted without going through this store
+			stale = append(stale, hit.ID)
+			continue
+		}
+		results = append(results, SearchHit{Book: book, Score: hit.Score})
+	}
+	s.removeAll(stale)
+
+	return results, nil
+}
+
+// Create stores and indexes a new book
+func (s *IndexedBookStore) Create(ctx context.Context, book models.Book) (models.Book, error) {
+	book, err := s.BookStore.Create(ctx, book)
+	if err == nil {
+		s.put(ctx, book)
+	}
+
+	return book, err
+}
+
+// Update replaces and re-indexes the book with the given ID
+func (s *IndexedBookStore) Update(ctx context.Context, id string, book models.Book, version int64) (models.Book, error) {
+	book, err := s.BookStore.Update(ctx, id, book, version)
+	if err == nil {
+		s.put(ctx, book)
+	}
+
+	return book, err
+}
+
+// Patch changes and re-indexes the book with the given ID
+func (s *IndexedBookStore) Patch(ctx context.Context, id string, version int64, apply func(models.Book) (models.Book, error)) (models.Book, error) {
+	book, err := s.BookStore.Patch(ctx, id, version, apply)
+	if err == nil {
+		s.put(ctx, book)
+	}
+
+	return book, err
+}
+
+// Delete removes the book with the given ID and its index entry
+func (s *IndexedBookStore) Delete(ctx context.Context, id string, version int64) error {
+	err := s.BookStore.Delete(ctx, id, version)
+	if err == nil {
+		s.remove(id)
+	}
+
+	return err
+}
+
+// BulkUpsert writes the books and indexes every one that was written
+func (s *IndexedBookStore) BulkUpsert(ctx context.Context, books []models.Book, atomic bool) ([]BulkResult, error) {
+	results, err := s.BookStore.BulkUpsert(ctx, books, atomic)
+	var written []models.Book
+	for _, r := range results {
+		if r.Err == nil {
+			written = append(written, r.Book)
+		}
+	}
+	s.putAll(ctx, written)
+
+	return results, err
+}
+
+// DeleteMany removes the books and the index entries of every one deleted
+func (s *IndexedBookStore) DeleteMany(ctx context.Context, ids []string, versions []int64, atomic bool) ([]error, error) {
+	errs, err := s.BookStore.DeleteMany(ctx, ids, versions, atomic)
+	var deleted []string
+	for i, itemErr := range errs {
+		if itemErr == nil {
+			deleted = append(deleted, ids[i])
+		}
+	}
+	s.removeAll(deleted)
+
+	return errs, err
+}
+
+// DeleteWhere removes the books matching the filter and re-checks every
+// book that matched it beforehand
+func (s *IndexedBookStore) DeleteWhere(ctx context.Context, filter BookFilter) (int64, error) {
+	page, err := s.BookStore.List(ctx, BookQuery{Filter: filter})
+	if err != nil {
+		return 0, err
+	}
+
+	deleted, err := s.BookStore.DeleteWhere(ctx, filter)
+	if err != nil {
+		return deleted, err
+	}
+
+	ids := make([]string, len(page.Books))
+	for i, b := range page.Books {
+		ids[i] = b.ID.Hex()
+	}
+	return deleted, s.refresh(ctx, ids)
+}
+
+// put indexes a book under its author's current name
+func (s *IndexedBookStore) put(ctx context.Context, book models.Book) {
+	s.putAll(ctx, []models.Book{book})
+}
+
+// putAll indexes books under their authors' current names. The whole batch
+// is indexed under one lock and merged into the completer at once, so a
+// large bulk write costs about as much as a Rebuild.
+func (s *IndexedBookStore) putAll(ctx context.Context, books []models.Book) {
+	if len(books) == 0 {
+		return
+	}
+
+	names := map[string]string{}
+	for _, book := range books {
+		if _, ok := names[book.AuthorID]; !ok {
+			names[book.AuthorID] = s.authorName(ctx, book.AuthorID)
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	completions := make(map[string][]search.Entry, len(books))
+	for _, book := range books {
+		id := book.ID.Hex()
+		if indexed, ok := s.books[id]; ok && indexed.version > book.Version {
+			continue
+		}
+		s.index.Put(id, searchFields(book, names[book.AuthorID]))
+		completions[id] = completionEntries(book, names[book.AuthorID])
+		s.books[id] = indexedOf(book)
+	}
+	s.completer.PutMany(completions)
+}
+
+// remove drops a book from the index
+func (s *IndexedBookStore) remove(id string) {
+	s.removeAll([]string{id})
+}
+
+// removeAll drops books from the index under one lock
+func (s *IndexedBookStore) removeAll(ids []string) {
+	if len(ids) == 0 {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, id := range ids {
+		s.index.Remove(id)
+		delete(s.books, id)
+	}
+	s.completer.RemoveMany(ids)
+}
+
+// refresh re-reads the given books, re-indexing those that still exist and
+// dropping the rest
+func (s *IndexedBookStore) refresh(ctx context.Context, ids []string) error {
+	books, err := s.BookStore.GetMany(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	found := make(map[string]bool, len(books))
+	for _, b := range books {
+		found[b.ID.Hex()] = true
+	}
+	var missing []string
+	for _, id := range ids {
+		if !found[id] {
+			missing = append(missing, id)
+		}
+	}
+
+	s.putAll(ctx, books)
+	s.removeAll(missing)
+	return nil
+}
+
+// authorName returns the name of an author, loading it if it is not known yet
+func (s *IndexedBookStore) authorName(ctx context.Context, id string) string {
+	if id == "" {
+		return ""
+	}
+
+	s.mutex.RLock()
+	name, ok := s.names[id]
+	s.mutex.RUnlock()
+	if ok || s.authors == nil {
+		return name
+	}
+
+	author, err := s.authors.Get(ctx, id)
+	if err != nil {
+		return ""
+	}
+	s.setAuthorName(id, author.Name)
+	return author.Name
+}
+
+// setAuthorName records an author's name, or forgets it if name is empty
+func (s *IndexedBookStore) setAuthorName(id, name string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if name == "" {
+		delete(s.names, id)
+	} else {
+		s.names[id] = name
+	}
+}
+
+// booksBy returns the IDs of the indexed books by an author
+func (s *IndexedBookStore) booksBy(authorID string) []string {
+	return s.booksWhere(func(indexed indexedBook) bool { return indexed.authorID == authorID })
+}
+
+// booksFrom returns the IDs of the indexed books of a publisher
+func (s *IndexedBookStore) booksFrom(publisherID string) []string {
+	return s.booksWhere(func(indexed indexedBook) bool { return indexed.publisherID == publisherID })
+}
+
+// booksWhere returns the IDs of the indexed books that match
+func (s *IndexedBookStore) booksWhere(match func(indexedBook) bool) []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var ids []string
+	for id, indexed := range s.books {
+		if match(indexed) {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+// indexedOf returns what is recorded about an indexed book
+func indexedOf(book models.Book) indexedBook {
+	return indexedBook{version: book.Version, authorID: book.AuthorID, publisherID: book.PublisherID}
+}
+
+// searchFields returns the text of a book that is searched
+func searchFields(book models.Book, authorName string) map[string]string {
+	return map[string]string{
+		"title":       book.Title,
+		"description": book.Description,
+		"genre":       book.Genre,
+		"author":      authorName,
+	}
+}
+
+// completionEntries returns what a prefix can complete to for a book
+func completionEntries(book models.Book, authorName string) []search.Entry {
+	id := book.ID.Hex()
+	entries := []search.Entry{{Kind: CompletionTitle, Text: book.Title, ID: id}}
+	if authorName != "" {
+		entries = append(entries, search.Entry{Kind: CompletionAuthor, Text: authorName, ID: book.AuthorID})
+	}
+	if book.ISBN != "" {
+		entries = append(entries, search.Entry{Kind: CompletionISBN, Text: book.ISBN, ID: id})
+	}
+
+	return entries
+}
+
+// indexedAuthorStore keeps an IndexedBookStore's author names up to date
+type indexedAuthorStore struct {
+	AuthorStore
+	books *IndexedBookStore
+}
+
+// Create stores a new author and remembers their name
+func (s *indexedAuthorStore) Create(ctx context.Context, author models.Author) (models.Author, error) {
+	author, err := s.AuthorStore.Create(ctx, author)
+	if err == nil {
+		s.books.setAuthorName(author.ID, author.Name)
+	}
+
+	return author, err
+}
+
+// Update replaces the author and re-indexes their books under the new name
+func (s *indexedAuthorStore) Update(ctx context.Context, id string, author models.Author) (models.Author, error) {
+	author, err := s.AuthorStore.Update(ctx, id, author)
+	if err != nil {
+		return author, err
+	}
+
+	s.books.setAuthorName(id, author.Name)
+	return author, s.books.refresh(ctx, s.books.booksBy(id))
+}
+
+// Delete removes the author and re-indexes or drops their books, depending
+// on what the policy did to them
+func (s *indexedAuthorStore) Delete(ctx context.Context, id string, policy DeletePolicy) error {
+	if err := s.AuthorStore.Delete(ctx, id, policy); err != nil {
+		return err
+	}
+
+	s.books.setAuthorName(id, "")
+	return s.books.refresh(ctx, s.books.booksBy(id))
+}
+
+// indexedPublisherStore keeps an IndexedBookStore up to date with the
+// books a publisher delete removes or orphans
+type indexedPublisherStore struct {
+	PublisherStore
+	books *IndexedBookStore
+}
+
+// Delete removes the publisher and re-indexes or drops its books,
+// depending on what the policy did to them
+func (s *indexedPublisherStore) Delete(ctx context.Context, id string, policy DeletePolicy) error {
+	if err := s.PublisherStore.Delete(ctx, id, policy); err != nil {
+		return err
+	}
+
+	return s.books.refresh(ctx, s.books.booksFrom(id))
+}
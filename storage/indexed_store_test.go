@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/harshakumara/book-api/models"
+)
+
+func TestIndexedBookStoreAuthorNames(t *testing.T) {
+	ctx := context.Background()
+	fileBooks, fileAuthors := newTestFileStores(t)
+
+	// Books that exist before the index is built are indexed too
+	tolkien, _ := fileAuthors.Create(ctx, models.Author{Name: "J. R. R. Tolkien"})
+	fileBooks.Create(ctx, models.Book{Title: "The Hobbit", AuthorID: tolkien.ID})
+
+	books, err := NewIndexedBookStore(ctx, fileBooks, fileAuthors)
+	if err != nil {
+		t.Fatalf("NewIndexedBookStore failed: %v", err)
+	}
+	authors := books.Authors(fileAuthors)
+
+	search := func(query string) []string {
+		t.Helper()
+		hits, err := books.SearchRanked(ctx, query, 0)
+		if err != nil {
+			t.Fatalf("SearchRanked failed: %v", err)
+		}
+		titles := make([]string, len(hits))
+		for i, h := range hits {
+			titles[i] = h.Title
+		}
+		return titles
+	}
+
+	if got := search("tolkien"); len(got) != 1 || got[0] != "The Hobbit" {
+		t.Fatalf("Expected to find the book by its author, got %v", got)
+	}
+
+	// Books by a new author are found by the author's name
+	lewis, _ := authors.Create(ctx, models.Author{Name: "C. S. Lewis"})
+	if _, err := books.Create(ctx, models.Book{Title: "Prince Caspian", AuthorID: lewis.ID}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if got := search("lewis"); len(got) != 1 || got[0] != "Prince Caspian" {
+		t.Errorf("Expected to find the new author's book, got %v", got)
+	}
+
+	// Renaming an author re-indexes their books
+	lewis.Name = "Clive Staples"
+	if _, err := authors.Update(ctx, lewis.ID, lewis); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if got := search("lewis"); len(got) != 0 {
+		t.Errorf("Expected the old name to be gone, got %v", got)
+	}
+	if got := search("staples"); len(got) != 1 {
+		t.Errorf("Expected the new name to match, got %v", got)
+	}
+
+	// Deleting an author with their books drops the books from the index
+	if err := authors.Delete(ctx, tolkien.ID, DeleteCascade); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if got := search("hobbit"); len(got) != 0 || books.index.Len() != 1 {
+		t.Errorf("Expected the cascaded book to leave the index, got %v", got)
+	}
+}
+
+func TestIndexedBookStoreBulkWrites(t *testing.T) {
+	ctx := context.Background()
+	fileBooks, _ := newTestFileStores(t)
+	books, err := NewIndexedBookStore(ctx, fileBooks, nil)
+	if err != nil {
+		t.Fatalf("NewIndexedBookStore failed: %v", err)
+	}
+
+	batch := make([]models.Book, 500)
+	for i := range batch {
+		batch[i] = models.Book{Title: fmt.Sprintf("Catalogue Volume %d", i)}
+	}
+	results, err := books.BulkUpsert(ctx, batch, true)
+	if err != nil {
+		t.Fatalf("BulkUpsert failed: %v", err)
+	}
+	if n := books.index.Len(); n != len(batch) {
+		t.Fatalf("Expected every book to be indexed, got %d", n)
+	}
+	if got, _ := books.Autocomplete(ctx, "catalogue volume 49", 20); len(got) != 11 {
+		t.Errorf("Expected volumes 49 and 490-499, got %v", got)
+	}
+
+	ids := make([]string, 250)
+	for i := range ids {
+		ids[i] = results[i].Book.ID.Hex()
+	}
+	if _, err := books.DeleteMany(ctx, ids, nil, true); err != nil {
+		t.Fatalf("DeleteMany failed: %v", err)
+	}
+	if n := books.index.Len(); n != len(batch)-len(ids) {
+		t.Errorf("Expected the deleted books to leave the index, got %d", n)
+	}
+	if got, _ := books.Autocomplete(ctx, "catalogue volume 49", 20); len(got) != 10 {
+		t.Errorf("Expected volumes 490-499, got %v", got)
+	}
+}
+
+func TestIndexedBookStoreAutocompleteDropsDeleted(t *testing.T) {
+	ctx := context.Background()
+	fileBooks, _ := newTestFileStores(t)
+	books, err := NewIndexedBookStore(ctx, fileBooks, nil)
+	if err != nil {
+		t.Fatalf("NewIndexedBookStore failed: %v", err)
+	}
+
+	gone, _ := books.Create(ctx, models.Book{Title: "Gatsby Revisited"})
+	books.Create(ctx, models.Book{Title: "The Great Gatsby"})
+
+	// Deleted without going through the indexed store
+	if err := fileBooks.Delete(ctx, gone.ID.Hex(), AnyVersion); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	got, err := books.Autocomplete(ctx, "gats", 1)
+	if err != nil {
+		t.Fatalf("Autocomplete failed: %v", err)
+	}
+	if len(got) != 1 || got[0].Text != "The Great Gatsby" {
+		t.Errorf("Expected only the remaining book, got %v", got)
+	}
+	if n := books.index.Len(); n != 1 {
+		t.Errorf("Expected the deleted book to leave the index, got %d", n)
+	}
+}
+
+func TestIndexedBookStorePublisherDelete(t *testing.T) {
+	ctx := context.Background()
+	fileBooks, filePublishers := newTestPublisherStores(t)
+	books, err := NewIndexedBookStore(ctx, fileBooks, nil)
+	if err != nil {
+		t.Fatalf("NewIndexedBookStore failed: %v", err)
+	}
+	publishers := books.Publishers(filePublishers)
+
+	press, _ := publishers.Create(ctx, models.Publisher{Name: "Allen & Unwin"})
+	if _, err := books.Create(ctx, models.Book{Title: "The Hobbit", PublisherID: press.ID}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	books.Create(ctx, models.Book{Title: "The Hobbit Companion"})
+
+	// Deleting a publisher with its books drops the books from the index
+	if err := publishers.Delete(ctx, press.ID, DeleteCascade); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if n := books.index.Len(); n != 1 {
+		t.Errorf("Expected the cascaded book to leave the index, got %d", n)
+	}
+	hits, err := books.SearchRanked(ctx, "hobbit", 0)
+	if err != nil {
+		t.Fatalf("SearchRanked failed: %v", err)
+	}
+	if len(hits) != 1 || hits[0].Title != "The Hobbit Companion" {
+		t.Errorf("Expected only the remaining book, got %v", hits)
+	}
+	books.mutex.RLock()
+	got := books.completer.Complete("hobbit", 10)
+	books.mutex.RUnlock()
+	if len(got) != 1 || got[0].Text != "The Hobbit Companion" {
+		t.Errorf("Expected the cascaded book to leave the completer, got %v", got)
+	}
+}
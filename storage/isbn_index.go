@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"github.com/harshakumara/book-api/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// isbnIndex maps each ISBN to the ID of the book holding it.
+// Entries are checked against the books on every lookup and an entry whose
+// book is gone or has another ISBN counts as free, so deleting a book or
+// changing its ISBN needs no cleanup.
+type isbnIndex map[string]primitive.ObjectID
+
+// buildISBNIndex indexes every book with an ISBN
+func buildISBNIndex(books []models.Book) isbnIndex {
+	x := make(isbnIndex, len(books))
+	for _, b := range books {
+		if b.ISBN != "" {
+			x[b.ISBN] = b.ID
+		}
+	}
+
+	return x
+}
+
+// clone returns a copy of the index that can be changed independently
+func (x isbnIndex) clone() isbnIndex {
+	c := make(isbnIndex, len(x))
+	for isbn, id := range x {
+		c[isbn] = id
+	}
+
+	return c
+}
+
+// owner returns the index in books of the book holding isbn, or -1
+func (x isbnIndex) owner(books []models.Book, isbn string) int {
+	id, ok := x[isbn]
+	if !ok {
+		return -1
+	}
+
+	i := findBook(books, id.Hex())
+	if i < 0 || books[i].ISBN != isbn {
+		return -1
+	}
+
+	return i
+}
+
+// claim records book as the holder of its ISBN, or returns
+// ErrDuplicateISBN if another book in books already holds it
+func (x isbnIndex) claim(books []models.Book, book models.Book) error {
+	if book.ISBN == "" {
+		return nil
+	}
+	if i := x.owner(books, book.ISBN); i >= 0 && books[i].ID != book.ID {
+		return ErrDuplicateISBN
+	}
+
+	x[book.ISBN] = book.ID
+	return nil
+}
+
+// isbnTaken reports whether a book other than book holds its ISBN, by
+// scanning books
+func isbnTaken(books []models.Book, book models.Book) bool {
+	if book.ISBN == "" {
+		return false
+	}
+	for _, b := range books {
+		if b.ISBN == book.ISBN && b.ID != book.ID {
+			return true
+		}
+	}
+
+	return false
+}
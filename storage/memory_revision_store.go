@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/harshakumara/book-api/models"
+)
+
+// MemoryRevisionStore is a RevisionStore that keeps revisions in memory only
+type MemoryRevisionStore struct {
+	mutex     sync.RWMutex
+	revisions []models.Revision
+}
+
+// NewMemoryRevisionStore creates an empty in-memory RevisionStore
+func NewMemoryRevisionStore() *MemoryRevisionStore {
+	return &MemoryRevisionStore{}
+}
+
+// Record appends revisions to the log
+func (s *MemoryRevisionStore) Record(ctx context.Context, revisions []models.Revision) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.revisions = append(s.revisions, revisions...)
+	return nil
+}
+
+// Get returns a single revision by ID
+func (s *MemoryRevisionStore) Get(ctx context.Context, id string) (models.Revision, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return findRevision(s.revisions, id)
+}
+
+// History returns every revision of a book, oldest first
+func (s *MemoryRevisionStore) History(ctx context.Context, bookID string) ([]models.Revision, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return bookHistory(s.revisions, bookID), nil
+}
+
+// Since returns up to limit revisions recorded at or after since, oldest first
+func (s *MemoryRevisionStore) Since(ctx context.Context, since time.Time, limit int) ([]models.Revision, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return revisionsSince(s.revisions, since, limit), nil
+}
@@ -0,0 +1,243 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/harshakumara/book-api/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryBookStore is a BookStore that keeps books in memory only.
+// It knows no authors or publishers, so every reference is accepted.
+// It is meant for tests and as the simplest implementation of the
+// BookStore contract.
+type MemoryBookStore struct {
+	mutex sync.RWMutex
+	books []models.Book
+}
+
+// NewMemoryBookStore creates an empty in-memory BookStore
+func NewMemoryBookStore() *MemoryBookStore {
+	return &MemoryBookStore{}
+}
+
+// List returns one page of books matching the query
+func (s *MemoryBookStore) List(ctx context.Context, q BookQuery) (BookPage, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return applyQuery(s.books, q)
+}
+
+// Facets counts the named facets over the books matching the filter
+func (s *MemoryBookStore) Facets(ctx context.Context, filter BookFilter, names []string) (Facets, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return countMatchingFacets(s.books, filter, names)
+}
+
+// Get returns a single book by ID
+func (s *MemoryBookStore) Get(ctx context.Context, id string) (models.Book, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	i := findBook(s.books, id)
+	if i < 0 {
+		return models.Book{}, ErrNotFound
+	}
+
+	return s.books[i], nil
+}
+
+// GetMany returns the books with the given IDs
+func (s *MemoryBookStore) GetMany(ctx context.Context, ids []string) ([]models.Book, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return booksWithIDs(s.books, ids), nil
+}
+
+// Create stores a new book
+func (s *MemoryBookStore) Create(ctx context.Context, book models.Book) (models.Book, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if book.ID.IsZero() {
+		book.ID = primitive.NewObjectID()
+	}
+	if book.Version <= 0 {
+		book.Version = 1
+	}
+	if isbnTaken(s.books, book) {
+		return models.Book{}, ErrDuplicateISBN
+	}
+
+	s.books = append(s.books, book)
+	return book, nil
+}
+
+// Update replaces the book with the given ID
+func (s *MemoryBookStore) Update(ctx context.Context, id string, book models.Book, version int64) (models.Book, error) {
+	return s.Patch(ctx, id, version, func(models.Book) (models.Book, error) {
+		return book, nil
+	})
+}
+
+// Patch applies a change to the book with the given ID
+func (s *MemoryBookStore) Patch(ctx context.Context, id string, version int64, apply func(models.Book) (models.Book, error)) (models.Book, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	i, err := findBookVersion(s.books, id, version)
+	if err != nil {
+		return models.Book{}, err
+	}
+
+	current := s.books[i]
+	book, err := apply(current)
+	if err != nil {
+		return models.Book{}, err
+	}
+	book.ID = current.ID
+	book.Version = current.Version + 1
+	if isbnTaken(s.books, book) {
+		return models.Book{}, ErrDuplicateISBN
+	}
+
+	s.books[i] = book
+	return book, nil
+}
+
+// Delete removes the book with the given ID
+func (s *MemoryBookStore) Delete(ctx context.Context, id string, version int64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	i, err := findBookVersion(s.books, id, version)
+	if err != nil {
+		return err
+	}
+
+	s.books = append(s.books[:i:i], s.books[i+1:]...)
+	return nil
+}
+
+// Search returns books whose title or description contains the keyword
+func (s *MemoryBookStore) Search(ctx context.Context, keyword string) ([]models.Book, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	keyword = strings.ToLower(keyword)
+	results := []models.Book{}
+	for _, b := range s.books {
+		if strings.Contains(strings.ToLower(b.Title), keyword) || strings.Contains(strings.ToLower(b.Description), keyword) {
+			results = append(results, b)
+		}
+	}
+
+	return results, nil
+}
+
+// SearchPattern returns books whose title or description matches the pattern
+func (s *MemoryBookStore) SearchPattern(ctx context.Context, p *Pattern) ([]models.Book, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return searchPattern(ctx, s.books, p)
+}
+
+// SearchStructured returns the books matching a structured query
+func (s *MemoryBookStore) SearchStructured(ctx context.Context, q *StructuredQuery) ([]models.Book, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return searchStructured(ctx, s.books, q)
+}
+
+// FindByISBN returns the books whose ISBN is one of isbns
+func (s *MemoryBookStore) FindByISBN(ctx context.Context, isbns []string) ([]models.Book, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return booksWithISBN(s.books, isbns), nil
+}
+
+// BulkUpsert creates or replaces every book.
+// With no references to check, only a duplicate ISBN fails an item.
+func (s *MemoryBookStore) BulkUpsert(ctx context.Context, books []models.Book, atomic bool) ([]BulkResult, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	next := append([]models.Book{}, s.books...)
+	results := make([]BulkResult, len(books))
+	seen := map[primitive.ObjectID]bool{}
+	for i, book := range books {
+		if book.ID.IsZero() {
+			book.ID = primitive.NewObjectID()
+		}
+		if seen[book.ID] {
+			results[i] = BulkResult{Book: book, Err: repeatedBookError()}
+			continue
+		}
+		seen[book.ID] = true
+		if err := checkBulkVersion(next, book); err != nil {
+			results[i] = BulkResult{Book: book, Err: err}
+			continue
+		}
+		if isbnTaken(next, book) {
+			results[i] = BulkResult{Book: book, Err: ErrDuplicateISBN}
+			continue
+		}
+		results[i] = upsertBook(&next, book)
+	}
+
+	if atomic && anyFailed(results) {
+		notApplied(results)
+		return results, nil
+	}
+
+	s.books = next
+	return results, nil
+}
+
+// DeleteMany removes the books with the given IDs
+func (s *MemoryBookStore) DeleteMany(ctx context.Context, ids []string, versions []int64, atomic bool) ([]error, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	next := append([]models.Book{}, s.books...)
+	errs := make([]error, len(ids))
+	for i, id := range ids {
+		errs[i] = deleteBook(&next, id, bulkVersion(versions, i))
+	}
+
+	if atomic && anyErr(errs) {
+		notAppliedErrs(errs)
+		return errs, nil
+	}
+
+	s.books = next
+	return errs, nil
+}
+
+// DeleteWhere removes every book matching the filter
+func (s *MemoryBookStore) DeleteWhere(ctx context.Context, filter BookFilter) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var deleted int64
+	kept := []models.Book{}
+	for _, b := range s.books {
+		if filter.Matches(b) {
+			deleted++
+		} else {
+			kept = append(kept, b)
+		}
+	}
+
+	s.books = kept
+	return deleted, nil
+}
@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/harshakumara/book-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoAuthorStore is an AuthorStore backed by a MongoDB collection
+type MongoAuthorStore struct {
+	collection *mongo.Collection
+	books      *mongo.Collection
+	changes    mongoBookChanges
+	timeout    time.Duration
+}
+
+// NewMongoAuthorStore creates an AuthorStore on top of the given database
+func NewMongoAuthorStore(db *mongo.Database, opts MongoOptions) *MongoAuthorStore {
+	opts = opts.withDefaults()
+
+	return &MongoAuthorStore{
+		collection: db.Collection(opts.AuthorsCollection),
+		books:      db.Collection(opts.BooksCollection),
+		changes:    newMongoBookChanges(db, opts),
+		timeout:    opts.Timeout,
+	}
+}
+
+// List returns all authors ordered by name
+func (s *MongoAuthorStore) List(ctx context.Context) ([]models.Author, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := s.collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	authors := []models.Author{}
+	if err := cursor.All(ctx, &authors); err != nil {
+		return nil, err
+	}
+
+	return authors, nil
+}
+
+// Get returns a single author by ID
+func (s *MongoAuthorStore) Get(ctx context.Context, id string) (models.Author, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var author models.Author
+	err := s.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&author)
+	if err == mongo.ErrNoDocuments {
+		return models.Author{}, ErrNotFound
+	}
+	if err != nil {
+		return models.Author{}, err
+	}
+
+	return author, nil
+}
+
+// Create stores a new author
+func (s *MongoAuthorStore) Create(ctx context.Context, author models.Author) (models.Author, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if author.ID == "" {
+		author.ID = models.NewUUID()
+	}
+
+	if _, err := s.collection.InsertOne(ctx, author); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return models.Author{}, ErrConflict
+		}
+		return models.Author{}, err
+	}
+
+	return author, nil
+}
+
+// Update replaces the author with the given ID
+func (s *MongoAuthorStore) Update(ctx context.Context, id string, author models.Author) (models.Author, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	// Preserve the original ID
+	author.ID = id
+
+	result, err := s.collection.ReplaceOne(ctx, bson.M{"_id": id}, author)
+	if err != nil {
+		return models.Author{}, err
+	}
+	if result.MatchedCount == 0 {
+		return models.Author{}, ErrNotFound
+	}
+
+	return author, nil
+}
+
+// Delete removes the author, handling their books according to policy.
+// Without multi-document transactions the book changes are applied first, so
+// an interrupted delete leaves the author in place rather than dangling books.
+// Each book changed or deleted is recorded as a revision.
+func (s *MongoAuthorStore) Delete(ctx context.Context, id string, policy DeletePolicy) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	ok, err := exists(ctx, s.collection, id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotFound
+	}
+
+	byAuthor := bson.M{"authorId": id}
+	switch policy {
+	case DeleteCascade:
+		if err := s.changes.deleteWhere(ctx, byAuthor); err != nil {
+			return err
+		}
+	case DeleteOrphan:
+		orphan := func(b *models.Book) { b.AuthorID = "" }
+		if err := s.changes.setWhere(ctx, byAuthor, "authorId", "", orphan); err != nil {
+			return err
+		}
+	default:
+		ok, err := existsWhere(ctx, s.books, byAuthor)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return ErrHasBooks
+		}
+	}
+
+	result, err := s.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/harshakumara/book-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoBookChanges makes the book changes of the MongoDB inventory, order,
+// author and publisher stores, which write to the books collection directly
+// rather than through an AuditedBookStore, and records each in the audit
+// log. Every change is a find-and-modify of a single book, so its revision
+// describes exactly what it did. As with AuditedBookStore, the revision is
+// recorded after the change and a failure to record it is logged.
+type mongoBookChanges struct {
+	books     *mongo.Collection
+	revisions *MongoRevisionStore
+}
+
+// newMongoBookChanges changes the books collection of opts
+func newMongoBookChanges(db *mongo.Database, opts MongoOptions) mongoBookChanges {
+	opts = opts.withDefaults()
+
+	return mongoBookChanges{
+		books:     db.Collection(opts.BooksCollection),
+		revisions: NewMongoRevisionStore(db, opts),
+	}
+}
+
+// incStock adds delta to the quantity of the book matching filter and
+// returns the book as changed, or mongo.ErrNoDocuments if none matches
+func (c mongoBookChanges) incStock(ctx context.Context, filter bson.M, delta int) (models.Book, error) {
+	var book models.Book
+	err := c.books.FindOneAndUpdate(ctx, filter,
+		bson.M{"$inc": bson.M{"quantity": delta, "version": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&book)
+	if err != nil {
+		return models.Book{}, err
+	}
+
+	before := book
+	before.Quantity -= delta
+	before.Version--
+	recordCommitted(ctx, c.revisions, newRevision(ctx, &before, &book))
+
+	return book, nil
+}
+
+// deleteWhere deletes the books matching filter one at a time until none
+// is left, recording each as it was when deleted
+func (c mongoBookChanges) deleteWhere(ctx context.Context, filter bson.M) error {
+	return c.eachWhere(ctx, filter, func(f bson.M) error {
+		var book models.Book
+		if err := c.books.FindOneAndDelete(ctx, f).Decode(&book); err != nil {
+			return err
+		}
+
+		recordCommitted(ctx, c.revisions, newRevision(ctx, &book, nil))
+		return nil
+	})
+}
+
+// setWhere sets field to value on the books matching filter one at a time
+// until none is left, recording each change. apply makes the same change
+// to a decoded book.
+func (c mongoBookChanges) setWhere(ctx context.Context, filter bson.M, field string, value interface{}, apply func(*models.Book)) error {
+	return c.eachWhere(ctx, filter, func(f bson.M) error {
+		var before models.Book
+		err := c.books.FindOneAndUpdate(ctx, f,
+			bson.M{"$set": bson.M{field: value}, "$inc": bson.M{"version": 1}},
+			options.FindOneAndUpdate().SetReturnDocument(options.Before),
+		).Decode(&before)
+		if err != nil {
+			return err
+		}
+
+		after := before
+		apply(&after)
+		after.Version++
+		recordCommitted(ctx, c.revisions, newRevision(ctx, &before, &after))
+		return nil
+	})
+}
+
+// eachWhere calls change with a filter for each book matching filter,
+// repeating until no book matches. change must make the book stop matching;
+// a book that stopped matching before its turn is skipped.
+func (c mongoBookChanges) eachWhere(ctx context.Context, filter bson.M, change func(filter bson.M) error) error {
+	for {
+		cursor, err := c.books.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+		if err != nil {
+			return err
+		}
+
+		var docs []struct {
+			ID interface{} `bson:"_id"`
+		}
+		if err := cursor.All(ctx, &docs); err != nil {
+			return err
+		}
+		if len(docs) == 0 {
+			return nil
+		}
+
+		for _, doc := range docs {
+			err := change(bson.M{"$and": []bson.M{{"_id": doc.ID}, filter}})
+			if err != nil && err != mongo.ErrNoDocuments {
+				return err
+			}
+		}
+	}
+}
+
+// detached returns a context carrying the ChangeInfo of ctx but not its
+// deadline, for writes that undo part of a failed operation and must run
+// even though ctx is done
+func detached(ctx context.Context) context.Context {
+	return WithChangeInfo(context.Background(), ChangeInfoFromContext(ctx))
+}
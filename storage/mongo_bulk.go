@@ -0,0 +1,425 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/harshakumara/book-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// transactionsUnsupported is the server error for a transaction on a
+// standalone server
+const transactionsUnsupported = 20
+
+// BulkUpsert creates or replaces books with a single InsertMany or BulkWrite.
+// Every book's references and ISBN are checked before anything is written.
+// In atomic mode the checks and the write run in one transaction, so a
+// failure anywhere writes nothing; that needs a replica set or sharded
+// cluster, and fails with ErrAtomicUnsupported on a standalone server.
+func (s *MongoBookStore) BulkUpsert(ctx context.Context, books []models.Book, atomic bool) ([]BulkResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if !atomic {
+		return s.bulkUpsert(ctx, books, false)
+	}
+
+	var results []BulkResult
+	err := s.inTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		if results, err = s.bulkUpsert(ctx, books, true); err == nil && anyFailed(results) {
+			return errBulkAborted
+		}
+		return err
+	})
+	if errors.Is(err, errBulkAborted) {
+		notApplied(results)
+		return results, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// bulkUpsert does the work of BulkUpsert. In atomic mode it stops at the
+// first failure; the caller rolls back whatever was written.
+func (s *MongoBookStore) bulkUpsert(ctx context.Context, books []models.Book, atomic bool) ([]BulkResult, error) {
+	results := make([]BulkResult, len(books))
+	ids := make([]primitive.ObjectID, len(books))
+	seen := map[primitive.ObjectID]bool{}
+	for i, book := range books {
+		if book.ID.IsZero() {
+			book.ID = primitive.NewObjectID()
+		}
+		ids[i] = book.ID
+		results[i] = BulkResult{Book: book}
+		if seen[book.ID] {
+			results[i].Err = repeatedBookError()
+		}
+		seen[book.ID] = true
+	}
+
+	if err := s.checkBulkReferences(ctx, results); err != nil {
+		return nil, err
+	}
+	if err := s.checkBulkISBNs(ctx, results); err != nil {
+		return nil, err
+	}
+	if atomic && anyFailed(results) {
+		notApplied(results)
+		return results, nil
+	}
+
+	existing, err := s.existingIDs(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		book := results[i].Book
+		switch {
+		case results[i].Err != nil || book.Version == 0:
+		case book.Version == CreateOnly:
+			if existing[book.ID] != nil {
+				results[i].Err = ErrConflict
+			}
+		case existing[book.ID] == nil:
+			results[i].Err = ErrNotFound
+		case *existing[book.ID] != book.Version:
+			results[i].Err = ErrVersionMismatch
+		}
+	}
+	if atomic && anyFailed(results) {
+		notApplied(results)
+		return results, nil
+	}
+
+	// Indexes into results of the books being written, in write order
+	var pending []int
+	for i := range results {
+		if results[i].Err == nil {
+			pending = append(pending, i)
+		}
+	}
+
+	var writeErr error
+	if len(existing) == 0 {
+		docs := make([]interface{}, len(pending))
+		for j, i := range pending {
+			results[i].Book.Version = 1
+			results[i].Created = true
+			docs[j] = results[i].Book
+		}
+		if len(docs) > 0 {
+			_, writeErr = s.collection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(atomic))
+		}
+	} else {
+		writes := make([]mongo.WriteModel, len(pending))
+		for j, i := range pending {
+			book := &results[i].Book
+			if book.Version == CreateOnly {
+				// A book created meanwhile collides with its ID
+				book.Version = 1
+				results[i].Created = true
+				writes[j] = mongo.NewInsertOneModel().SetDocument(*book)
+				continue
+			}
+			set, err := bookFields(*book)
+			if err != nil {
+				return nil, err
+			}
+			// A book replaced at a version must still be at it; if it has
+			// changed the upsert collides with its ID
+			filter := bson.M{"_id": book.ID}
+			if book.Version != 0 {
+				filter["version"] = book.Version
+			}
+			writes[j] = mongo.NewUpdateOneModel().
+				SetFilter(filter).
+				SetUpdate(bson.M{"$set": set, "$inc": bson.M{"version": 1}}).
+				SetUpsert(true)
+
+			if existing[book.ID] != nil {
+				book.Version = *existing[book.ID] + 1
+			} else {
+				book.Version = 1
+				results[i].Created = true
+			}
+		}
+		_, writeErr = s.collection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(atomic))
+	}
+
+	if writeErr != nil {
+		var bulkErr mongo.BulkWriteException
+		if !errors.As(writeErr, &bulkErr) {
+			return nil, writeErr
+		}
+
+		failed := map[int]bool{}
+		for _, we := range bulkErr.WriteErrors {
+			i := pending[we.Index]
+			failed[i] = true
+			results[i].Created = false
+			results[i].Err = bookWriteError(we)
+			if results[i].Err == ErrConflict && books[i].Version > 0 {
+				results[i].Err = ErrVersionMismatch
+			}
+		}
+		if atomic {
+			// An ordered write stops at the first error; the transaction
+			// is then rolled back
+			first := bulkErr.WriteErrors[0].Index
+			for _, i := range pending[first+1:] {
+				results[i] = BulkResult{Book: results[i].Book, Err: ErrNotApplied}
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// DeleteMany removes the books with the given IDs with a single DeleteMany.
+// A book that changed after its version was checked is not deleted and is
+// reported as ErrVersionMismatch. Atomic mode runs in a transaction, as for
+// BulkUpsert.
+func (s *MongoBookStore) DeleteMany(ctx context.Context, ids []string, versions []int64, atomic bool) ([]error, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if !atomic {
+		return s.deleteMany(ctx, ids, versions, false)
+	}
+
+	var errs []error
+	err := s.inTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		if errs, err = s.deleteMany(ctx, ids, versions, true); err == nil && anyErr(errs) {
+			return errBulkAborted
+		}
+		return err
+	})
+	if errors.Is(err, errBulkAborted) {
+		notAppliedErrs(errs)
+		return errs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return errs, nil
+}
+
+// deleteMany does the work of DeleteMany
+func (s *MongoBookStore) deleteMany(ctx context.Context, ids []string, versions []int64, atomic bool) ([]error, error) {
+	errs := make([]error, len(ids))
+	objIDs := make([]primitive.ObjectID, len(ids))
+	for i, id := range ids {
+		objID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			errs[i] = ErrInvalidID
+			continue
+		}
+		objIDs[i] = objID
+	}
+
+	existing, err := s.existingIDs(ctx, bson.M{"_id": bson.M{"$in": objIDs}})
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[primitive.ObjectID]bool{}
+	var found []primitive.ObjectID
+	var matches []bson.M
+	for i, objID := range objIDs {
+		version := bulkVersion(versions, i)
+		switch {
+		case errs[i] != nil:
+		case existing[objID] == nil || seen[objID]:
+			errs[i] = ErrNotFound
+		case version != AnyVersion && *existing[objID] != version:
+			errs[i] = ErrVersionMismatch
+		default:
+			seen[objID] = true
+			found = append(found, objID)
+			match := bson.M{"_id": objID}
+			if version != AnyVersion {
+				match["version"] = version
+			}
+			matches = append(matches, match)
+		}
+	}
+
+	if atomic && anyErr(errs) {
+		notAppliedErrs(errs)
+		return errs, nil
+	}
+	if len(found) == 0 {
+		return errs, nil
+	}
+
+	res, err := s.collection.DeleteMany(ctx, bson.M{"$or": matches})
+	if err != nil {
+		return nil, err
+	}
+	if res.DeletedCount < int64(len(found)) {
+		// Whatever is left changed since it was checked
+		left, err := s.existingIDs(ctx, bson.M{"_id": bson.M{"$in": found}})
+		if err != nil {
+			return nil, err
+		}
+		for i, objID := range objIDs {
+			if errs[i] == nil && left[objID] != nil {
+				errs[i] = ErrVersionMismatch
+			}
+		}
+	}
+
+	return errs, nil
+}
+
+// DeleteWhere removes every book matching the filter
+func (s *MongoBookStore) DeleteWhere(ctx context.Context, filter BookFilter) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	res, err := s.collection.DeleteMany(ctx, mongoFilter(filter))
+	if err != nil {
+		return 0, err
+	}
+
+	return res.DeletedCount, nil
+}
+
+// inTransaction runs fn in a transaction, retrying it on transient errors.
+// fn must do all its work through the context it is given.
+func (s *MongoBookStore) inTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	session, err := s.collection.Database().Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(context.Background())
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) && serverErr.HasErrorCode(transactionsUnsupported) {
+		return ErrAtomicUnsupported
+	}
+	return err
+}
+
+// existingIDs returns the version of every book matching filter, keyed by ID
+func (s *MongoBookStore) existingIDs(ctx context.Context, filter interface{}) (map[primitive.ObjectID]*int64, error) {
+	cursor, err := s.collection.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1, "version": 1}))
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []struct {
+		ID      primitive.ObjectID `bson:"_id"`
+		Version int64              `bson:"version"`
+	}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	existing := make(map[primitive.ObjectID]*int64, len(docs))
+	for i := range docs {
+		existing[docs[i].ID] = &docs[i].Version
+	}
+
+	return existing, nil
+}
+
+// checkBulkReferences checks the author and publisher of every book with
+// one query per collection, setting a ReferenceError on each bad result
+func (s *MongoBookStore) checkBulkReferences(ctx context.Context, results []BulkResult) error {
+	refs := []struct {
+		collection *mongo.Collection
+		field      string
+		resource   string
+		id         func(models.Book) string
+	}{
+		{s.authors, "authorId", "author", func(b models.Book) string { return b.AuthorID }},
+		{s.publishers, "publisherId", "publisher", func(b models.Book) string { return b.PublisherID }},
+	}
+
+	for _, ref := range refs {
+		var ids []string
+		for _, r := range results {
+			if id := ref.id(r.Book); id != "" {
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) == 0 {
+			continue
+		}
+
+		cursor, err := ref.collection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}}, options.Find().SetProjection(bson.M{"_id": 1}))
+		if err != nil {
+			return err
+		}
+		var docs []struct {
+			ID string `bson:"_id"`
+		}
+		if err := cursor.All(ctx, &docs); err != nil {
+			return err
+		}
+
+		known := map[string]bool{}
+		for _, d := range docs {
+			known[d.ID] = true
+		}
+		for i := range results {
+			if id := ref.id(results[i].Book); id != "" && !known[id] && results[i].Err == nil {
+				results[i].Err = &ReferenceError{Field: ref.field, Resource: ref.resource, ID: id}
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkBulkISBNs fails each book whose ISBN is held by a stored book or by
+// an earlier book of the batch, with one query for the stored ISBNs
+func (s *MongoBookStore) checkBulkISBNs(ctx context.Context, results []BulkResult) error {
+	var isbns []string
+	for _, r := range results {
+		if r.Book.ISBN != "" {
+			isbns = append(isbns, r.Book.ISBN)
+		}
+	}
+	if len(isbns) == 0 {
+		return nil
+	}
+
+	stored, err := s.find(ctx, bson.M{"isbn": bson.M{"$in": isbns}}, options.Find().SetProjection(bson.M{"_id": 1, "isbn": 1}))
+	if err != nil {
+		return err
+	}
+
+	holders := map[string]primitive.ObjectID{}
+	for _, b := range stored {
+		holders[b.ISBN] = b.ID
+	}
+	for i := range results {
+		book := results[i].Book
+		if book.ISBN == "" || results[i].Err != nil {
+			continue
+		}
+		if id, ok := holders[book.ISBN]; ok && id != book.ID {
+			results[i].Err = ErrDuplicateISBN
+			continue
+		}
+		holders[book.ISBN] = book.ID
+	}
+
+	return nil
+}
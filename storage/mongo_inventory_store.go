@@ -0,0 +1,300 @@
+package storage
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/harshakumara/book-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoInventoryStore is an InventoryStore backed by MongoDB.
+// Stock is only ever changed with $inc guarded by a quantity filter, so a
+// concurrent decrement can never take a book's quantity below zero. Every
+// stock change is recorded as a revision of the book.
+type MongoInventoryStore struct {
+	books        *mongo.Collection
+	changes      mongoBookChanges
+	reservations *mongo.Collection
+	adjustments  *mongo.Collection
+	timeout      time.Duration
+}
+
+// NewMongoInventoryStore creates an InventoryStore on top of the given database
+func NewMongoInventoryStore(db *mongo.Database, opts MongoOptions) *MongoInventoryStore {
+	opts = opts.withDefaults()
+
+	return &MongoInventoryStore{
+		books:        db.Collection(opts.BooksCollection),
+		changes:      newMongoBookChanges(db, opts),
+		reservations: db.Collection(opts.ReservationsCollection),
+		adjustments:  db.Collection(opts.AdjustmentsCollection),
+		timeout:      opts.Timeout,
+	}
+}
+
+// AdjustStock changes a book's quantity by delta and records the reason
+func (s *MongoInventoryStore) AdjustStock(ctx context.Context, bookID string, delta int, reason string) (models.StockAdjustment, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(bookID)
+	if err != nil {
+		return models.StockAdjustment{}, ErrInvalidID
+	}
+
+	book, err := s.incStock(ctx, objID, delta)
+	if err != nil {
+		return models.StockAdjustment{}, err
+	}
+
+	adjustment := models.StockAdjustment{
+		ID:            models.NewUUID(),
+		BookID:        objID,
+		Delta:         delta,
+		Reason:        reason,
+		QuantityAfter: book.Quantity,
+		CreatedAt:     time.Now().UTC(),
+	}
+	if _, err := s.adjustments.InsertOne(ctx, adjustment); err != nil {
+		// Undo the change so stock never moves without its adjustment
+		s.incStock(detached(ctx), objID, -delta)
+		return models.StockAdjustment{}, err
+	}
+
+	return adjustment, nil
+}
+
+// Reserve takes quantity off a book's stock for ttl
+func (s *MongoInventoryStore) Reserve(ctx context.Context, bookID string, quantity int, ttl time.Duration) (models.Reservation, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(bookID)
+	if err != nil {
+		return models.Reservation{}, ErrInvalidID
+	}
+
+	if _, err := s.incStock(ctx, objID, -quantity); err != nil {
+		return models.Reservation{}, err
+	}
+
+	now := time.Now().UTC()
+	reservation := models.Reservation{
+		ID:        models.NewUUID(),
+		BookID:    objID,
+		Quantity:  quantity,
+		Status:    models.ReservationActive,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+		UpdatedAt: now,
+	}
+	if _, err := s.reservations.InsertOne(ctx, reservation); err != nil {
+		// Give the stock back so a failed insert does not leak it
+		s.changes.incStock(detached(ctx), bson.M{"_id": objID}, quantity)
+		return models.Reservation{}, err
+	}
+
+	return reservation, nil
+}
+
+// GetReservation returns a reservation by ID
+func (s *MongoInventoryStore) GetReservation(ctx context.Context, id string) (models.Reservation, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var reservation models.Reservation
+	err := s.reservations.FindOne(ctx, bson.M{"_id": id}).Decode(&reservation)
+	if err == mongo.ErrNoDocuments {
+		return models.Reservation{}, ErrNotFound
+	}
+	if err != nil {
+		return models.Reservation{}, err
+	}
+
+	return reservation, nil
+}
+
+// ReleaseReservation returns an active reservation's stock to the book
+func (s *MongoInventoryStore) ReleaseReservation(ctx context.Context, id string) (models.Reservation, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	now := time.Now().UTC()
+	reservation, err := s.closeRestocked(ctx, bson.M{"_id": id, "expiresAt": bson.M{"$gt": now}}, models.ReservationReleased, now)
+	if err == mongo.ErrNoDocuments {
+		return s.closeFailed(ctx, id, now)
+	}
+	if err != nil {
+		return models.Reservation{}, err
+	}
+
+	return reservation, nil
+}
+
+// CommitReservation makes an active reservation's stock decrement permanent
+func (s *MongoInventoryStore) CommitReservation(ctx context.Context, id string) (models.Reservation, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	now := time.Now().UTC()
+	reservation, err := s.transition(ctx, bson.M{"_id": id, "expiresAt": bson.M{"$gt": now}}, models.ReservationCommitted, now)
+	if err == mongo.ErrNoDocuments {
+		return s.closeFailed(ctx, id, now)
+	}
+	if err != nil {
+		return models.Reservation{}, err
+	}
+
+	return reservation, nil
+}
+
+// ExpireReservations releases every active reservation that expired before now
+func (s *MongoInventoryStore) ExpireReservations(ctx context.Context, now time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	cursor, err := s.reservations.Find(ctx, bson.M{
+		"status":    models.ReservationActive,
+		"expiresAt": bson.M{"$lte": now},
+	}, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return 0, err
+	}
+
+	var ids []struct {
+		ID string `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &ids); err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, doc := range ids {
+		if _, err := s.expire(ctx, doc.ID, now); err == mongo.ErrNoDocuments {
+			// Released, committed or expired concurrently
+			continue
+		} else if err != nil {
+			return expired, err
+		}
+		expired++
+	}
+
+	return expired, nil
+}
+
+// incStock adds delta to a book's quantity unless that would take it below zero
+func (s *MongoInventoryStore) incStock(ctx context.Context, id primitive.ObjectID, delta int) (models.Book, error) {
+	filter := bson.M{"_id": id}
+	if delta < 0 {
+		filter["quantity"] = bson.M{"$gte": -delta}
+	}
+
+	book, err := s.changes.incStock(ctx, filter, delta)
+	if err == mongo.ErrNoDocuments {
+		n, err := s.books.CountDocuments(ctx, bson.M{"_id": id}, options.Count().SetLimit(1))
+		if err != nil {
+			return models.Book{}, err
+		}
+		if n == 0 {
+			return models.Book{}, ErrNotFound
+		}
+		return models.Book{}, ErrInsufficientStock
+	}
+	if err != nil {
+		return models.Book{}, err
+	}
+
+	return book, nil
+}
+
+// transition moves an active reservation matching filter to status.
+// Only one caller can win the transition, so stock is restored at most once.
+func (s *MongoInventoryStore) transition(ctx context.Context, filter bson.M, status string, now time.Time) (models.Reservation, error) {
+	filter["status"] = models.ReservationActive
+
+	var reservation models.Reservation
+	err := s.reservations.FindOneAndUpdate(ctx, filter,
+		bson.M{"$set": bson.M{"status": status, "updatedAt": now}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&reservation)
+
+	return reservation, err
+}
+
+// expire restocks an active, expired reservation and marks it as expired
+func (s *MongoInventoryStore) expire(ctx context.Context, id string, now time.Time) (models.Reservation, error) {
+	return s.closeRestocked(ctx, bson.M{"_id": id, "expiresAt": bson.M{"$lte": now}}, models.ReservationExpired, now)
+}
+
+// closeRestocked gives the stock of the active reservation matching filter
+// back to its book, then moves the reservation to status. Restocking first
+// means a failure leaves the reservation active, to be retried or swept,
+// rather than closed with its stock lost. If another request closes the
+// reservation in between, the restock is undone.
+func (s *MongoInventoryStore) closeRestocked(ctx context.Context, filter bson.M, status string, now time.Time) (models.Reservation, error) {
+	filter["status"] = models.ReservationActive
+
+	var active models.Reservation
+	if err := s.reservations.FindOne(ctx, filter).Decode(&active); err != nil {
+		return models.Reservation{}, err
+	}
+
+	restocked, err := s.restock(ctx, active)
+	if err != nil {
+		return models.Reservation{}, err
+	}
+
+	reservation, err := s.transition(ctx, filter, status, now)
+	if err != nil && restocked {
+		s.unstock(detached(ctx), active)
+	}
+
+	return reservation, err
+}
+
+// closeFailed explains why a release or commit matched no active, unexpired
+// reservation. A reservation that has expired but not been swept yet is
+// expired on the spot.
+func (s *MongoInventoryStore) closeFailed(ctx context.Context, id string, now time.Time) (models.Reservation, error) {
+	reservation, err := s.expire(ctx, id, now)
+	if err == nil {
+		return reservation, ErrReservationExpired
+	}
+	if err != mongo.ErrNoDocuments {
+		return models.Reservation{}, err
+	}
+
+	reservation, err = s.GetReservation(ctx, id)
+	if err != nil {
+		return models.Reservation{}, err
+	}
+	if reservation.Status == models.ReservationExpired {
+		return reservation, ErrReservationExpired
+	}
+
+	return reservation, ErrReservationClosed
+}
+
+// restock gives a reservation's quantity back to its book, reporting
+// whether the book still exists to take it
+func (s *MongoInventoryStore) restock(ctx context.Context, r models.Reservation) (bool, error) {
+	_, err := s.changes.incStock(ctx, bson.M{"_id": r.BookID}, r.Quantity)
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// unstock takes back a restock whose reservation could not be closed. If
+// the restocked units have been sold in the meantime there is nothing left
+// to take, which is logged.
+func (s *MongoInventoryStore) unstock(ctx context.Context, r models.Reservation) {
+	if _, err := s.incStock(ctx, r.BookID, -r.Quantity); err != nil {
+		log.Printf("WARNING: taking back %d units of book %s restocked for reservation %s failed: %v", r.Quantity, r.BookID.Hex(), r.ID, err)
+	}
+}
@@ -0,0 +1,221 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/harshakumara/book-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoOrderStore is an OrderStore backed by MongoDB.
+// Status changes are guarded on the current status so only one concurrent
+// request can win a transition; stock is moved with guarded $inc updates and
+// given back if the transition is lost. Every stock change is recorded as a
+// revision of the book.
+type MongoOrderStore struct {
+	orders  *mongo.Collection
+	books   *mongo.Collection
+	changes mongoBookChanges
+	timeout time.Duration
+}
+
+// NewMongoOrderStore creates an OrderStore on top of the given database
+func NewMongoOrderStore(db *mongo.Database, opts MongoOptions) *MongoOrderStore {
+	opts = opts.withDefaults()
+
+	return &MongoOrderStore{
+		orders:  db.Collection(opts.OrdersCollection),
+		books:   db.Collection(opts.BooksCollection),
+		changes: newMongoBookChanges(db, opts),
+		timeout: opts.Timeout,
+	}
+}
+
+// List returns orders, newest first, optionally only those with the given status
+func (s *MongoOrderStore) List(ctx context.Context, status string) ([]models.Order, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	filter := bson.M{}
+	if status != "" {
+		filter["status"] = status
+	}
+
+	cursor, err := s.orders.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	orders := []models.Order{}
+	if err := cursor.All(ctx, &orders); err != nil {
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+// Get returns a single order by ID
+func (s *MongoOrderStore) Get(ctx context.Context, id string) (models.Order, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var order models.Order
+	err := s.orders.FindOne(ctx, bson.M{"_id": id}).Decode(&order)
+	if err == mongo.ErrNoDocuments {
+		return models.Order{}, ErrNotFound
+	}
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	return order, nil
+}
+
+// Create places a pending order, copying each item's title and price from its book
+func (s *MongoOrderStore) Create(ctx context.Context, order models.Order) (models.Order, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	order.Items = append([]models.OrderItem{}, order.Items...)
+	ids := make([]primitive.ObjectID, len(order.Items))
+	for i, item := range order.Items {
+		ids[i] = item.BookID
+	}
+
+	cursor, err := s.books.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return models.Order{}, err
+	}
+	var found []models.Book
+	if err := cursor.All(ctx, &found); err != nil {
+		return models.Order{}, err
+	}
+
+	books := map[primitive.ObjectID]models.Book{}
+	for _, b := range found {
+		books[b.ID] = b
+	}
+	for i, item := range order.Items {
+		if _, ok := books[item.BookID]; !ok {
+			return models.Order{}, &ReferenceError{Field: fmt.Sprintf("items[%d].bookId", i), Resource: "book", ID: item.BookID.Hex()}
+		}
+	}
+
+	now := time.Now().UTC()
+	order.ID = models.NewUUID()
+	order.Status = models.OrderPending
+	order.CreatedAt = now
+	order.UpdatedAt = now
+	order.PriceItems(books)
+
+	if _, err := s.orders.InsertOne(ctx, order); err != nil {
+		return models.Order{}, err
+	}
+
+	return order, nil
+}
+
+// Transition moves an order to a new status, moving stock as needed
+func (s *MongoOrderStore) Transition(ctx context.Context, id, status string) (models.Order, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	order, err := s.Get(ctx, id)
+	if err != nil {
+		return models.Order{}, err
+	}
+	if !models.CanTransition(order.Status, status) {
+		return models.Order{}, ErrInvalidTransition
+	}
+
+	// Stock moves before the status does, so a failure leaves the order as
+	// it was, with its stock, and the transition can be retried
+	restock := status == models.OrderCancelled && order.Status == models.OrderPaid
+	var put []models.OrderItem
+	switch {
+	case status == models.OrderPaid:
+		if err := s.takeStock(ctx, order.Items); err != nil {
+			return models.Order{}, err
+		}
+	case restock:
+		if put, err = s.putStock(ctx, order.Items); err != nil {
+			return models.Order{}, err
+		}
+	}
+
+	var updated models.Order
+	err = s.orders.FindOneAndUpdate(ctx,
+		bson.M{"_id": id, "status": order.Status},
+		bson.M{"$set": bson.M{"status": status, "updatedAt": time.Now().UTC()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		// Another request changed the order first; undo the stock move
+		switch {
+		case status == models.OrderPaid:
+			s.putStock(detached(ctx), order.Items)
+		case restock:
+			s.retakeStock(detached(ctx), put)
+		}
+		if err == mongo.ErrNoDocuments {
+			return models.Order{}, ErrInvalidTransition
+		}
+		return models.Order{}, err
+	}
+
+	return updated, nil
+}
+
+// takeStock decrements every item's book, never below zero.
+// If any book is short, the items already taken are put back.
+func (s *MongoOrderStore) takeStock(ctx context.Context, items []models.OrderItem) error {
+	for i, item := range items {
+		_, err := s.changes.incStock(ctx, bson.M{"_id": item.BookID, "quantity": bson.M{"$gte": item.Quantity}}, -item.Quantity)
+		if err == mongo.ErrNoDocuments {
+			err = fmt.Errorf("%w for book %s", ErrInsufficientStock, item.BookID.Hex())
+		}
+		if err != nil {
+			s.putStock(detached(ctx), items[:i])
+			return err
+		}
+	}
+
+	return nil
+}
+
+// putStock increments every item's book by the item's quantity and
+// returns the items it put back. Books deleted since have nothing to
+// restock. If any item fails, the items already put back are taken again.
+func (s *MongoOrderStore) putStock(ctx context.Context, items []models.OrderItem) ([]models.OrderItem, error) {
+	var put []models.OrderItem
+	for _, item := range items {
+		_, err := s.changes.incStock(ctx, bson.M{"_id": item.BookID}, item.Quantity)
+		if err == mongo.ErrNoDocuments {
+			continue
+		}
+		if err != nil {
+			s.retakeStock(detached(ctx), put)
+			return nil, err
+		}
+		put = append(put, item)
+	}
+
+	return put, nil
+}
+
+// retakeStock undoes a putStock of items. Units sold in the meantime cannot
+// be taken again, which is logged.
+func (s *MongoOrderStore) retakeStock(ctx context.Context, items []models.OrderItem) {
+	for _, item := range items {
+		_, err := s.changes.incStock(ctx, bson.M{"_id": item.BookID, "quantity": bson.M{"$gte": item.Quantity}}, -item.Quantity)
+		if err != nil {
+			log.Printf("WARNING: taking back %d units of book %s restocked for an order failed: %v", item.Quantity, item.BookID.Hex(), err)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/harshakumara/book-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoPublisherStore is a PublisherStore backed by a MongoDB collection
+type MongoPublisherStore struct {
+	collection *mongo.Collection
+	books      *mongo.Collection
+	changes    mongoBookChanges
+	timeout    time.Duration
+}
+
+// NewMongoPublisherStore creates a PublisherStore on top of the given database
+func NewMongoPublisherStore(db *mongo.Database, opts MongoOptions) *MongoPublisherStore {
+	opts = opts.withDefaults()
+
+	return &MongoPublisherStore{
+		collection: db.Collection(opts.PublishersCollection),
+		books:      db.Collection(opts.BooksCollection),
+		changes:    newMongoBookChanges(db, opts),
+		timeout:    opts.Timeout,
+	}
+}
+
+// List returns all publishers ordered by name
+func (s *MongoPublisherStore) List(ctx context.Context) ([]models.Publisher, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := s.collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	publishers := []models.Publisher{}
+	if err := cursor.All(ctx, &publishers); err != nil {
+		return nil, err
+	}
+
+	return publishers, nil
+}
+
+// Get returns a single publisher by ID
+func (s *MongoPublisherStore) Get(ctx context.Context, id string) (models.Publisher, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var publisher models.Publisher
+	err := s.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&publisher)
+	if err == mongo.ErrNoDocuments {
+		return models.Publisher{}, ErrNotFound
+	}
+	if err != nil {
+		return models.Publisher{}, err
+	}
+
+	return publisher, nil
+}
+
+// Create stores a new publisher
+func (s *MongoPublisherStore) Create(ctx context.Context, publisher models.Publisher) (models.Publisher, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if publisher.ID == "" {
+		publisher.ID = models.NewUUID()
+	}
+
+	if _, err := s.collection.InsertOne(ctx, publisher); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return models.Publisher{}, ErrConflict
+		}
+		return models.Publisher{}, err
+	}
+
+	return publisher, nil
+}
+
+// Update replaces the publisher with the given ID
+func (s *MongoPublisherStore) Update(ctx context.Context, id string, publisher models.Publisher) (models.Publisher, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	// Preserve the original ID
+	publisher.ID = id
+
+	result, err := s.collection.ReplaceOne(ctx, bson.M{"_id": id}, publisher)
+	if err != nil {
+		return models.Publisher{}, err
+	}
+	if result.MatchedCount == 0 {
+		return models.Publisher{}, ErrNotFound
+	}
+
+	return publisher, nil
+}
+
+// Delete removes the publisher, handling its books according to policy.
+// Without multi-document transactions the book changes are applied first, so
+// an interrupted delete leaves the publisher in place rather than dangling books.
+// Each book changed or deleted is recorded as a revision.
+func (s *MongoPublisherStore) Delete(ctx context.Context, id string, policy DeletePolicy) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	ok, err := exists(ctx, s.collection, id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotFound
+	}
+
+	byPublisher := bson.M{"publisherId": id}
+	switch policy {
+	case DeleteCascade:
+		if err := s.changes.deleteWhere(ctx, byPublisher); err != nil {
+			return err
+		}
+	case DeleteOrphan:
+		orphan := func(b *models.Book) { b.PublisherID = "" }
+		if err := s.changes.setWhere(ctx, byPublisher, "publisherId", "", orphan); err != nil {
+			return err
+		}
+	default:
+		ok, err := existsWhere(ctx, s.books, byPublisher)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return ErrHasBooks
+		}
+	}
+
+	result, err := s.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
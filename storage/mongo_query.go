@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// mongoFilter translates a BookFilter into a MongoDB filter document
+func mongoFilter(f BookFilter) bson.M {
+	filter := bson.M{}
+	if f.Genre != "" {
+		filter["genre"] = f.Genre
+	}
+	if f.AuthorID != "" {
+		filter["authorId"] = f.AuthorID
+	}
+	if f.PublisherID != "" {
+		filter["publisherId"] = f.PublisherID
+	}
+
+	price := bson.M{}
+	if f.MinPrice != nil {
+		price["$gte"] = *f.MinPrice
+	}
+	if f.MaxPrice != nil {
+		price["$lte"] = *f.MaxPrice
+	}
+	if len(price) > 0 {
+		filter["price"] = price
+	}
+
+	if f.InStock != nil {
+		if *f.InStock {
+			filter["quantity"] = bson.M{"$gt": 0}
+		} else {
+			filter["quantity"] = bson.M{"$lte": 0}
+		}
+	}
+
+	// The price band gets its own clause so it combines with minPrice and maxPrice
+	if i := priceBandIndex(f.PriceBand); i >= 0 {
+		band := bson.M{"$gte": PriceBands[i].Min}
+		if PriceBands[i].Max > 0 {
+			band["$lt"] = PriceBands[i].Max
+		}
+		filter["$and"] = []bson.M{{"price": band}}
+	}
+	if prefix, ok := decadePrefix(f.Decade); ok {
+		filter["publicationDate"] = bson.M{"$regex": "^" + prefix}
+	}
+
+	return filter
+}
+
+// mongoFacetStages builds the $facet stage that counts the named facets.
+// Each sub-pipeline groups books by the facet's value into {_id, count}.
+func mongoFacetStages(names []string) bson.M {
+	count := bson.M{"$sum": 1}
+	stages := bson.M{}
+	for _, name := range names {
+		switch name {
+		case FacetGenre:
+			stages[name] = bson.A{
+				bson.M{"$match": bson.M{"genre": bson.M{"$nin": bson.A{nil, ""}}}},
+				bson.M{"$group": bson.M{"_id": "$genre", "count": count}},
+			}
+		case FacetPrice:
+			// $bucket labels each bucket with its lower boundary; prices
+			// past the last boundary fall into the open-ended default
+			boundaries := bson.A{}
+			for _, band := range PriceBands {
+				boundaries = append(boundaries, band.Min)
+			}
+			stages[name] = bson.A{
+				bson.M{"$bucket": bson.M{
+					"groupBy":    "$price",
+					"boundaries": boundaries,
+					"default":    "last",
+					"output":     bson.M{"count": count},
+				}},
+			}
+		case FacetDecade:
+			stages[name] = bson.A{
+				bson.M{"$match": bson.M{"publicationDate": bson.M{"$regex": "^[0-9]{4}"}}},
+				bson.M{"$group": bson.M{"_id": bson.M{"$substrCP": bson.A{"$publicationDate", 0, 3}}, "count": count}},
+			}
+		case FacetStock:
+			stages[name] = bson.A{
+				bson.M{"$group": bson.M{"_id": bson.M{"$gt": bson.A{"$quantity", 0}}, "count": count}},
+			}
+		case FacetPublisher:
+			stages[name] = bson.A{
+				bson.M{"$match": bson.M{"publisherId": bson.M{"$nin": bson.A{nil, ""}}}},
+				bson.M{"$group": bson.M{"_id": "$publisherId", "count": count}},
+			}
+		}
+	}
+
+	return bson.M{"$facet": stages}
+}
+
+// mongoFacetValue turns the _id a facet sub-pipeline grouped by into the
+// facet value CountFacets would report
+func mongoFacetValue(name string, id interface{}) string {
+	switch name {
+	case FacetPrice:
+		if min, ok := id.(float64); ok {
+			for _, band := range PriceBands {
+				if band.Min == min {
+					return band.Label
+				}
+			}
+		}
+		return PriceBands[len(PriceBands)-1].Label
+	case FacetDecade:
+		return fmt.Sprint(id) + "0s"
+	case FacetStock:
+		if id == true {
+			return InStock
+		}
+		return OutOfStock
+	}
+
+	return fmt.Sprint(id)
+}
+
+// mongoSort builds the sort document for a query, tie-broken by _id
+func mongoSort(q BookQuery) bson.D {
+	sort := bson.D{}
+	for _, f := range q.Sort {
+		dir := 1
+		if f.Desc {
+			dir = -1
+		}
+		sort = append(sort, bson.E{Key: f.Field, Value: dir})
+	}
+
+	return append(sort, bson.E{Key: "_id", Value: 1})
+}
+
+// mongoCursorFilter matches books that sort strictly after the cursor
+func mongoCursorFilter(q BookQuery, c cursor, id primitive.ObjectID) bson.M {
+	var or []bson.M
+	equal := bson.M{}
+	for i, f := range q.Sort {
+		op := "$gt"
+		if f.Desc {
+			op = "$lt"
+		}
+
+		clause := bson.M{f.Field: bson.M{op: c.Values[i]}}
+		for k, v := range equal {
+			clause[k] = v
+		}
+		or = append(or, clause)
+
+		equal[f.Field] = c.Values[i]
+	}
+
+	last := bson.M{"_id": bson.M{"$gt": id}}
+	for k, v := range equal {
+		last[k] = v
+	}
+
+	return bson.M{"$or": append(or, last)}
+}
@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/harshakumara/book-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoRevisionStore is a RevisionStore backed by a MongoDB collection.
+// Revisions are only ever inserted.
+type MongoRevisionStore struct {
+	revisions *mongo.Collection
+	timeout   time.Duration
+}
+
+// NewMongoRevisionStore creates a RevisionStore on top of the given database
+func NewMongoRevisionStore(db *mongo.Database, opts MongoOptions) *MongoRevisionStore {
+	opts = opts.withDefaults()
+
+	return &MongoRevisionStore{
+		revisions: db.Collection(opts.RevisionsCollection),
+		timeout:   opts.Timeout,
+	}
+}
+
+// EnsureIndexes creates the indexes that serve book histories and the
+// audit log if they are missing
+func (s *MongoRevisionStore) EnsureIndexes(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.revisions.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "bookId", Value: 1}, {Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}},
+	})
+
+	return err
+}
+
+// Record appends revisions to the log
+func (s *MongoRevisionStore) Record(ctx context.Context, revisions []models.Revision) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	docs := make([]interface{}, len(revisions))
+	for i, r := range revisions {
+		docs[i] = r
+	}
+
+	_, err := s.revisions.InsertMany(ctx, docs)
+	return err
+}
+
+// Get returns a single revision by ID
+func (s *MongoRevisionStore) Get(ctx context.Context, id string) (models.Revision, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.Revision{}, ErrInvalidID
+	}
+
+	var revision models.Revision
+	err = s.revisions.FindOne(ctx, bson.M{"_id": objID}).Decode(&revision)
+	if err == mongo.ErrNoDocuments {
+		return models.Revision{}, ErrNotFound
+	}
+	if err != nil {
+		return models.Revision{}, err
+	}
+
+	return revision, nil
+}
+
+// History returns every revision of a book, oldest first
+func (s *MongoRevisionStore) History(ctx context.Context, bookID string) ([]models.Revision, error) {
+	objID, err := primitive.ObjectIDFromHex(bookID)
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+
+	return s.find(ctx, bson.M{"bookId": objID}, 0)
+}
+
+// Since returns up to limit revisions recorded at or after since, oldest first
+func (s *MongoRevisionStore) Since(ctx context.Context, since time.Time, limit int) ([]models.Revision, error) {
+	return s.find(ctx, bson.M{"createdAt": bson.M{"$gte": since}}, limit)
+}
+
+// find returns up to limit revisions matching filter, oldest first
+func (s *MongoRevisionStore) find(ctx context.Context, filter bson.M, limit int) ([]models.Revision, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}})
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+
+	cursor, err := s.revisions.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := []models.Revision{}
+	if err := cursor.All(ctx, &revisions); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
@@ -0,0 +1,560 @@
+package storage
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/harshakumara/book-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoOptions configures the MongoDB stores; zero values fall back to the defaults
+type MongoOptions struct {
+	BooksCollection        string
+	AuthorsCollection      string
+	PublishersCollection   string
+	ReservationsCollection string
+	AdjustmentsCollection  string
+	OrdersCollection       string
+	RevisionsCollection    string
+	// Timeout bounds every MongoDB operation
+	Timeout time.Duration
+}
+
+// withDefaults fills in unset options
+func (o MongoOptions) withDefaults() MongoOptions {
+	if o.BooksCollection == "" {
+		o.BooksCollection = "books"
+	}
+	if o.AuthorsCollection == "" {
+		o.AuthorsCollection = "authors"
+	}
+	if o.PublishersCollection == "" {
+		o.PublishersCollection = "publishers"
+	}
+	if o.ReservationsCollection == "" {
+		o.ReservationsCollection = "reservations"
+	}
+	if o.AdjustmentsCollection == "" {
+		o.AdjustmentsCollection = "stock_adjustments"
+	}
+	if o.OrdersCollection == "" {
+		o.OrdersCollection = "orders"
+	}
+	if o.RevisionsCollection == "" {
+		o.RevisionsCollection = "book_revisions"
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 10 * time.Second
+	}
+
+	return o
+}
+
+// MongoBookStore is a BookStore backed by a MongoDB collection
+type MongoBookStore struct {
+	collection *mongo.Collection
+	authors    *mongo.Collection
+	publishers *mongo.Collection
+	timeout    time.Duration
+}
+
+// NewMongoBookStore creates a BookStore on top of the given database
+func NewMongoBookStore(db *mongo.Database, opts MongoOptions) *MongoBookStore {
+	opts = opts.withDefaults()
+
+	return &MongoBookStore{
+		collection: db.Collection(opts.BooksCollection),
+		authors:    db.Collection(opts.AuthorsCollection),
+		publishers: db.Collection(opts.PublishersCollection),
+		timeout:    opts.Timeout,
+	}
+}
+
+// isbnIndexName names the unique index on book ISBNs
+const isbnIndexName = "isbn_unique"
+
+// EnsureIndexes creates the books collection's indexes if they are missing.
+// ISBNs are unique among books that have one; creating the index fails
+// while the collection holds duplicates.
+func (s *MongoBookStore) EnsureIndexes(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "isbn", Value: 1}},
+		Options: options.Index().
+			SetName(isbnIndexName).
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"isbn": bson.M{"$gt": ""}}),
+	})
+
+	return err
+}
+
+// bookWriteError turns a unique index violation into ErrDuplicateISBN, or
+// ErrConflict for a duplicate ID
+func bookWriteError(err error) error {
+	if !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+	if strings.Contains(err.Error(), isbnIndexName) {
+		return ErrDuplicateISBN
+	}
+
+	return ErrConflict
+}
+
+// List returns one page of books matching the query
+func (s *MongoBookStore) List(ctx context.Context, q BookQuery) (BookPage, error) {
+	if err := q.Validate(); err != nil {
+		return BookPage{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	filter := mongoFilter(q.Filter)
+	total, err := s.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return BookPage{}, err
+	}
+
+	var query interface{} = filter
+	if q.Cursor != "" {
+		c, id, err := decodeCursor(q)
+		if err != nil {
+			return BookPage{}, err
+		}
+		query = bson.M{"$and": []bson.M{filter, mongoCursorFilter(q, c, id)}}
+	}
+
+	opts := options.Find().SetSort(mongoSort(q))
+	if q.Offset > 0 {
+		opts.SetSkip(int64(q.Offset))
+	}
+	if q.Limit > 0 {
+		// Fetch one extra book to learn whether there is a next page
+		opts.SetLimit(int64(q.Limit) + 1)
+	}
+
+	books, err := s.find(ctx, query, opts)
+	if err != nil {
+		return BookPage{}, err
+	}
+
+	page := BookPage{Books: books, Total: total}
+	if q.Limit > 0 && len(books) > q.Limit {
+		page.Books = books[:q.Limit]
+		page.NextCursor = encodeCursor(q, page.Books[q.Limit-1])
+	}
+
+	return page, nil
+}
+
+// Get returns a single book by ID
+func (s *MongoBookStore) Get(ctx context.Context, id string) (models.Book, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.Book{}, ErrInvalidID
+	}
+
+	var book models.Book
+	err = s.collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&book)
+	if err == mongo.ErrNoDocuments {
+		return models.Book{}, ErrNotFound
+	}
+	if err != nil {
+		return models.Book{}, err
+	}
+
+	return book, nil
+}
+
+// GetMany returns the books with the given IDs
+func (s *MongoBookStore) GetMany(ctx context.Context, ids []string) ([]models.Book, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	objIDs := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		if objID, err := primitive.ObjectIDFromHex(id); err == nil {
+			objIDs = append(objIDs, objID)
+		}
+	}
+
+	return s.find(ctx, bson.M{"_id": bson.M{"$in": objIDs}})
+}
+
+// Create stores a new book
+func (s *MongoBookStore) Create(ctx context.Context, book models.Book) (models.Book, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	// Generate new ID if not provided
+	if book.ID.IsZero() {
+		book.ID = primitive.NewObjectID()
+	}
+	if book.Version <= 0 {
+		book.Version = 1
+	}
+
+	if err := s.checkReferences(ctx, book); err != nil {
+		return models.Book{}, err
+	}
+
+	if _, err := s.collection.InsertOne(ctx, book); err != nil {
+		return models.Book{}, bookWriteError(err)
+	}
+
+	return book, nil
+}
+
+// Update replaces the book with the given ID
+func (s *MongoBookStore) Update(ctx context.Context, id string, book models.Book, version int64) (models.Book, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.Book{}, ErrInvalidID
+	}
+
+	// Preserve the original ID
+	book.ID = objID
+
+	if err := s.checkReferences(ctx, book); err != nil {
+		// A missing book takes precedence over its bad references
+		if ok, existsErr := exists(ctx, s.collection, objID); existsErr == nil && !ok {
+			return models.Book{}, ErrNotFound
+		}
+		return models.Book{}, err
+	}
+
+	set, err := bookFields(book)
+	if err != nil {
+		return models.Book{}, err
+	}
+
+	var updated models.Book
+	err = s.collection.FindOneAndUpdate(ctx, versionFilter(objID, version),
+		bson.M{"$set": set, "$inc": bson.M{"version": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		return models.Book{}, s.missed(ctx, objID)
+	}
+	if err != nil {
+		return models.Book{}, bookWriteError(err)
+	}
+
+	return updated, nil
+}
+
+// Patch applies a change to the book with the given ID, writing only the
+// changed fields with $set.
+// The write is guarded on the version that was read, so a concurrent change
+// is never overwritten; an unconditional patch is retried against it.
+func (s *MongoBookStore) Patch(ctx context.Context, id string, version int64, apply func(models.Book) (models.Book, error)) (models.Book, error) {
+	const attempts = 3
+
+	for attempt := 1; ; attempt++ {
+		current, err := s.Get(ctx, id)
+		if err != nil {
+			return models.Book{}, err
+		}
+		if version != AnyVersion && current.Version != version {
+			return models.Book{}, ErrVersionMismatch
+		}
+
+		book, err := s.patch(ctx, current, apply)
+		if err != ErrVersionMismatch || version != AnyVersion || attempt == attempts {
+			return book, err
+		}
+	}
+}
+
+// patch applies a change to the given version of a book
+func (s *MongoBookStore) patch(ctx context.Context, current models.Book, apply func(models.Book) (models.Book, error)) (models.Book, error) {
+	book, err := apply(current)
+	if err != nil {
+		return models.Book{}, err
+	}
+	// Preserve the original ID
+	book.ID = current.ID
+	book.Version = current.Version
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if err := s.checkReferences(ctx, book); err != nil {
+		return models.Book{}, err
+	}
+
+	set, err := changedFields(current, book)
+	if err != nil {
+		return models.Book{}, err
+	}
+	if len(set) == 0 {
+		return current, nil
+	}
+
+	res, err := s.collection.UpdateOne(ctx, versionFilter(book.ID, current.Version),
+		bson.M{"$set": set, "$inc": bson.M{"version": 1}})
+	if err != nil {
+		return models.Book{}, bookWriteError(err)
+	}
+	if res.MatchedCount == 0 {
+		return models.Book{}, s.missed(ctx, book.ID)
+	}
+
+	book.Version++
+	return book, nil
+}
+
+// Delete removes the book with the given ID
+func (s *MongoBookStore) Delete(ctx context.Context, id string, version int64) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrInvalidID
+	}
+
+	res, err := s.collection.DeleteOne(ctx, versionFilter(objID, version))
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return s.missed(ctx, objID)
+	}
+
+	return nil
+}
+
+// missed explains why a write guarded by versionFilter matched nothing
+func (s *MongoBookStore) missed(ctx context.Context, id primitive.ObjectID) error {
+	ok, err := exists(ctx, s.collection, id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotFound
+	}
+
+	return ErrVersionMismatch
+}
+
+// versionFilter matches the book with the given ID at version, or at any
+// version for AnyVersion. Books stored before versioning have no version
+// field and match version 0.
+func versionFilter(id primitive.ObjectID, version int64) bson.M {
+	filter := bson.M{"_id": id}
+	switch version {
+	case AnyVersion:
+	case 0:
+		filter["version"] = bson.M{"$in": bson.A{0, nil}}
+	default:
+		filter["version"] = version
+	}
+
+	return filter
+}
+
+// Search returns books whose title or description contains the keyword
+func (s *MongoBookStore) Search(ctx context.Context, keyword string) ([]models.Book, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	// The keyword is escaped so it matches literally; otherwise a client
+	// could send a pattern that backtracks for minutes
+	return s.find(ctx, regexFilter(regexp.QuoteMeta(keyword)))
+}
+
+// SearchPattern returns books whose title or description matches the
+// pattern. The server gives up after PatternTimeout.
+func (s *MongoBookStore) SearchPattern(ctx context.Context, p *Pattern) ([]models.Book, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	books, err := s.find(ctx, regexFilter(p.String()), options.Find().SetMaxTime(PatternTimeout))
+	if mongo.IsTimeout(err) {
+		return nil, ErrSearchTimeout
+	}
+	return books, err
+}
+
+// SearchStructured returns the books matching a structured query
+func (s *MongoBookStore) SearchStructured(ctx context.Context, q *StructuredQuery) ([]models.Book, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	sort := bson.D{{Key: "title", Value: 1}, {Key: "_id", Value: 1}}
+	return s.find(ctx, q.mongoFilter(), options.Find().SetSort(sort))
+}
+
+// regexFilter matches books whose title or description matches the
+// regular expression, ignoring case
+func regexFilter(regex string) bson.M {
+	return bson.M{
+		"$or": []bson.M{
+			{"title": bson.M{"$regex": regex, "$options": "i"}},
+			{"description": bson.M{"$regex": regex, "$options": "i"}},
+		},
+	}
+}
+
+// Facets counts the named facets over the books matching the filter with
+// a single $facet aggregation
+func (s *MongoBookStore) Facets(ctx context.Context, filter BookFilter, names []string) (Facets, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return Facets{}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	pipeline := bson.A{bson.M{"$match": mongoFilter(filter)}, mongoFacetStages(names)}
+	cursor, err := s.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	// $facet returns a single document with one array per facet
+	var results []map[string][]struct {
+		ID    interface{} `bson:"_id"`
+		Count int64       `bson:"count"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]map[string]int64, len(names))
+	for _, name := range names {
+		counts[name] = map[string]int64{}
+		for _, result := range results {
+			for _, group := range result[name] {
+				counts[name][mongoFacetValue(name, group.ID)] += group.Count
+			}
+		}
+	}
+
+	return sortFacets(counts), nil
+}
+
+// FindByISBN returns the books whose ISBN is one of isbns
+func (s *MongoBookStore) FindByISBN(ctx context.Context, isbns []string) ([]models.Book, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return s.find(ctx, bson.M{"isbn": bson.M{"$in": isbns}})
+}
+
+// checkReferences verifies that the records a book points at exist
+func (s *MongoBookStore) checkReferences(ctx context.Context, book models.Book) error {
+	if book.AuthorID != "" {
+		ok, err := exists(ctx, s.authors, book.AuthorID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return &ReferenceError{Field: "authorId", Resource: "author", ID: book.AuthorID}
+		}
+	}
+
+	if book.PublisherID != "" {
+		ok, err := exists(ctx, s.publishers, book.PublisherID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return &ReferenceError{Field: "publisherId", Resource: "publisher", ID: book.PublisherID}
+		}
+	}
+
+	return nil
+}
+
+// bookFields returns a book's BSON fields by name, without _id and version
+func bookFields(book models.Book) (bson.M, error) {
+	raw, err := bson.Marshal(book)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields bson.M
+	if err := bson.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	delete(fields, "_id")
+	delete(fields, "version")
+
+	return fields, nil
+}
+
+// changedFields returns the BSON fields whose values differ between two
+// versions of a book, keyed by field name
+func changedFields(before, after models.Book) (bson.M, error) {
+	b, err := bookFields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := bookFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	set := bson.M{}
+	for k, v := range a {
+		if !reflect.DeepEqual(b[k], v) {
+			set[k] = v
+		}
+	}
+
+	return set, nil
+}
+
+// exists reports whether a document with the given _id is in the collection
+func exists(ctx context.Context, collection *mongo.Collection, id interface{}) (bool, error) {
+	return existsWhere(ctx, collection, bson.M{"_id": id})
+}
+
+// existsWhere reports whether any document in the collection matches filter
+func existsWhere(ctx context.Context, collection *mongo.Collection, filter interface{}) (bool, error) {
+	n, err := collection.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// find runs a query and decodes every matching book
+func (s *MongoBookStore) find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) ([]models.Book, error) {
+	cursor, err := s.collection.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	books := []models.Book{}
+	if err := cursor.All(ctx, &books); err != nil {
+		return nil, err
+	}
+
+	return books, nil
+}
@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"time"
+
+	"github.com/harshakumara/book-api/models"
+)
+
+// Limits on the regular expressions clients may search with
+const (
+	// MaxPatternLength is the longest pattern accepted, in bytes
+	MaxPatternLength = 100
+	// MaxPatternRepeats is the most quantifiers a pattern may use
+	MaxPatternRepeats = 5
+	// maxRepeatCount bounds counted repetitions such as a{2,50}
+	maxRepeatCount = 50
+	// PatternTimeout bounds how long a store spends matching one pattern
+	PatternTimeout = 2 * time.Second
+)
+
+// ErrSearchTimeout is returned when a pattern search runs out of time
+var ErrSearchTimeout = errors.New("search timed out")
+
+// Pattern is a client-supplied regular expression that has been checked
+// for the constructs that make backtracking engines, MongoDB's included,
+// take exponential time: nested quantifiers and alternations under a
+// quantifier. It matches case-insensitively.
+type Pattern struct {
+	source string
+	re     *regexp.Regexp
+}
+
+// CompilePattern validates a pattern. Only syntax both Go and MongoDB
+// understand is accepted, so backreferences and lookarounds are rejected.
+// Errors wrap ErrInvalidQuery.
+func CompilePattern(source string) (*Pattern, error) {
+	if source == "" {
+		return nil, fmt.Errorf("%w: pattern is empty", ErrInvalidQuery)
+	}
+	if len(source) > MaxPatternLength {
+		return nil, fmt.Errorf("%w: pattern is longer than %d characters", ErrInvalidQuery, MaxPatternLength)
+	}
+
+	parsed, err := syntax.Parse(source, syntax.Perl)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+	}
+	repeats := 0
+	if err := checkPattern(parsed, false, &repeats); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+	}
+	if err := checkSource(source); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+	}
+
+	re, err := regexp.Compile("(?i)" + source)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+	}
+
+	return &Pattern{source: source, re: re}, nil
+}
+
+// checkPattern walks a parsed pattern rejecting the constructs that
+// backtrack badly. inRepeat is set below a quantifier.
+func checkPattern(re *syntax.Regexp, inRepeat bool, repeats *int) error {
+	switch re.Op {
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+		if inRepeat {
+			return errors.New("nested quantifiers are not allowed")
+		}
+		if *repeats++; *repeats > MaxPatternRepeats {
+			return fmt.Errorf("pattern has more than %d quantifiers", MaxPatternRepeats)
+		}
+		if re.Op == syntax.OpRepeat && (re.Min > maxRepeatCount || re.Max > maxRepeatCount) {
+			return fmt.Errorf("repetition counts above %d are not allowed", maxRepeatCount)
+		}
+		inRepeat = true
+	case syntax.OpAlternate:
+		if inRepeat {
+			return errors.New("alternation inside a quantifier is not allowed")
+		}
+	}
+
+	for _, sub := range re.Sub {
+		if err := checkPattern(sub, inRepeat, repeats); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSource rejects alternation inside a quantified group in the pattern
+// as written. The parser folds alternations such as (a|a) or (\w|\d) into
+// a single literal or class, so checkPattern cannot see them, but MongoDB
+// is sent the source and backtracks on them all the same.
+func checkSource(source string) error {
+	// alternates records, for each open group, whether it contains a |
+	var alternates []bool
+	inClass := false
+
+	for i := 0; i < len(source); i++ {
+		switch c := source[i]; {
+		case c == '\\' && strings.HasPrefix(source[i:], `\Q`):
+			// Quoted text runs to \E or the end
+			end := strings.Index(source[i+2:], `\E`)
+			if end < 0 {
+				return nil
+			}
+			i += end + 3
+		case c == '\\':
+			i++
+		case inClass:
+			if strings.HasPrefix(source[i:], "[:") {
+				if end := strings.Index(source[i:], ":]"); end >= 0 {
+					i += end + 1
+				}
+			} else if c == ']' {
+				inClass = false
+			}
+		case c == '[':
+			inClass = true
+			// A ] first in a class is a literal
+			if strings.HasPrefix(source[i+1:], "^") {
+				i++
+			}
+			if strings.HasPrefix(source[i+1:], "]") {
+				i++
+			}
+		case c == '(':
+			alternates = append(alternates, false)
+		case c == '|' && len(alternates) > 0:
+			alternates[len(alternates)-1] = true
+		case c == ')' && len(alternates) > 0:
+			alt := alternates[len(alternates)-1]
+			alternates = alternates[:len(alternates)-1]
+			if alt && quantified(source[i+1:]) {
+				return errors.New("alternation inside a quantifier is not allowed")
+			}
+			if alt && len(alternates) > 0 {
+				alternates[len(alternates)-1] = true
+			}
+		}
+	}
+
+	return nil
+}
+
+// quantified reports whether rest starts with a quantifier
+func quantified(rest string) bool {
+	if rest == "" {
+		return false
+	}
+	switch rest[0] {
+	case '*', '+', '?':
+		return true
+	case '{':
+		return len(rest) > 1 && rest[1] >= '0' && rest[1] <= '9'
+	}
+	return false
+}
+
+// String returns the pattern as the client wrote it
+func (p *Pattern) String() string {
+	return p.source
+}
+
+// MatchBook reports whether the pattern matches the book's title or description
+func (p *Pattern) MatchBook(b models.Book) bool {
+	return p.re.MatchString(b.Title) || p.re.MatchString(b.Description)
+}
+
+// searchPattern returns the books p matches, giving up with
+// ErrSearchTimeout once PatternTimeout has passed
+func searchPattern(ctx context.Context, books []models.Book, p *Pattern) ([]models.Book, error) {
+	ctx, cancel := context.WithTimeout(ctx, PatternTimeout)
+	defer cancel()
+
+	results := []models.Book{}
+	for _, b := range books {
+		if err := ctx.Err(); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return nil, ErrSearchTimeout
+			}
+			return nil, err
+		}
+		if p.MatchBook(b) {
+			results = append(results, b)
+		}
+	}
+
+	return results, nil
+}
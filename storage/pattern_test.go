@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harshakumara/book-api/models"
+)
+
+func TestCompilePattern(t *testing.T) {
+	for _, source := range []string{"^the", "hob+it", "colou?r", "[a-z]{2,5}", ".*", "(?:c|d)\\+\\+", `\bwizard\b`, "(a|b)c+", `\(a|b\)+`, "[(|)]+", `\Q(a|b)+\E`} {
+		if _, err := CompilePattern(source); err != nil {
+			t.Errorf("Expected %q to be accepted, got %v", source, err)
+		}
+	}
+
+	for _, source := range []string{
+		"",
+		"(a+)+$",
+		"(a*)*b",
+		"(a|aa)+",
+		"(a|a)+$",
+		`(\w|\d)+$`,
+		"((a|b)c){2}",
+		"[(](x|x)*",
+		"(x+x+)+y",
+		"(.*a){12}",
+		`(\w+)\1`,
+		"(?=a)b",
+		"a{1000}",
+		"a*b*c*d*e*f*",
+		"(",
+		strings.Repeat("a", MaxPatternLength+1),
+	} {
+		if _, err := CompilePattern(source); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("Expected %q to be rejected as an invalid query, got %v", source, err)
+		}
+	}
+}
+
+func TestSearchPattern(t *testing.T) {
+	books := []models.Book{
+		{Title: "The Hobbit", Description: "There and back again"},
+		{Title: "C++ Primer", Description: "Templates explained"},
+	}
+
+	p, err := CompilePattern(`^c\+\+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found, err := searchPattern(context.Background(), books, p)
+	if err != nil || titles(found) != "C++ Primer" {
+		t.Errorf("Expected a case-insensitive match, got %q, %v", titles(found), err)
+	}
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	if _, err := searchPattern(ctx, books, p); !errors.Is(err, ErrSearchTimeout) {
+		t.Errorf("Expected ErrSearchTimeout past the deadline, got %v", err)
+	}
+}
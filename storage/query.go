@@ -0,0 +1,335 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/harshakumara/book-api/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrInvalidQuery is returned when a BookQuery cannot be executed
+var ErrInvalidQuery = errors.New("invalid query")
+
+// MaxLimit caps the page size a client may request
+const MaxLimit = 1000
+
+// SortableFields lists the book fields that can be sorted on
+var SortableFields = map[string]bool{
+	"title":           true,
+	"authorId":        true,
+	"publisherId":     true,
+	"publicationDate": true,
+	"isbn":            true,
+	"pages":           true,
+	"genre":           true,
+	"price":           true,
+	"quantity":        true,
+}
+
+// BookFilter restricts which books a query returns; zero values match everything
+type BookFilter struct {
+	Genre       string
+	AuthorID    string
+	PublisherID string
+	MinPrice    *float64
+	MaxPrice    *float64
+	InStock     *bool
+	// PriceBand and Decade select a value of the price and decade facets,
+	// such as "10-20" or "1990s"
+	PriceBand string
+	Decade    string
+}
+
+// SortField orders results by a single book field
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// BookQuery describes a filtered, sorted and paginated listing.
+// Results are always tie-broken by ID so pages are stable. Limit 0 means no
+// limit. Cursor and Offset are mutually exclusive.
+type BookQuery struct {
+	Filter BookFilter
+	Sort   []SortField
+	Limit  int
+	Offset int
+	Cursor string
+}
+
+// BookPage is one page of a listing
+type BookPage struct {
+	Books      []models.Book
+	Total      int64
+	NextCursor string
+}
+
+// ParseSort parses a comma-separated sort spec such as "price,-publicationDate"
+func ParseSort(spec string) ([]SortField, error) {
+	var fields []SortField
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		field := SortField{Field: part}
+		if strings.HasPrefix(part, "-") {
+			field = SortField{Field: part[1:], Desc: true}
+		} else if strings.HasPrefix(part, "+") {
+			field.Field = part[1:]
+		}
+
+		if !SortableFields[field.Field] {
+			return nil, fmt.Errorf("%w: cannot sort by %q", ErrInvalidQuery, field.Field)
+		}
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+// Validate checks the query for unsupported or conflicting options
+func (q BookQuery) Validate() error {
+	if q.Limit < 0 || q.Limit > MaxLimit {
+		return fmt.Errorf("%w: limit must be between 0 and %d", ErrInvalidQuery, MaxLimit)
+	}
+	if q.Offset < 0 {
+		return fmt.Errorf("%w: offset must not be negative", ErrInvalidQuery)
+	}
+	if q.Cursor != "" && q.Offset > 0 {
+		return fmt.Errorf("%w: cursor and offset cannot be combined", ErrInvalidQuery)
+	}
+	for _, f := range q.Sort {
+		if !SortableFields[f.Field] {
+			return fmt.Errorf("%w: cannot sort by %q", ErrInvalidQuery, f.Field)
+		}
+	}
+
+	return q.Filter.Validate()
+}
+
+// sortSignature identifies the sort order a cursor was issued for
+func (q BookQuery) sortSignature() string {
+	parts := make([]string, len(q.Sort))
+	for i, f := range q.Sort {
+		if f.Desc {
+			parts[i] = "-" + f.Field
+		} else {
+			parts[i] = f.Field
+		}
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// cursor is the decoded form of BookQuery.Cursor: the sort key of the last
+// book on the previous page
+type cursor struct {
+	Sort   string        `json:"s"`
+	Values []interface{} `json:"v"`
+	ID     string        `json:"id"`
+}
+
+// encodeCursor builds the cursor that resumes after book
+func encodeCursor(q BookQuery, book models.Book) string {
+	c := cursor{Sort: q.sortSignature(), ID: book.ID.Hex()}
+	for _, f := range q.Sort {
+		c.Values = append(c.Values, sortValue(book, f.Field))
+	}
+
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses and checks a cursor against the query's sort order
+func decodeCursor(q BookQuery) (cursor, primitive.ObjectID, error) {
+	var c cursor
+	data, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return c, primitive.NilObjectID, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, primitive.NilObjectID, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	if c.Sort != q.sortSignature() || len(c.Values) != len(q.Sort) {
+		return c, primitive.NilObjectID, fmt.Errorf("%w: cursor does not match sort order", ErrInvalidQuery)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.ID)
+	if err != nil {
+		return c, primitive.NilObjectID, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+
+	// JSON turns every number into float64; normalize string/number types
+	for i, f := range q.Sort {
+		switch sortValue(models.Book{}, f.Field).(type) {
+		case float64:
+			if _, ok := c.Values[i].(float64); !ok {
+				return c, primitive.NilObjectID, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+			}
+		case string:
+			if _, ok := c.Values[i].(string); !ok {
+				return c, primitive.NilObjectID, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+			}
+		}
+	}
+
+	return c, id, nil
+}
+
+// sortValue returns the value of a sortable field as a string or float64
+func sortValue(b models.Book, field string) interface{} {
+	switch field {
+	case "title":
+		return b.Title
+	case "authorId":
+		return b.AuthorID
+	case "publisherId":
+		return b.PublisherID
+	case "publicationDate":
+		return b.PublicationDate
+	case "isbn":
+		return b.ISBN
+	case "genre":
+		return b.Genre
+	case "pages":
+		return float64(b.Pages)
+	case "price":
+		return b.Price
+	case "quantity":
+		return float64(b.Quantity)
+	}
+
+	return nil
+}
+
+// compareValues orders two values returned by sortValue
+func compareValues(a, b interface{}) int {
+	switch av := a.(type) {
+	case string:
+		return strings.Compare(av, b.(string))
+	case float64:
+		bv := b.(float64)
+		if av < bv {
+			return -1
+		}
+		if av > bv {
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// compareBooks orders two books by the query's sort fields, then by ID
+func compareBooks(q BookQuery, a, b models.Book) int {
+	for _, f := range q.Sort {
+		c := compareValues(sortValue(a, f.Field), sortValue(b, f.Field))
+		if f.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+
+	return strings.Compare(a.ID.Hex(), b.ID.Hex())
+}
+
+// afterCursor reports whether book sorts strictly after the cursor position
+func afterCursor(q BookQuery, c cursor, id primitive.ObjectID, book models.Book) bool {
+	for i, f := range q.Sort {
+		cmp := compareValues(sortValue(book, f.Field), c.Values[i])
+		if f.Desc {
+			cmp = -cmp
+		}
+		if cmp != 0 {
+			return cmp > 0
+		}
+	}
+
+	return strings.Compare(book.ID.Hex(), id.Hex()) > 0
+}
+
+// Matches reports whether a book passes the filter
+func (f BookFilter) Matches(b models.Book) bool {
+	if f.Genre != "" && b.Genre != f.Genre {
+		return false
+	}
+	if f.AuthorID != "" && b.AuthorID != f.AuthorID {
+		return false
+	}
+	if f.PublisherID != "" && b.PublisherID != f.PublisherID {
+		return false
+	}
+	if f.MinPrice != nil && b.Price < *f.MinPrice {
+		return false
+	}
+	if f.MaxPrice != nil && b.Price > *f.MaxPrice {
+		return false
+	}
+	if f.InStock != nil && (b.Quantity > 0) != *f.InStock {
+		return false
+	}
+	if i := priceBandIndex(f.PriceBand); i >= 0 && !PriceBands[i].contains(b.Price) {
+		return false
+	}
+	if prefix, ok := decadePrefix(f.Decade); ok && !strings.HasPrefix(b.PublicationDate, prefix) {
+		return false
+	}
+
+	return true
+}
+
+// applyQuery runs a query over an in-memory slice of books
+func applyQuery(books []models.Book, q BookQuery) (BookPage, error) {
+	if err := q.Validate(); err != nil {
+		return BookPage{}, err
+	}
+
+	matched := []models.Book{}
+	for _, b := range books {
+		if q.Filter.Matches(b) {
+			matched = append(matched, b)
+		}
+	}
+	total := int64(len(matched))
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		return compareBooks(q, matched[i], matched[j]) < 0
+	})
+
+	if q.Cursor != "" {
+		c, id, err := decodeCursor(q)
+		if err != nil {
+			return BookPage{}, err
+		}
+
+		start := sort.Search(len(matched), func(i int) bool {
+			return afterCursor(q, c, id, matched[i])
+		})
+		matched = matched[start:]
+	}
+
+	if q.Offset > 0 {
+		if q.Offset >= len(matched) {
+			matched = matched[:0]
+		} else {
+			matched = matched[q.Offset:]
+		}
+	}
+
+	page := BookPage{Books: matched, Total: total}
+	if q.Limit > 0 && len(matched) > q.Limit {
+		page.Books = matched[:q.Limit]
+		page.NextCursor = encodeCursor(q, page.Books[q.Limit-1])
+	}
+
+	return page, nil
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/harshakumara/book-api/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func queryTestBooks() []models.Book {
+	return []models.Book{
+		{ID: primitive.NewObjectID(), Title: "A", Genre: "Fantasy", Price: 20, Quantity: 1, PublicationDate: "2001-01-01"},
+		{ID: primitive.NewObjectID(), Title: "B", Genre: "Fantasy", Price: 10, Quantity: 0, PublicationDate: "1999-01-01"},
+		{ID: primitive.NewObjectID(), Title: "C", Genre: "Novel", Price: 10, Quantity: 5, PublicationDate: "2010-01-01"},
+		{ID: primitive.NewObjectID(), Title: "D", Genre: "Fantasy", Price: 30, Quantity: 2, PublicationDate: "2005-01-01"},
+	}
+}
+
+func titles(books []models.Book) string {
+	s := ""
+	for _, b := range books {
+		s += b.Title
+	}
+	return s
+}
+
+func TestApplyQueryFiltersAndSorts(t *testing.T) {
+	minPrice := 15.0
+	inStock := true
+	sort, err := ParseSort("-price")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := applyQuery(queryTestBooks(), BookQuery{
+		Filter: BookFilter{Genre: "Fantasy", MinPrice: &minPrice, InStock: &inStock},
+		Sort:   sort,
+	})
+	if err != nil {
+		t.Fatalf("applyQuery failed: %v", err)
+	}
+
+	if got := titles(page.Books); got != "DA" {
+		t.Errorf("Expected DA, got %s", got)
+	}
+	if page.Total != 2 {
+		t.Errorf("Expected total 2, got %d", page.Total)
+	}
+}
+
+func TestApplyQueryCursorPagination(t *testing.T) {
+	books := queryTestBooks()
+	sort, _ := ParseSort("price,-publicationDate")
+	q := BookQuery{Sort: sort, Limit: 3}
+
+	var got string
+	for {
+		page, err := applyQuery(books, q)
+		if err != nil {
+			t.Fatalf("applyQuery failed: %v", err)
+		}
+		if page.Total != 4 {
+			t.Errorf("Expected total 4, got %d", page.Total)
+		}
+		got += titles(page.Books)
+		if page.NextCursor == "" {
+			break
+		}
+		q.Cursor = page.NextCursor
+	}
+
+	if got != "CBAD" {
+		t.Errorf("Expected CBAD across pages, got %s", got)
+	}
+}
+
+func TestApplyQueryOffset(t *testing.T) {
+	sort, _ := ParseSort("title")
+	page, err := applyQuery(queryTestBooks(), BookQuery{Sort: sort, Offset: 1, Limit: 2})
+	if err != nil {
+		t.Fatalf("applyQuery failed: %v", err)
+	}
+	if got := titles(page.Books); got != "BC" {
+		t.Errorf("Expected BC, got %s", got)
+	}
+}
+
+func TestApplyQueryRejectsBadInput(t *testing.T) {
+	if _, err := ParseSort("color"); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("Expected ErrInvalidQuery for unknown sort field, got %v", err)
+	}
+
+	sort, _ := ParseSort("title")
+	page, _ := applyQuery(queryTestBooks(), BookQuery{Sort: sort, Limit: 1})
+	other, _ := ParseSort("price")
+	if _, err := applyQuery(queryTestBooks(), BookQuery{Sort: other, Cursor: page.NextCursor}); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("Expected ErrInvalidQuery for cursor from another sort, got %v", err)
+	}
+
+	if _, err := applyQuery(queryTestBooks(), BookQuery{Cursor: "!!", Limit: 1}); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("Expected ErrInvalidQuery for malformed cursor, got %v", err)
+	}
+}
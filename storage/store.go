@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/harshakumara/book-api/models"
+)
+
+// Errors returned by store implementations
+var (
+	ErrNotFound  = errors.New("not found")
+	ErrInvalidID = errors.New("invalid ID format")
+	ErrConflict  = errors.New("already exists")
+	ErrHasBooks  = errors.New("still referenced by books")
+
+	// ErrDuplicateISBN is returned when a book would share its ISBN with
+	// another book. It is also an ErrConflict.
+	ErrDuplicateISBN = fmt.Errorf("isbn %w", ErrConflict)
+
+	ErrInsufficientStock  = errors.New("insufficient stock")
+	ErrReservationClosed  = errors.New("reservation is no longer active")
+	ErrReservationExpired = errors.New("reservation has expired")
+
+	ErrInvalidTransition = errors.New("invalid status transition")
+
+	// ErrVersionMismatch is returned when a conditional write names a
+	// version that is no longer the book's current one
+	ErrVersionMismatch = errors.New("version does not match")
+
+	// ErrNotApplied marks the items of an all-or-nothing bulk operation
+	// that were valid but not written because another item failed
+	ErrNotApplied = errors.New("not applied because another item failed")
+
+	// ErrAtomicUnsupported is returned for an all-or-nothing bulk operation
+	// on a backend that cannot roll one back
+	ErrAtomicUnsupported = errors.New("all-or-nothing bulk operations need a MongoDB replica set")
+)
+
+// ReferenceError is returned when a book points at a record that does not exist
+type ReferenceError struct {
+	Field    string
+	Resource string
+	ID       string
+}
+
+func (e *ReferenceError) Error() string {
+	return fmt.Sprintf("%s %q does not refer to an existing %s", e.Field, e.ID, e.Resource)
+}
+
+// DeletePolicy controls what happens to books when the record they reference is deleted
+type DeletePolicy string
+
+// Supported delete policies
+const (
+	// DeleteReject refuses the delete while books still reference the record
+	DeleteReject DeletePolicy = "reject"
+	// DeleteCascade deletes the referencing books as well
+	DeleteCascade DeletePolicy = "cascade"
+	// DeleteOrphan clears the reference on the books and keeps them
+	DeleteOrphan DeletePolicy = "orphan"
+)
+
+// ParseDeletePolicy validates a delete policy name
+func ParseDeletePolicy(s string) (DeletePolicy, error) {
+	switch p := DeletePolicy(s); p {
+	case DeleteReject, DeleteCascade, DeleteOrphan:
+		return p, nil
+	}
+
+	return "", fmt.Errorf("unknown delete policy %q (want reject, cascade or orphan)", s)
+}
+
+// AnyVersion makes a book write unconditional
+const AnyVersion int64 = -1
+
+// CreateOnly, as the Version of a book in a bulk upsert, creates the book
+// with its ID and fails with ErrConflict if a book with that ID exists
+const CreateOnly int64 = -2
+
+// BookStore is the persistence layer used by the book handlers
+type BookStore interface {
+	// List returns one page of books matching the query
+	List(ctx context.Context, q BookQuery) (BookPage, error)
+	// Facets counts the named facets over the books matching the filter
+	Facets(ctx context.Context, filter BookFilter, names []string) (Facets, error)
+	// Get returns a single book by ID
+	Get(ctx context.Context, id string) (models.Book, error)
+	// GetMany returns the books with the given IDs, in no particular order.
+	// IDs that are malformed or match no book are skipped.
+	GetMany(ctx context.Context, ids []string) ([]models.Book, error)
+	// Create stores a new book at book.Version, or at version 1 if that is
+	// not positive, generating an ID if none is set
+	Create(ctx context.Context, book models.Book) (models.Book, error)
+	// Update replaces the book with the given ID and increments its version.
+	// Unless version is AnyVersion, the stored book must be at that version
+	// or ErrVersionMismatch is returned.
+	Update(ctx context.Context, id string, book models.Book, version int64) (models.Book, error)
+	// Patch loads the book with the given ID, passes it to apply and stores
+	// the book apply returns, checking version like Update. The read and
+	// write are atomic; on MongoDB only the fields apply changed are written.
+	Patch(ctx context.Context, id string, version int64, apply func(models.Book) (models.Book, error)) (models.Book, error)
+	// Delete removes the book with the given ID, checking version like Update
+	Delete(ctx context.Context, id string, version int64) error
+	// Search returns books whose title or description contains the keyword.
+	// The keyword is matched literally, never as a regular expression.
+	Search(ctx context.Context, keyword string) ([]models.Book, error)
+	// SearchPattern returns books whose title or description matches the
+	// pattern, failing with ErrSearchTimeout if that takes longer than
+	// PatternTimeout
+	SearchPattern(ctx context.Context, p *Pattern) ([]models.Book, error)
+	// SearchStructured returns the books matching a structured query,
+	// ordered by title and then ID
+	SearchStructured(ctx context.Context, q *StructuredQuery) ([]models.Book, error)
+	// FindByISBN returns the books whose ISBN is one of isbns.
+	// ISBNs are unique, so there is at most one book per ISBN.
+	FindByISBN(ctx context.Context, isbns []string) ([]models.Book, error)
+	// BulkUpsert creates each book whose ID is unset or unknown and replaces
+	// each book whose ID exists, returning one result per book. A book with
+	// a positive Version only replaces the stored book at that version,
+	// failing with ErrVersionMismatch, or ErrNotFound if there is none; one
+	// with Version CreateOnly is only created. With atomic set nothing is
+	// written unless every book succeeds.
+	BulkUpsert(ctx context.Context, books []models.Book, atomic bool) ([]BulkResult, error)
+	// DeleteMany removes the books with the given IDs, returning one error
+	// (or nil) per ID. versions is nil or holds the version each book must
+	// be at, checked like Delete. With atomic set nothing is deleted unless
+	// every item succeeds.
+	DeleteMany(ctx context.Context, ids []string, versions []int64, atomic bool) ([]error, error)
+	// DeleteWhere removes every book matching the filter and returns how many
+	DeleteWhere(ctx context.Context, filter BookFilter) (int64, error)
+}
+
+// RevisionStore keeps the audit log of book changes. Revisions are never
+// changed once recorded.
+type RevisionStore interface {
+	// Record appends revisions to the log
+	Record(ctx context.Context, revisions []models.Revision) error
+	// Get returns a single revision by ID
+	Get(ctx context.Context, id string) (models.Revision, error)
+	// History returns every revision of a book, oldest first
+	History(ctx context.Context, bookID string) ([]models.Revision, error)
+	// Since returns up to limit revisions of any book recorded at or after
+	// since, oldest first. Limit 0 means no limit.
+	Since(ctx context.Context, since time.Time, limit int) ([]models.Revision, error)
+}
+
+// BulkResult is the outcome of one book of a bulk upsert
+type BulkResult struct {
+	Book    models.Book
+	Created bool
+	Err     error
+}
+
+// AuthorStore is the persistence layer used by the author handlers
+type AuthorStore interface {
+	// List returns all authors ordered by name
+	List(ctx context.Context) ([]models.Author, error)
+	// Get returns a single author by ID
+	Get(ctx context.Context, id string) (models.Author, error)
+	// Create stores a new author, generating an ID if none is set
+	Create(ctx context.Context, author models.Author) (models.Author, error)
+	// Update replaces the author with the given ID
+	Update(ctx context.Context, id string, author models.Author) (models.Author, error)
+	// Delete removes the author, handling their books according to policy
+	Delete(ctx context.Context, id string, policy DeletePolicy) error
+}
+
+// PublisherStore is the persistence layer used by the publisher handlers
+type PublisherStore interface {
+	// List returns all publishers ordered by name
+	List(ctx context.Context) ([]models.Publisher, error)
+	// Get returns a single publisher by ID
+	Get(ctx context.Context, id string) (models.Publisher, error)
+	// Create stores a new publisher, generating an ID if none is set
+	Create(ctx context.Context, publisher models.Publisher) (models.Publisher, error)
+	// Update replaces the publisher with the given ID
+	Update(ctx context.Context, id string, publisher models.Publisher) (models.Publisher, error)
+	// Delete removes the publisher, handling its books according to policy
+	Delete(ctx context.Context, id string, policy DeletePolicy) error
+}
+
+// InventoryStore manages stock levels and reservations.
+// Implementations guarantee that Book.Quantity never goes negative, even
+// under concurrent requests.
+type InventoryStore interface {
+	// AdjustStock changes a book's quantity by delta and records the reason
+	AdjustStock(ctx context.Context, bookID string, delta int, reason string) (models.StockAdjustment, error)
+	// Reserve takes quantity off a book's stock until the reservation is
+	// committed, released or expires after ttl
+	Reserve(ctx context.Context, bookID string, quantity int, ttl time.Duration) (models.Reservation, error)
+	// GetReservation returns a reservation by ID
+	GetReservation(ctx context.Context, id string) (models.Reservation, error)
+	// ReleaseReservation returns an active reservation's stock to the book
+	ReleaseReservation(ctx context.Context, id string) (models.Reservation, error)
+	// CommitReservation makes an active reservation's stock decrement permanent
+	CommitReservation(ctx context.Context, id string) (models.Reservation, error)
+	// ExpireReservations releases every active reservation that expired before now
+	ExpireReservations(ctx context.Context, now time.Time) (int, error)
+}
+
+// OrderStore is the persistence layer used by the order handlers
+type OrderStore interface {
+	// List returns orders, newest first, optionally only those with the given status
+	List(ctx context.Context, status string) ([]models.Order, error)
+	// Get returns a single order by ID
+	Get(ctx context.Context, id string) (models.Order, error)
+	// Create places a pending order, copying each item's title and price from its book
+	Create(ctx context.Context, order models.Order) (models.Order, error)
+	// Transition moves an order to a new status. Paying takes the items off
+	// stock and fails with ErrInsufficientStock if any book is short;
+	// cancelling a paid order puts them back.
+	Transition(ctx context.Context, id, status string) (models.Order, error)
+}
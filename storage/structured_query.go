@@ -0,0 +1,366 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/harshakumara/book-api/models"
+	"github.com/harshakumara/book-api/search"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// StructuredQuery is a query such as genre:Fantasy price:<20 "young wizard"
+// whose fields have been checked against the book fields. It can be run
+// in memory with Matches or on MongoDB as a filter.
+type StructuredQuery struct {
+	source string
+	root   queryNode
+}
+
+// queryNode is a resolved node of a structured query
+type queryNode interface {
+	matches(b models.Book) bool
+	mongo() bson.M
+}
+
+// fieldKind says how a field's values are parsed and compared
+type fieldKind int
+
+const (
+	// kindText fields contain the value, ignoring case
+	kindText fieldKind = iota
+	// kindExact fields equal the value, ignoring case
+	kindExact
+	// kindISBN fields equal the value once both are normalized
+	kindISBN
+	// kindNumber fields compare numerically with any operator
+	kindNumber
+	// kindDate fields compare with the period the value names, so 1997
+	// covers every date in 1997
+	kindDate
+	// kindStock is true for books with a positive quantity
+	kindStock
+)
+
+// queryField describes a field clients may name in a structured query.
+// key is the BSON and JSON name of the field.
+type queryField struct {
+	key  string
+	kind fieldKind
+}
+
+// queryFields maps the lower-cased field names of structured queries
+var queryFields = map[string]queryField{
+	"title":           {key: "title", kind: kindText},
+	"description":     {key: "description", kind: kindText},
+	"genre":           {key: "genre", kind: kindExact},
+	"authorid":        {key: "authorId", kind: kindExact},
+	"publisherid":     {key: "publisherId", kind: kindExact},
+	"isbn":            {key: "isbn", kind: kindISBN},
+	"pages":           {key: "pages", kind: kindNumber},
+	"price":           {key: "price", kind: kindNumber},
+	"quantity":        {key: "quantity", kind: kindNumber},
+	"publicationdate": {key: "publicationDate", kind: kindDate},
+	"published":       {key: "publicationDate", kind: kindDate},
+	"instock":         {key: "quantity", kind: kindStock},
+}
+
+// QueryFieldNames lists the fields structured queries can name
+var QueryFieldNames = []string{
+	"title", "description", "genre", "authorId", "publisherId", "isbn",
+	"pages", "price", "quantity", "publicationDate", "published", "inStock",
+}
+
+// ParseStructuredQuery parses a structured query and checks its fields.
+// Field names ignore case. Every error is a *search.SyntaxError pointing at
+// the offending part of the query.
+func ParseStructuredQuery(source string) (*StructuredQuery, error) {
+	expr, err := search.ParseQuery(source)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := resolve(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StructuredQuery{source: source, root: root}, nil
+}
+
+// String returns the query as the client wrote it
+func (q *StructuredQuery) String() string {
+	return q.source
+}
+
+// Matches reports whether a book satisfies the query
+func (q *StructuredQuery) Matches(b models.Book) bool {
+	return q.root.matches(b)
+}
+
+// mongoFilter translates the query into a MongoDB filter document
+func (q *StructuredQuery) mongoFilter() bson.M {
+	return q.root.mongo()
+}
+
+// resolve turns a parsed expression into query nodes
+func resolve(expr search.Expr) (queryNode, error) {
+	switch e := expr.(type) {
+	case *search.And:
+		nodes, err := resolveAll(e.Exprs)
+		return andNode(nodes), err
+	case *search.Or:
+		nodes, err := resolveAll(e.Exprs)
+		return orNode(nodes), err
+	case *search.Not:
+		node, err := resolve(e.Expr)
+		return notNode{node}, err
+	case *search.Text:
+		return textNode(e.Value), nil
+	case *search.Field:
+		return resolveField(e)
+	}
+
+	return nil, &search.SyntaxError{Position: expr.Pos(), Message: "unsupported expression"}
+}
+
+func resolveAll(exprs []search.Expr) ([]queryNode, error) {
+	nodes := make([]queryNode, len(exprs))
+	for i, expr := range exprs {
+		node, err := resolve(expr)
+		if err != nil {
+			return nil, err
+		}
+		nodes[i] = node
+	}
+	return nodes, nil
+}
+
+// resolveField checks a field term's name, operator and value
+func resolveField(f *search.Field) (queryNode, error) {
+	field, ok := queryFields[strings.ToLower(f.Name)]
+	if !ok {
+		return nil, &search.SyntaxError{
+			Position: f.At,
+			Message:  fmt.Sprintf("unknown field %q (want %s)", f.Name, strings.Join(QueryFieldNames, ", ")),
+		}
+	}
+	if f.Op != search.OpEqual && field.kind != kindNumber && field.kind != kindDate {
+		return nil, &search.SyntaxError{Position: f.OpAt, Message: fmt.Sprintf("%s cannot be compared with %s", f.Name, f.Op)}
+	}
+
+	node := fieldNode{key: field.key, kind: field.kind, op: f.Op, value: f.Value}
+	switch field.kind {
+	case kindISBN:
+		node.value = models.NormalizeISBN(f.Value)
+	case kindNumber:
+		n, err := strconv.ParseFloat(f.Value, 64)
+		if err != nil {
+			return nil, &search.SyntaxError{Position: f.ValueAt, Message: fmt.Sprintf("%s must be a number", f.Name)}
+		}
+		node.number = n
+	case kindDate:
+		if strings.Trim(f.Value, "0123456789-") != "" {
+			return nil, &search.SyntaxError{Position: f.ValueAt, Message: fmt.Sprintf("%s must be a date such as 1997 or 1997-06-26", f.Name)}
+		}
+	case kindStock:
+		inStock, err := strconv.ParseBool(f.Value)
+		if err != nil {
+			return nil, &search.SyntaxError{Position: f.ValueAt, Message: fmt.Sprintf("%s must be true or false", f.Name)}
+		}
+		node.flag = inStock
+	}
+
+	return node, nil
+}
+
+type andNode []queryNode
+
+func (n andNode) matches(b models.Book) bool {
+	for _, node := range n {
+		if !node.matches(b) {
+			return false
+		}
+	}
+	return true
+}
+
+func (n andNode) mongo() bson.M {
+	clauses := make([]bson.M, len(n))
+	for i, node := range n {
+		clauses[i] = node.mongo()
+	}
+	return bson.M{"$and": clauses}
+}
+
+type orNode []queryNode
+
+func (n orNode) matches(b models.Book) bool {
+	for _, node := range n {
+		if node.matches(b) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n orNode) mongo() bson.M {
+	clauses := make([]bson.M, len(n))
+	for i, node := range n {
+		clauses[i] = node.mongo()
+	}
+	return bson.M{"$or": clauses}
+}
+
+type notNode struct {
+	node queryNode
+}
+
+func (n notNode) matches(b models.Book) bool {
+	return !n.node.matches(b)
+}
+
+func (n notNode) mongo() bson.M {
+	return bson.M{"$nor": []bson.M{n.node.mongo()}}
+}
+
+// textNode matches books whose title or description contains it, ignoring case
+type textNode string
+
+func (n textNode) matches(b models.Book) bool {
+	return containsFold(b.Title, string(n)) || containsFold(b.Description, string(n))
+}
+
+func (n textNode) mongo() bson.M {
+	return regexFilter(regexp.QuoteMeta(string(n)))
+}
+
+// fieldNode is a resolved field term
+type fieldNode struct {
+	key    string
+	kind   fieldKind
+	op     string
+	value  string
+	number float64
+	flag   bool
+}
+
+func (n fieldNode) matches(b models.Book) bool {
+	switch n.kind {
+	case kindText:
+		return containsFold(bookString(b, n.key), n.value)
+	case kindExact:
+		return strings.EqualFold(bookString(b, n.key), n.value)
+	case kindISBN:
+		return b.ISBN == n.value
+	case kindNumber:
+		return compareWith(n.op, compareValues(sortValue(b, n.key), n.number))
+	case kindDate:
+		// Cut the date to the length of the value so it compares as a period
+		date := b.PublicationDate
+		if len(date) > len(n.value) {
+			date = date[:len(n.value)]
+		}
+		return compareWith(n.op, strings.Compare(date, n.value))
+	case kindStock:
+		return (b.Quantity > 0) == n.flag
+	}
+	return false
+}
+
+func (n fieldNode) mongo() bson.M {
+	switch n.kind {
+	case kindText:
+		return bson.M{n.key: bson.M{"$regex": regexp.QuoteMeta(n.value), "$options": "i"}}
+	case kindExact:
+		return bson.M{n.key: bson.M{"$regex": "^" + regexp.QuoteMeta(n.value) + "$", "$options": "i"}}
+	case kindISBN:
+		return bson.M{n.key: n.value}
+	case kindNumber:
+		if n.op == search.OpEqual {
+			return bson.M{n.key: n.number}
+		}
+		return bson.M{n.key: bson.M{mongoOperators[n.op]: n.number}}
+	case kindDate:
+		// Dates within the period start with the value
+		within := bson.M{n.key: bson.M{"$regex": "^" + regexp.QuoteMeta(n.value)}}
+		switch n.op {
+		case search.OpLess:
+			return bson.M{n.key: bson.M{"$lt": n.value}}
+		case search.OpGreaterEqual:
+			return bson.M{n.key: bson.M{"$gte": n.value}}
+		case search.OpGreater:
+			return bson.M{"$and": []bson.M{{n.key: bson.M{"$gt": n.value}}, {"$nor": []bson.M{within}}}}
+		case search.OpLessEqual:
+			return bson.M{"$or": []bson.M{{n.key: bson.M{"$lt": n.value}}, within}}
+		}
+		return within
+	case kindStock:
+		if n.flag {
+			return bson.M{n.key: bson.M{"$gt": 0}}
+		}
+		return bson.M{n.key: bson.M{"$lte": 0}}
+	}
+	return bson.M{}
+}
+
+// mongoOperators maps comparison operators to MongoDB query operators
+var mongoOperators = map[string]string{
+	search.OpLess:         "$lt",
+	search.OpLessEqual:    "$lte",
+	search.OpGreater:      "$gt",
+	search.OpGreaterEqual: "$gte",
+}
+
+// compareWith reports whether the result of a comparison satisfies op
+func compareWith(op string, cmp int) bool {
+	switch op {
+	case search.OpLess:
+		return cmp < 0
+	case search.OpLessEqual:
+		return cmp <= 0
+	case search.OpGreater:
+		return cmp > 0
+	case search.OpGreaterEqual:
+		return cmp >= 0
+	}
+	return cmp == 0
+}
+
+// bookString returns a string field of a book by its JSON name
+func bookString(b models.Book, key string) string {
+	if key == "description" {
+		return b.Description
+	}
+	s, _ := sortValue(b, key).(string)
+	return s
+}
+
+// containsFold reports whether s contains substr, ignoring case
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// searchStructured returns the books q matches, ordered by title and then ID
+func searchStructured(ctx context.Context, books []models.Book, q *StructuredQuery) ([]models.Book, error) {
+	results := []models.Book{}
+	for _, b := range books {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if q.Matches(b) {
+			results = append(results, b)
+		}
+	}
+
+	byTitle := BookQuery{Sort: []SortField{{Field: "title"}}}
+	sort.Slice(results, func(i, j int) bool {
+		return compareBooks(byTitle, results[i], results[j]) < 0
+	})
+
+	return results, nil
+}
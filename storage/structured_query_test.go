@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/harshakumara/book-api/models"
+	"github.com/harshakumara/book-api/search"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestStructuredQueryMatches(t *testing.T) {
+	book := models.Book{
+		Title:           "The Hobbit",
+		Description:     "There and back again",
+		Genre:           "Fantasy",
+		AuthorID:        "a1",
+		ISBN:            "9780261103344",
+		Pages:           310,
+		Price:           12.99,
+		Quantity:        0,
+		PublicationDate: "1937-09-21",
+	}
+
+	for query, want := range map[string]bool{
+		`hobbit`:                       true,
+		`"back again"`:                 true,
+		`dragon`:                       false,
+		`genre:fantasy`:                true,
+		`genre:fant`:                   false,
+		`title:HOBBIT`:                 true,
+		`authorId:a1 pages:>=310`:      true,
+		`pages:>310`:                   false,
+		`price:<13 price:>12`:          true,
+		`price:=12.99`:                 true,
+		`isbn:0-261-10334-2`:           true,
+		`inStock:false`:                true,
+		`published:1937`:               true,
+		`published:193`:                true,
+		`published:<1937`:              false,
+		`published:<=1937`:             true,
+		`published:>1936-12`:           true,
+		`publicationDate:>=1938`:       false,
+		`-genre:Fantasy OR pages:<400`: true,
+		`NOT (hobbit OR dragon)`:       false,
+	} {
+		q, err := ParseStructuredQuery(query)
+		if err != nil {
+			t.Errorf("ParseStructuredQuery(%q) failed: %v", query, err)
+			continue
+		}
+		if got := q.Matches(book); got != want {
+			t.Errorf("Expected %q to match %v, got %v", query, want, got)
+		}
+	}
+}
+
+func TestParseStructuredQueryErrors(t *testing.T) {
+	for query, position := range map[string]int{
+		`colour:red`:            1,
+		`hobbit Colour:red`:     8,
+		`genre:<Fantasy`:        7,
+		`inStock:>=true`:        9,
+		`price:cheap`:           7,
+		`pages:>=lots`:          9,
+		`inStock:maybe`:         9,
+		`published:"last year"`: 11,
+		`price:`:                7,
+	} {
+		_, err := ParseStructuredQuery(query)
+		var syntaxErr *search.SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("Expected %q to be rejected with a syntax error, got %v", query, err)
+			continue
+		}
+		if syntaxErr.Position != position {
+			t.Errorf("Expected %q to fail at position %d, got %v", query, position, err)
+		}
+	}
+}
+
+// bsonDepth returns how deeply documents and arrays nest in a filter
+func bsonDepth(v interface{}) int {
+	var children []interface{}
+	switch v := v.(type) {
+	case bson.M:
+		for _, child := range v {
+			children = append(children, child)
+		}
+	case []bson.M:
+		for _, child := range v {
+			children = append(children, child)
+		}
+	default:
+		return 0
+	}
+
+	deepest := 0
+	for _, child := range children {
+		if d := bsonDepth(child); d > deepest {
+			deepest = d
+		}
+	}
+	return deepest + 1
+}
+
+func TestStructuredQueryMongoDepth(t *testing.T) {
+	// Every level nests an AND and an OR, the deepest a query can get
+	depth := search.MaxQueryDepth
+	source := strings.Repeat("a (b OR ", depth) + "publicationDate:>1990" + strings.Repeat(")", depth)
+	q, err := ParseStructuredQuery(source)
+	if err != nil {
+		t.Fatalf("ParseStructuredQuery failed: %v", err)
+	}
+
+	// MongoDB rejects filters nested more than 100 levels deep
+	if got := bsonDepth(q.mongoFilter()); got > 90 {
+		t.Errorf("Expected the filter to leave room under MongoDB's nesting limit, got depth %d", got)
+	}
+}
@@ -10,6 +10,38 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// SampleAuthors returns the authors referenced by SampleBooks
+func SampleAuthors() []models.Author {
+	return []models.Author{
+		{ID: "e0d91f68-a183-477d-8aa4-1f44ccc78a70", Name: "F. Scott Fitzgerald", BirthDate: "1896-09-24", Nationality: "American"},
+		{ID: "a7f3d45b-c892-4b7e-9f8a-2d6c4e3b5c0d", Name: "Harper Lee", BirthDate: "1926-04-28", Nationality: "American"},
+		{ID: "b8e2c1d9-a7f3-4b5c-9d8e-2c1b3a4d5e6f", Name: "George Orwell", BirthDate: "1903-06-25", Nationality: "British"},
+		{ID: "c9d8e7f6-5a4b-3c2d-1e0f-9a8b7c6d5e4f", Name: "J.K. Rowling", BirthDate: "1965-07-31", Nationality: "British"},
+		{ID: "d0e9f8a7-b6c5-4d3e-2f1a-0b9c8d7e6f5a", Name: "J.R.R. Tolkien", BirthDate: "1892-01-03", Nationality: "British"},
+		{ID: "e1f0a9b8-c7d6-5e4f-3a2b-1c0d9e8f7a6b", Name: "Jane Austen", BirthDate: "1775-12-16", Nationality: "British"},
+		{ID: "f2a1b0c9-d8e7-6f5a-4b3c-2d1e0f9a8b7c", Name: "John Ronald Reuel Tolkien", BirthDate: "1892-01-03", Nationality: "British"},
+		{ID: "a3b2c1d0-e9f8-7a6b-5c4d-3e2f1a0b9c8d", Name: "J.D. Salinger", BirthDate: "1919-01-01", Nationality: "American"},
+		{ID: "b4c3d2e1-f0a9-8b7c-6d5e-4f3a2b1c0d9e", Name: "Aldous Huxley", BirthDate: "1894-07-26", Nationality: "British"},
+		{ID: "c5d4e3f2-a1b0-9c8d-7e6f-5a4b3c2d1e0f", Name: "Herman Melville", BirthDate: "1819-08-01", Nationality: "American"},
+	}
+}
+
+// SamplePublishers returns the publishers referenced by SampleBooks
+func SamplePublishers() []models.Publisher {
+	return []models.Publisher{
+		{ID: "2f7b19e9-b268-4440-a15b-bed8177ed607", Name: "Scribner", Country: "United States", Contact: models.Contact{Website: "https://www.scribner.com"}, Imprints: []string{"Scribner Classics"}},
+		{ID: "3e9d2f1a-b6c7-4d5e-8f9a-1b2c3d4e5f6a", Name: "Harper Perennial", Country: "United States", Contact: models.Contact{Website: "https://www.harperperennial.com"}, Imprints: []string{"Harper Perennial Modern Classics"}},
+		{ID: "4f5e6d7c-8b9a-1c2d-3e4f-5a6b7c8d9e0f", Name: "Signet Classics", Country: "United States", Contact: models.Contact{Website: "https://www.penguin.com"}, Imprints: []string{"Signet"}},
+		{ID: "5a6b7c8d-9e0f-1a2b-3c4d-5e6f7a8b9c0d", Name: "Scholastic", Country: "United States", Contact: models.Contact{Website: "https://www.scholastic.com"}, Imprints: []string{"Arthur A. Levine Books"}},
+		{ID: "6b7c8d9e-0f1a-2b3c-4d5e-6f7a8b9c0d1e", Name: "Houghton Mifflin", Country: "United States", Contact: models.Contact{Website: "https://www.hmhco.com"}, Imprints: []string{"Mariner Books"}},
+		{ID: "7c8d9e0f-1a2b-3c4d-5e6f-7a8b9c0d1e2f", Name: "Penguin Classics", Country: "United Kingdom", Contact: models.Contact{Website: "https://www.penguin.co.uk"}, Imprints: []string{"Penguin Classics"}},
+		{ID: "8d9e0f1a-2b3c-4d5e-6f7a-8b9c0d1e2f3a", Name: "Houghton Mifflin Harcourt", Country: "United States", Contact: models.Contact{Website: "https://www.hmhco.com"}, Imprints: []string{"Clarion Books"}},
+		{ID: "9e0f1a2b-3c4d-5e6f-7a8b-9c0d1e2f3a4b", Name: "Little, Brown and Company", Country: "United States", Contact: models.Contact{Website: "https://www.littlebrown.com"}, Imprints: []string{"Back Bay Books"}},
+		{ID: "0f1a2b3c-4d5e-6f7a-8b9c-0d1e2f3a4b5c", Name: "Harper Perennial Modern Classics", Country: "United States", Contact: models.Contact{Website: "https://www.harpercollins.com"}, Imprints: []string{"Harper Perennial"}},
+		{ID: "1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6d", Name: "Penguin Books", Country: "United States", Contact: models.Contact{Website: "https://www.penguin.com"}, Imprints: []string{"Penguin Classics"}},
+	}
+}
+
 // SampleBooks returns a slice of sample book data
 func SampleBooks() []models.Book {
 	return []models.Book{
@@ -136,39 +168,66 @@ func SampleBooks() []models.Book {
 	}
 }
 
-// SeedBooks adds sample books to the API via HTTP requests
+// SeedBooks adds sample authors, publishers and books to the API via HTTP requests
 func SeedBooks(apiURL string) error {
 	books := SampleBooks()
 
-	fmt.Println("Seeding database with sample books...")
+	fmt.Println("Seeding database with sample authors...")
 
-	for _, book := range books {
-		// Generate ID if needed
-		if book.ID.IsZero() {
-			book.ID = primitive.NewObjectID()
+	// Authors and publishers go first so the books' references resolve
+	for _, author := range SampleAuthors() {
+		if err := sendJSON(apiURL+"/authors", author, http.StatusCreated); err != nil {
+			return err
 		}
 
-		// Convert book to JSON
-		bookJSON, err := json.Marshal(book)
-		if err != nil {
-			return fmt.Errorf("error marshaling book: %v", err)
-		}
+		fmt.Printf("Added author: %s\n", author.Name)
+	}
+
+	fmt.Println("Seeding database with sample publishers...")
 
-		// Send POST request
-		resp, err := http.Post(apiURL+"/books", "application/json", bytes.NewBuffer(bookJSON))
-		if err != nil {
-			return fmt.Errorf("error sending POST request: %v", err)
+	for _, publisher := range SamplePublishers() {
+		if err := sendJSON(apiURL+"/publishers", publisher, http.StatusCreated); err != nil {
+			return err
 		}
-		defer resp.Body.Close()
 
-		// Check response status
-		if resp.StatusCode != http.StatusCreated {
-			return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		fmt.Printf("Added publisher: %s\n", publisher.Name)
+	}
+
+	fmt.Println("Seeding database with sample books...")
+
+	// Books go in one all-or-nothing batch
+	for i := range books {
+		if books[i].ID.IsZero() {
+			books[i].ID = primitive.NewObjectID()
 		}
+	}
 
-		fmt.Printf("Added book: %s\n", book.Title)
+	if err := sendJSON(apiURL+"/books/bulk", books, http.StatusOK); err != nil {
+		return err
 	}
 
+	fmt.Printf("Added %d books\n", len(books))
+
 	fmt.Println("Sample data seeding completed successfully!")
 	return nil
 }
+
+// sendJSON posts v to url and expects the given status
+func sendJSON(url string, v interface{}, status int) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("error marshaling %T: %v", v, err)
+	}
+
+	resp, err := http.Post(url, "application/json", bytes.NewBuffer(data))
+	if err != nil {
+		return fmt.Errorf("error sending POST request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != status {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -4,14 +4,32 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-// SeedFileStorage adds sample books directly to the JSON file storage
+// SeedFileStorage adds sample books directly to the JSON file storage.
+// The sample authors and publishers are written to authors.json and
+// publishers.json next to filePath.
 func SeedFileStorage(filePath string) error {
 	fmt.Println("Seeding file storage with sample books...")
 
+	related := map[string]interface{}{
+		"authors.json":    SampleAuthors(),
+		"publishers.json": SamplePublishers(),
+	}
+	for name, records := range related {
+		data, err := json.MarshalIndent(records, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error marshaling %s: %v", name, err)
+		}
+
+		if err := os.WriteFile(filepath.Join(filepath.Dir(filePath), name), data, 0644); err != nil {
+			return fmt.Errorf("error writing to file: %v", err)
+		}
+	}
+
 	books := SampleBooks()
 
 	// Assign IDs to all books